@@ -12,11 +12,19 @@ import (
 
 	"github.com/qiangli/ai/swarm"
 	"github.com/qiangli/ai/swarm/api"
+	"github.com/qiangli/ai/swarm/approval"
+	"github.com/qiangli/ai/swarm/db"
 	"github.com/qiangli/ai/swarm/llm/adapter"
+	"github.com/qiangli/ai/swarm/llm/selector"
 	"github.com/qiangli/ai/swarm/log"
-	"github.com/qiangli/ai/swarm/util/calllog"
+	mcpcli "github.com/qiangli/ai/swarm/mcp"
+	"github.com/qiangli/ai/swarm/policy"
+	"github.com/qiangli/ai/swarm/telemetry"
+	"github.com/qiangli/ai/swarm/util/cache"
 	"github.com/qiangli/ai/swarm/util/conf"
 	hist "github.com/qiangli/ai/swarm/util/history"
+	"github.com/qiangli/ai/swarm/util/usage"
+	"github.com/qiangli/ai/swarm/util/vault"
 	"github.com/qiangli/shell/vfs"
 	"github.com/qiangli/shell/vos"
 )
@@ -48,7 +56,18 @@ func Run(argv []string) error {
 		if slices.Contains([]string{"--base", "-base"}, v) {
 			if len(argv) > i+1 {
 				base = argv[i+1]
-				break
+			}
+		}
+		// rerun the input of a previous run, skipping its completed flow steps
+		if slices.Contains([]string{"--resume", "-resume"}, v) {
+			if len(argv) > i+1 {
+				app.Resume = argv[i+1]
+			}
+		}
+		// continue the conversation of a named or previous session
+		if slices.Contains([]string{"--session", "-session"}, v) {
+			if len(argv) > i+1 {
+				app.Session = argv[i+1]
 			}
 		}
 	}
@@ -62,6 +81,36 @@ func Run(argv []string) error {
 	app.Base = base
 	app.Input = argv
 
+	// ai /mcp serve: publish agents and tools to MCP clients
+	if isMcpServe(argv) {
+		return RunMcpServer(app, argv)
+	}
+
+	// ai /serve: OpenAI compatible chat completions
+	if isServe(argv) {
+		return RunServer(app, argv)
+	}
+
+	// ai /secret set|get|list|rm: manage the encrypted vault
+	if isSecret(argv) {
+		return RunSecret(app, argv)
+	}
+
+	// ai /log is short for /log:search
+	if len(argv) > 0 && argv[0] == "/log" {
+		app.Input = append([]string{"/log:search"}, argv[1:]...)
+	}
+
+	// ai /session list|show|fork|delete|export [id]
+	if len(argv) > 0 && argv[0] == "/session" {
+		app.Input = sessionCommand(argv)
+	}
+
+	// ai /usage [--today|--session [ID]|--agent NAME|--all]
+	if len(argv) > 0 && argv[0] == "/usage" {
+		app.Input = usageCommand(argv)
+	}
+
 	//
 	if err := RunSwarm(app); err != nil {
 		return err
@@ -73,10 +122,28 @@ func RunSwarm(cfg *api.App) error {
 	ctx := context.Background()
 
 	// init
-	sw, err := initSwarm(ctx, cfg)
+	sw, vars, err := initSwarm(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := telemetry.Shutdown(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to export traces: %v\n", err)
+		}
+	}()
+	// stop local mcp servers
+	defer mcpcli.DefaultPool.Close()
+
+	// journal the run for --resume
+	run, err := startRun(vars, cfg)
+	if err != nil {
+		return err
+	}
+	flow, err := api.NewFlowRun(run.ID, vars.Journal, cfg.Resume != "")
 	if err != nil {
 		return err
 	}
+	ctx = api.WithFlowRun(ctx, flow)
 
 	// ***
 	// parse input
@@ -107,7 +174,19 @@ func RunSwarm(cfg *api.App) error {
 		argm["pack"] = "root"
 		argm["name"] = "root"
 	}
-	if v, err := sw.Exec(ctx, argm); err != nil {
+	// print LLM output as it is generated unless --stream=false
+	var cs = newConsoleStream(ctx)
+	var stream = true
+	if _, found := argm["stream"]; found {
+		stream, _ = api.GetBoolProp("stream", argm)
+	}
+	if stream {
+		ctx = api.WithStreamHandler(ctx, cs.Handle)
+	}
+
+	v, err := sw.Exec(ctx, argm)
+	endRun(ctx, vars, run, err)
+	if err != nil {
 		// return err
 		out.Content = fmt.Sprintf("❌ %+v", err)
 	} else {
@@ -120,7 +199,11 @@ func RunSwarm(cfg *api.App) error {
 	if format == "" {
 		format = "markdown"
 	}
-	processOutput(ctx, format, &out)
+	if out.ContentType != api.ContentTypeImageB64 && cs.Streamed(out.Content) {
+		logger.Printf("\n")
+	} else {
+		processOutput(ctx, format, &out)
+	}
 
 	/* close tee file if opened */
 	if logger != nil {
@@ -132,7 +215,7 @@ func RunSwarm(cfg *api.App) error {
 	return nil
 }
 
-func initSwarm(ctx context.Context, cfg *api.App) (*swarm.Swarm, error) {
+func initSwarm(ctx context.Context, cfg *api.App) (*swarm.Swarm, *api.Vars, error) {
 	var user *api.User
 	if v, err := loadUser(cfg.Base); err != nil {
 		user = &api.User{
@@ -144,38 +227,52 @@ func initSwarm(ctx context.Context, cfg *api.App) (*swarm.Swarm, error) {
 	}
 
 	var sessionID = api.SessionID(uuid.NewString())
+	if cfg.Session != "" {
+		if !api.ValidSession(cfg.Session) {
+			return nil, nil, fmt.Errorf("invalid session: %q. letters, digits, '.', '_' and '-' only", cfg.Session)
+		}
+		sessionID = api.SessionID(cfg.Session)
+	}
+
+	// read before the env is cleared
+	passphrase := vault.Passphrase()
 
 	swarm.ClearAllEnv(essentialEnv)
 
 	var adapters = adapter.GetAdapters()
-	var secrets = conf.LocalSecrets
 
 	dc, err := conf.Load(cfg.Base)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	secrets, err := newSecrets(cfg.Base, dc, passphrase)
+	if err != nil {
+		return nil, nil, err
 	}
 	var roots = dc.Roots
 	dirs, err := roots.AllowedDirs()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if len(dirs) == 0 {
-		return nil, fmt.Errorf("root directories not configed")
+		return nil, nil, fmt.Errorf("root directories not configed")
 	}
 
 	//
 	callDir := filepath.Join(roots.Workspace.Path, "var", "log", "toolcall")
 	teeDir := filepath.Join(roots.Workspace.Path, "var", "log", "chat")
+	runDir := filepath.Join(roots.Workspace.Path, "var", "run")
+	memDir := filepath.Join(roots.Workspace.Path, "var", "lib")
 
 	//
 
 	lfs, err := vfs.NewLocalFS(dirs)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	los, err := vos.NewLocalSystem(lfs)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	// populate essentialEnv
 	for _, k := range essentialEnv {
@@ -184,28 +281,67 @@ func initSwarm(ctx context.Context, cfg *api.App) (*swarm.Swarm, error) {
 
 	assets, err := conf.Assets(dc, user.Email, secrets)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	blobs, err := conf.NewBlobs(dc, "")
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	mem, err := hist.NewFileMemStore(roots.Workspace.Path)
+	mem, err := db.OpenMemoryStore(memDir, "memory.db")
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	// history saved as json files by earlier versions
+	if n, err := hist.Import(roots.Workspace.Path, mem); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to import history: %v\n", err)
+	} else if n > 0 {
+		fmt.Fprintf(os.Stderr, "✔ imported %v messages into %s\n", n, filepath.Join(memDir, "memory.db"))
 	}
-	callogs, err := calllog.NewFileCallLog(filepath.Join(callDir, fmt.Sprintf("session_%s", sessionID)))
+	llmCache, err := cache.NewFileCache(roots.Workspace.Path, 0, 0)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	ledger, err := usage.NewFileLedger(roots.Workspace.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+	// shared by all sessions for ai /log
+	callogs, err := db.OpenCallLog(callDir, "calllog.db", string(sessionID))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// spans are flushed by RunSwarm
+	if err := telemetry.Setup(ctx, dc.Trace, roots.Workspace.Path, sessionID); err != nil {
+		return nil, nil, err
+	}
+
+	journal, err := db.OpenRunJournal(runDir, "journal.db")
+	if err != nil {
+		return nil, nil, err
+	}
+	if cfg.Resume != "" {
+		run, err := journal.GetRun(cfg.Resume)
+		if err != nil {
+			return nil, nil, err
+		}
+		cfg.Input = run.Input
+	}
+
+	// workspace rules take precedence over the user rules
+	authz, err := policy.Load(filepath.Join(roots.Workspace.Path, "policy.yaml"), filepath.Join(cfg.Base, "policy.yaml"))
+	if err != nil {
+		return nil, nil, err
 	}
 
 	tools, err := swarm.NewToolSystem(cfg.Base)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	var vars = &api.Vars{
-		SessionID: sessionID,
+		SessionID:    sessionID,
+		NamedSession: cfg.Session != "",
 		//
 		Base:      cfg.Base,
 		Input:     cfg.Input,
@@ -222,11 +358,23 @@ func initSwarm(ctx context.Context, cfg *api.App) (*swarm.Swarm, error) {
 		Adapters: adapters,
 		History:  mem,
 		Log:      callogs,
+		Cache:    llmCache,
+		Health:   selector.NewBreaker(),
+		Usage:    ledger,
+		Journal:  journal,
+		Policy:   authz,
+	}
+	if cfg := authz.ShellGuard(); cfg != nil {
+		vars.ShellGuard = swarm.NewShellGuard(cfg)
+	}
+	// console on a terminal, otherwise files under the workspace unless configured
+	if vars.Approver, err = approval.New(authz.Approval(), roots.Workspace.Path); err != nil {
+		return nil, nil, err
 	}
 
 	sw, err := swarm.New(vars)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// hook up tee logging for this run: write to <workspace>/var/log/conversation/<uuid>.log
@@ -244,7 +392,47 @@ func initSwarm(ctx context.Context, cfg *api.App) (*swarm.Swarm, error) {
 		fmt.Fprintf(os.Stderr, "failed to create tee dir %s: %v\n", teeDir, err)
 	}
 
-	return sw, nil
+	return sw, vars, nil
+}
+
+// startRun records the run in the journal, a resumed run keeps its id.
+func startRun(vars *api.Vars, cfg *api.App) (*api.Run, error) {
+	var run *api.Run
+	if cfg.Resume != "" {
+		v, err := vars.Journal.GetRun(cfg.Resume)
+		if err != nil {
+			return nil, err
+		}
+		run = v
+	} else {
+		input, _ := cfg.Input.([]string)
+		run = &api.Run{
+			ID:    uuid.NewString(),
+			Input: input,
+		}
+	}
+	run.Session = string(vars.SessionID)
+	run.Status = api.RunStatusRunning
+	run.Error = ""
+	if err := vars.Journal.SaveRun(run); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+func endRun(ctx context.Context, vars *api.Vars, run *api.Run, err error) {
+	run.Status = api.RunStatusCompleted
+	if err != nil {
+		run.Status = api.RunStatusFailed
+		run.Error = err.Error()
+	}
+	if err := vars.Journal.SaveRun(run); err != nil {
+		log.GetLogger(ctx).Errorf("failed to save run %s: %v\n", run.ID, err)
+		return
+	}
+	if run.Status == api.RunStatusFailed {
+		log.GetLogger(ctx).Infof("run %s failed, continue with: ai --resume %s\n", run.ID, run.ID)
+	}
 }
 
 func processOutput(ctx context.Context, format string, message *api.Output) {
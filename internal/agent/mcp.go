@@ -0,0 +1,101 @@
+package agent
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/qiangli/ai/internal"
+	"github.com/qiangli/ai/swarm"
+	"github.com/qiangli/ai/swarm/api"
+	"github.com/qiangli/ai/swarm/log"
+	mcpcli "github.com/qiangli/ai/swarm/mcp"
+	"github.com/qiangli/ai/swarm/telemetry"
+)
+
+// isMcpServe reports whether the input is ai /mcp serve or ai /mcp:serve
+func isMcpServe(argv []string) bool {
+	if len(argv) > 1 && argv[0] == "/mcp" && argv[1] == "serve" {
+		return true
+	}
+	return len(argv) > 0 && argv[0] == "/mcp:serve"
+}
+
+// RunMcpServer publishes the selected agents and tool kits to MCP clients
+// over stdin/stdout or streamable http, e.g.
+//
+//	ai /mcp serve --agents ops,coder/* --kits fs,web
+//	ai /mcp serve --agents ops --http localhost:8080
+func RunMcpServer(cfg *api.App, argv []string) error {
+	if argv[0] == "/mcp" {
+		argv = argv[2:]
+	} else {
+		argv = argv[1:]
+	}
+
+	fs := flag.NewFlagSet("mcp serve", flag.ContinueOnError)
+	agents := fs.String("agents", "", "comma separated agents to publish: pack, pack/sub or pack/*")
+	kits := fs.String("kits", "", "comma separated tool kits to publish")
+	addr := fs.String("http", "", "serve streamable http at the address instead of stdin/stdout")
+	// handled by Run
+	fs.String("base", "", "base directory")
+	fs.String("session", "", "session of the conversations")
+	if err := fs.Parse(argv); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	sw, vars, err := initSwarm(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	// stdin/stdout carry the protocol and http requests are not from the terminal
+	if err := serveApprover(vars); err != nil {
+		return err
+	}
+	defer func() {
+		if err := telemetry.Shutdown(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to export traces: %v\n", err)
+		}
+	}()
+	// stop local mcp servers
+	defer mcpcli.DefaultPool.Close()
+
+	server, err := sw.McpServer(ctx, &swarm.McpServerOptions{
+		Agents:  splitList(*agents),
+		Kits:    splitList(*kits),
+		Version: internal.Version,
+	})
+	if err != nil {
+		return err
+	}
+
+	if *addr != "" {
+		handler := mcp.NewStreamableHTTPHandler(func(*http.Request) *mcp.Server {
+			return server
+		}, nil)
+		log.GetLogger(ctx).Infof("⣿ mcp server listening on %s\n", *addr)
+		return http.ListenAndServe(*addr, handler)
+	}
+	if err := server.Run(ctx, &mcp.StdioTransport{}); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
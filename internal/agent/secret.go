@@ -0,0 +1,168 @@
+package agent
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/term"
+
+	"github.com/qiangli/ai/swarm/api"
+	"github.com/qiangli/ai/swarm/util/conf"
+	"github.com/qiangli/ai/swarm/util/vault"
+)
+
+// isSecret reports whether the input is ai /secret
+func isSecret(argv []string) bool {
+	return len(argv) > 0 && argv[0] == "/secret"
+}
+
+// vaultPath returns the vault file of the config, <base>/vault.json by default.
+func vaultPath(base string, dc *api.DHNTConfig) string {
+	if dc != nil && dc.Secrets != nil && dc.Secrets.Vault != "" {
+		if filepath.IsAbs(dc.Secrets.Vault) {
+			return dc.Secrets.Vault
+		}
+		return filepath.Join(base, dc.Secrets.Vault)
+	}
+	return filepath.Join(base, "vault.json")
+}
+
+// newSecrets returns the secret store checking the vault, the environment and the helper if configured.
+func newSecrets(base string, dc *api.DHNTConfig, passphrase vault.PassphraseFunc) (api.SecretStore, error) {
+	var stores = []api.SecretStore{
+		vault.Open(vaultPath(base, dc), passphrase),
+		conf.LocalSecrets,
+	}
+	if dc != nil && dc.Secrets != nil && dc.Secrets.Helper != "" {
+		helper, err := conf.NewHelperSecrets(dc.Secrets.Helper)
+		if err != nil {
+			return nil, err
+		}
+		stores = append(stores, helper)
+	}
+	return conf.NewSecrets(stores...), nil
+}
+
+// RunSecret manages the secrets of the vault:
+//
+//	ai /secret set KEY [VALUE]
+//	ai /secret get KEY
+//	ai /secret list
+//	ai /secret rm KEY
+//
+// the value of set is read from stdin if omitted, keeping it out of the shell history.
+// secrets are kept per owner, the user email by default.
+func RunSecret(cfg *api.App, argv []string) error {
+	flags := flag.NewFlagSet("secret", flag.ContinueOnError)
+	owner := flags.String("owner", "", "namespace of the secrets, defaults to the user email")
+	// handled by Run
+	flags.String("base", "", "base directory")
+
+	var cmd = "list"
+	var args = argv[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
+	// flags may follow the key
+	var rest []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+		if args = flags.Args(); len(args) == 0 {
+			break
+		}
+		rest, args = append(rest, args[0]), args[1:]
+	}
+	args = rest
+
+	if *owner == "" {
+		if user, err := loadUser(cfg.Base); err == nil {
+			*owner = user.Email
+		}
+	}
+	dc, err := conf.Load(cfg.Base)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	v := vault.Open(vaultPath(cfg.Base, dc), vault.Passphrase())
+
+	key := func() (string, error) {
+		if len(args) == 0 {
+			return "", fmt.Errorf("key is required: ai /secret %s KEY", cmd)
+		}
+		return args[0], nil
+	}
+
+	switch cmd {
+	case "set":
+		k, err := key()
+		if err != nil {
+			return err
+		}
+		var value string
+		if len(args) > 1 {
+			value = args[1]
+		} else if value, err = readSecret(k); err != nil {
+			return err
+		}
+		if value == "" {
+			return fmt.Errorf("value is required for %s", k)
+		}
+		if err := v.Set(*owner, k, value); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "✔ saved %s to %s\n", k, v.Path())
+	case "get":
+		k, err := key()
+		if err != nil {
+			return err
+		}
+		value, err := v.Get(*owner, k)
+		if err != nil {
+			return err
+		}
+		fmt.Println(value)
+	case "list", "ls":
+		keys, err := v.List(*owner)
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			fmt.Println(k)
+		}
+	case "rm", "delete":
+		k, err := key()
+		if err != nil {
+			return err
+		}
+		if err := v.Delete(*owner, k); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "✔ removed %s\n", k)
+	default:
+		return fmt.Errorf("unknown command: %s. supported: set, get, list, rm", cmd)
+	}
+	return nil
+}
+
+// readSecret reads the value from the terminal without echo or the first line of stdin.
+func readSecret(key string) (string, error) {
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Fprintf(os.Stderr, "Value for %s: ", key)
+		data, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		return string(data), err
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
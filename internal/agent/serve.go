@@ -0,0 +1,86 @@
+package agent
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/qiangli/ai/swarm"
+	"github.com/qiangli/ai/swarm/api"
+	"github.com/qiangli/ai/swarm/approval"
+	"github.com/qiangli/ai/swarm/log"
+	mcpcli "github.com/qiangli/ai/swarm/mcp"
+	"github.com/qiangli/ai/swarm/policy"
+	"github.com/qiangli/ai/swarm/telemetry"
+)
+
+// isServe reports whether the input is ai /serve
+func isServe(argv []string) bool {
+	return len(argv) > 0 && argv[0] == "/serve"
+}
+
+// RunServer serves the agents over the OpenAI Chat Completions API, e.g.
+//
+//	ai /serve --http localhost:8000 --agents ask,swe/*
+//
+// the model of the request is the agent: ask, swe/coder
+func RunServer(cfg *api.App, argv []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	agents := fs.String("agents", "", "comma separated agents to serve: pack, pack/sub or pack/*. all agents if empty")
+	addr := fs.String("http", "localhost:8000", "address to listen on")
+	apiKey := fs.String("api-key", "", "name of the secret required from clients as bearer token")
+	// handled by Run
+	fs.String("base", "", "base directory")
+	fs.String("session", "", "session of the conversations")
+	if err := fs.Parse(argv[1:]); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	sw, vars, err := initSwarm(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	if err := serveApprover(vars); err != nil {
+		return err
+	}
+	defer func() {
+		if err := telemetry.Shutdown(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to export traces: %v\n", err)
+		}
+	}()
+	// stop local mcp servers
+	defer mcpcli.DefaultPool.Close()
+
+	var token string
+	if *apiKey != "" {
+		if token, err = vars.Token(*apiKey); err != nil {
+			return err
+		}
+	}
+
+	handler := sw.OpenAIHandler(&swarm.OpenAIServerOptions{
+		Agents: splitList(*agents),
+		Token:  token,
+	})
+	log.GetLogger(ctx).Infof("🌐 openai compatible server listening on http://%s/v1\n", *addr)
+	return http.ListenAndServe(*addr, handler)
+}
+
+// serveApprover requests approvals through files unless a mode is configured in policy.yaml,
+// served requests can't be approved on the terminal the server was started from.
+func serveApprover(vars *api.Vars) error {
+	var cfg *api.ApprovalConfig
+	if engine, ok := vars.Policy.(*policy.Engine); ok {
+		cfg = engine.Approval()
+	}
+	approver, err := approval.NewServer(cfg, vars.Roots.Workspace.Path)
+	if err != nil {
+		return err
+	}
+	vars.Approver = approver
+	return nil
+}
@@ -0,0 +1,20 @@
+package agent
+
+import (
+	"strings"
+)
+
+// sessionCommand converts ai /session list|show|fork|delete|export [id] [--flags]
+// to the session tool, e.g. /session:show --id ID
+func sessionCommand(argv []string) []string {
+	if len(argv) < 2 || strings.HasPrefix(argv[1], "-") {
+		return append([]string{"/session:list"}, argv[1:]...)
+	}
+	input := []string{"/session:" + argv[1]}
+	rest := argv[2:]
+	if len(rest) > 0 && !strings.HasPrefix(rest[0], "-") {
+		input = append(input, "--id", rest[0])
+		rest = rest[1:]
+	}
+	return append(input, rest...)
+}
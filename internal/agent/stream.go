@@ -0,0 +1,58 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/qiangli/ai/swarm/api"
+	"github.com/qiangli/ai/swarm/log"
+)
+
+// consoleStream prints LLM deltas to the console as they arrive
+// and remembers the streamed text so the final output is not printed twice.
+type consoleStream struct {
+	ctx context.Context
+
+	mu  sync.Mutex
+	buf strings.Builder
+}
+
+func newConsoleStream(ctx context.Context) *consoleStream {
+	return &consoleStream{
+		ctx: ctx,
+	}
+}
+
+func (r *consoleStream) Handle(ev *api.StreamEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	logger := log.GetLogger(r.ctx)
+	switch ev.Type {
+	case api.StreamEventText:
+		logger.Printf("%s", ev.Text)
+		r.buf.WriteString(ev.Text)
+	case api.StreamEventToolCall:
+		// progress only, the tool runner reports the call itself
+		if ev.Name != "" {
+			logger.Infof("\n⣿ %s ", ev.Name)
+		}
+		if ev.Arguments != "" {
+			logger.Debugf("%s", ev.Arguments)
+		}
+	}
+}
+
+// Streamed returns true if the content has already been printed
+// as the tail of the streamed text.
+func (r *consoleStream) Streamed(content string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return false
+	}
+	return strings.HasSuffix(strings.TrimSpace(r.buf.String()), content)
+}
@@ -0,0 +1,35 @@
+package agent
+
+import (
+	"strings"
+)
+
+// usageCommand converts ai /usage [--today|--session [ID]|--agent NAME|--all]
+// to the usage tool, e.g. /ai:usage --scope agent --agent swe
+func usageCommand(argv []string) []string {
+	var input = []string{"/ai:usage"}
+	var scope string
+	rest := argv[1:]
+	for i := 0; i < len(rest); i++ {
+		name := strings.TrimLeft(rest[i], "-")
+		if !strings.HasPrefix(rest[i], "-") {
+			name = ""
+		}
+		switch name {
+		case "today", "all":
+			scope = name
+		case "session", "agent":
+			scope = name
+			if i+1 < len(rest) && !strings.HasPrefix(rest[i+1], "-") {
+				input = append(input, "--"+name, rest[i+1])
+				i++
+			}
+		default:
+			input = append(input, rest[i])
+		}
+	}
+	if scope == "" {
+		scope = "today"
+	}
+	return append(input, "--scope", scope)
+}
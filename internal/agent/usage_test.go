@@ -0,0 +1,25 @@
+package agent
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUsageCommand(t *testing.T) {
+	tests := []struct {
+		argv []string
+		want []string
+	}{
+		{[]string{"/usage"}, []string{"/ai:usage", "--scope", "today"}},
+		{[]string{"/usage", "--today"}, []string{"/ai:usage", "--scope", "today"}},
+		{[]string{"/usage", "--session"}, []string{"/ai:usage", "--scope", "session"}},
+		{[]string{"/usage", "--session", "s1"}, []string{"/ai:usage", "--session", "s1", "--scope", "session"}},
+		{[]string{"/usage", "--agent", "swe", "--base", "/tmp"}, []string{"/ai:usage", "--agent", "swe", "--base", "/tmp", "--scope", "agent"}},
+		{[]string{"/usage", "--all"}, []string{"/ai:usage", "--scope", "all"}},
+	}
+	for _, tc := range tests {
+		if got := usageCommand(tc.argv); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%q: got %q want %q", tc.argv, got, tc.want)
+		}
+	}
+}
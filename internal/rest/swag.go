@@ -3,10 +3,16 @@ package rest
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
 	"os"
+	"strings"
 
 	"github.com/getkin/kin-openapi/openapi2"
+	"github.com/getkin/kin-openapi/openapi2conv"
 	"github.com/getkin/kin-openapi/openapi3"
+	"gopkg.in/yaml.v3"
 )
 
 type SwagKit struct {
@@ -37,3 +43,69 @@ func (r *SwagKit) LoadV3(ctx context.Context, file string) (*openapi3.T, error)
 	}
 	return doc, nil
 }
+
+// Load reads an OpenAPI document from a http(s) url or a local file.
+// v2 (swagger) documents are converted to v3.
+func (r *SwagKit) Load(ctx context.Context, uri string) (*openapi3.T, error) {
+	var data []byte
+	if strings.HasPrefix(uri, "http://") || strings.HasPrefix(uri, "https://") {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("failed to fetch %s: %s", uri, resp.Status)
+		}
+		if data, err = io.ReadAll(resp.Body); err != nil {
+			return nil, err
+		}
+	} else {
+		file, err := expandPath(uri)
+		if err != nil {
+			return nil, err
+		}
+		if data, err = os.ReadFile(file); err != nil {
+			return nil, err
+		}
+	}
+	return r.LoadData(ctx, data)
+}
+
+// LoadData parses an OpenAPI v2 or v3 document in json or yaml.
+// v2 (swagger) documents are converted to v3.
+func (r *SwagKit) LoadData(ctx context.Context, data []byte) (*openapi3.T, error) {
+	var m map[string]any
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %v", err)
+	}
+	if _, ok := m["swagger"]; ok {
+		// openapi2 only supports json
+		input, err := json.Marshal(m)
+		if err != nil {
+			return nil, err
+		}
+		var doc2 openapi2.T
+		if err := json.Unmarshal(input, &doc2); err != nil {
+			return nil, err
+		}
+		return openapi2conv.ToV3(&doc2)
+	}
+	if _, ok := m["openapi"]; !ok {
+		return nil, fmt.Errorf("invalid OpenAPI document: missing openapi or swagger version")
+	}
+
+	loader := &openapi3.Loader{Context: ctx}
+	doc, err := loader.LoadFromData(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := doc.Validate(ctx); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
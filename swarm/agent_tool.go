@@ -18,6 +18,7 @@ import (
 	"github.com/qiangli/ai/swarm/atm"
 	"github.com/qiangli/ai/swarm/atm/conf"
 	"github.com/qiangli/ai/swarm/log"
+	"github.com/qiangli/ai/swarm/telemetry"
 )
 
 type AgentToolRunner struct {
@@ -155,6 +156,16 @@ func (r *AgentToolRunner) loadTool(tid string, args map[string]any) (*api.ToolFu
 		}
 	}
 
+	return r.findTool(tid)
+}
+
+// Resolve returns the tool of the action without running it, implementing api.ToolResolver.
+func (r *AgentToolRunner) Resolve(action string) (*api.ToolFunc, error) {
+	kit, name := api.Kitname(action).Decode()
+	return r.findTool(api.NewKitname(kit, name).ID())
+}
+
+func (r *AgentToolRunner) findTool(tid string) (*api.ToolFunc, error) {
 	// inline
 	v, ok := r.toolMap[tid]
 	if ok {
@@ -296,7 +307,7 @@ func (r *AgentToolRunner) Run(ctx context.Context, tid string, args map[string]a
 	return result, err
 }
 
-func (r *AgentToolRunner) callTool(ctx context.Context, tf *api.ToolFunc, input map[string]any) (*api.Result, error) {
+func (r *AgentToolRunner) callTool(ctx context.Context, tf *api.ToolFunc, input map[string]any) (result *api.Result, err error) {
 	var args map[string]any
 	if len(tf.Arguments) > 0 {
 		args = make(map[string]any)
@@ -342,7 +353,56 @@ func (r *AgentToolRunner) callTool(ctx context.Context, tf *api.ToolFunc, input
 		Started:   time.Now(),
 	}
 
-	result, err := r.dispatch(ctx, tf, args)
+	// command lines of bin tools are recorded as the tool name
+	spanName := tf.Kit + ":" + tf.Name
+	if tf.Type == api.ToolTypeBin {
+		if argv := conf.Argv(tf.Name); len(argv) > 0 {
+			spanName = tf.Kit + ":" + path.Base(argv[0])
+		}
+	}
+	ctx, span := telemetry.Start(ctx, "tool "+spanName,
+		telemetry.AttrToolKit.String(tf.Kit),
+		telemetry.AttrToolName.String(tf.Name),
+		telemetry.AttrAgent.String(entry.Agent),
+	)
+	defer func() {
+		telemetry.End(span, err)
+	}()
+
+	decision, err := authorize(ctx, r.vars, toolPolicyRequest(entry.Agent, tf, args))
+	entry.Policy = decision
+	if decision != "" {
+		span.SetAttributes(telemetry.AttrPolicy.String(decision))
+	}
+	if err != nil {
+		entry.Ended = time.Now()
+		entry.Error = err
+		r.vars.Log.Save(&entry)
+		return nil, err
+	}
+
+	if tf.Approval == api.ApprovalRequired {
+		outcome, approved, rejection, err := requestApproval(ctx, r.vars, entry.Agent, tf, args)
+		entry.Approval = outcome
+		span.SetAttributes(telemetry.AttrApproval.String(outcome))
+		if err != nil || outcome == api.ApprovalRejected {
+			// the reason is returned to the LLM instead of failing the call
+			var result *api.Result
+			if err == nil {
+				result = &api.Result{Value: rejection}
+			}
+			entry.Ended = time.Now()
+			entry.Error = err
+			entry.Result = result
+			r.vars.Log.Save(&entry)
+			return result, err
+		}
+		args = approved
+		entry.Arguments = args
+	}
+
+	// e.g. the response cache of ai:call_llm
+	result, err = r.dispatch(api.WithCallLogEntry(ctx, &entry), tf, args)
 
 	entry.Ended = time.Now()
 
@@ -381,6 +441,15 @@ func (r *AgentToolRunner) callTool(ctx context.Context, tf *api.ToolFunc, input
 }
 
 func (r *AgentToolRunner) dispatch(ctx context.Context, tf *api.ToolFunc, args api.ArgMap) (*api.Result, error) {
+	// shell safety check
+	if r.vars.ShellGuard != nil {
+		if script := r.shellScript(tf, args); strings.TrimSpace(script) != "" {
+			if err := r.vars.ShellGuard.Check(ctx, r.vars, r.agent, script); err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	// command
 	if tf.Type == api.ToolTypeBin {
 		out, err := atm.ExecCommand(ctx, r.vars.OS, r.vars, tf.Name, nil)
@@ -136,6 +136,11 @@ type ActionRunner interface {
 	Run(context.Context, string, map[string]any) (any, error)
 }
 
+// ToolResolver is implemented by action runners that can look up the tool of an action.
+type ToolResolver interface {
+	Resolve(string) (*ToolFunc, error)
+}
+
 type App struct {
 	// user id: auth email
 	UserID string
@@ -144,6 +149,12 @@ type App struct {
 	Base string
 
 	Input any
+
+	// id of a previous run to resume
+	Resume string
+
+	// name or id of the session to continue, a new session if empty
+	Session string
 }
 
 // type InputConfig struct {
@@ -223,6 +234,9 @@ type AppConfig struct {
 
 	Models map[string]*ModelConfig `yaml:"models" json:"models"`
 
+	// external LLM adapters
+	Adapters []*AdapterConfig `yaml:"adapters" json:"adapters"`
+
 	// The raw data for this config
 	RawContent []byte `yaml:"-" json:"-"`
 
@@ -408,6 +422,16 @@ func (g *Environment) Copy(dst map[string]any) {
 // agent/tool parameters
 type Parameters map[string]any
 
+// Properties returns the names of the declared parameters.
+func (r Parameters) Properties() []string {
+	props, _ := ToMap(r["properties"])
+	var names []string
+	for key := range props {
+		names = append(names, key)
+	}
+	return names
+}
+
 func (r Parameters) Defaults() map[string]any {
 	if len(r) == 0 {
 		return nil
@@ -10,6 +10,7 @@ const (
 	RoleSystem    = "system"
 	RoleAssistant = "assistant"
 	RoleUser      = "user"
+	RoleTool      = "tool"
 )
 
 // @pack[/sub]
@@ -183,6 +184,10 @@ type AgentConfig struct {
 	// output destinateion: console, none, file:/
 	Output string `yaml:"output" json:"output"`
 
+	// JSON schema for structured output.
+	// the validated response is available as data to templates and downstream actions
+	OutputSchema map[string]any `yaml:"output_schema" json:"output_schema"`
+
 	// tools defined in tools config
 	// kit:name | agent:pack/sub
 	Functions []string `yaml:"functions" json:"functions"`
@@ -193,6 +198,11 @@ type AgentConfig struct {
 	MaxHistory int `yaml:"max_history" json:"max_history"`
 	MaxSpan    int `yaml:"max_span" json:"max_span"`
 
+	// context window management applied before each LLM call: drop | summarize | pin | none
+	ContextStrategy string `yaml:"context_strategy" json:"context_strategy"`
+	// model set/level alias for summarizing the older history
+	ContextModel string `yaml:"context_model" json:"context_model"`
+
 	// logging: quiet | info[rmative] | verbose | trace
 	LogLevel string `yaml:"log_level" json:"log_level"`
 
@@ -252,6 +262,12 @@ func (c *AgentConfig) ToMap() map[string]any {
 	if c.MaxSpan > 0 {
 		result["max_span"] = c.MaxSpan
 	}
+	if c.ContextStrategy != "" {
+		result["context_strategy"] = c.ContextStrategy
+	}
+	if c.ContextModel != "" {
+		result["context_model"] = c.ContextModel
+	}
 	if c.LogLevel != "" {
 		result["log_level"] = c.LogLevel
 	}
@@ -285,9 +301,9 @@ const (
 	// This allows for concurrent processing of independent actions.
 	FlowTypeParallel FlowType = "parallel"
 
-	// // FlowTypeMap applies specified action(s) to each element in the input array, creating a new
-	// // array populated with the results.
-	// FlowTypeMap FlowType = "map"
+	// FlowTypeMap applies specified action(s) to each element in the input array, creating a new
+	// array populated with the results.
+	FlowTypeMap FlowType = "map"
 
 	// FlowTypeLoop executes actions repetitively in a loop. The loop runs indefinitely or can use a counter.
 	FlowTypeLoop FlowType = "loop"
@@ -295,10 +311,16 @@ const (
 	// Fallback executes actions in sequence. Return the result of the first successfully executed action, or produce an error from the final action if all actions fail.
 	FlowTypeFallback FlowType = "fallback"
 
-	// // FlowTypeReduce applies action(s) sequentially to each element of an input array, accumulating
-	// // results. It passes the result of each action as input to the next. The process returns a single
-	// // accumulated value. If at the root, an initial value is sourced from a previous agent or user query.
-	// FlowTypeReduce FlowType = "reduce"
+	// FlowTypeReduce applies action(s) sequentially to each element of an input array, accumulating
+	// results. It passes the result of each action as input to the next. The process returns a single
+	// accumulated value. If at the root, an initial value is sourced from a previous agent or user query.
+	FlowTypeReduce FlowType = "reduce"
+
+	// FlowTypeIf evaluates an expression against the arguments and runs the "then" or "else" action(s).
+	FlowTypeIf FlowType = "if"
+
+	// FlowTypeSwitch evaluates an expression against the arguments and runs the action(s) of the matching case.
+	FlowTypeSwitch FlowType = "switch"
 
 	// FlowTypeShell delegates control to a shell script using bash script syntax, enabling
 	// complex flow control scenarios driven by external scripting logic.
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"context"
+	"time"
+)
+
+// value of the approval setting of tools that are held until the user approves the call
+const ApprovalRequired = "required"
+
+// approval modes
+const (
+	// console if attached to a terminal, file otherwise
+	ApprovalAuto    = "auto"
+	ApprovalConsole = "console"
+	ApprovalFile    = "file"
+	ApprovalHttp    = "http"
+)
+
+// outcome of an approval recorded in the call log
+const (
+	ApprovalApproved = "approved"
+	ApprovalEdited   = "edited"
+	ApprovalRejected = "rejected"
+)
+
+// ApprovalConfig configures how approvals of tool calls are requested.
+type ApprovalConfig struct {
+	// auto, console, file or http
+	Mode string `yaml:"mode" json:"mode"`
+
+	// file: directory of the request and response files, defaults to <workspace>/var/approval
+	Dir string `yaml:"dir" json:"dir"`
+
+	// http: callback url the request is posted to, the response is the decision
+	Url string `yaml:"url" json:"url"`
+	// optional bearer token, environment variables are expanded
+	Token string `yaml:"token" json:"token"`
+
+	// seconds to wait for the decision, defaults to 600
+	Timeout int `yaml:"timeout" json:"timeout"`
+}
+
+// ApprovalRequest is the tool call proposed by the LLM.
+type ApprovalRequest struct {
+	ID    string `json:"id"`
+	Agent string `json:"agent"`
+
+	Kit       string         `json:"kit"`
+	Name      string         `json:"name"`
+	Arguments map[string]any `json:"arguments"`
+
+	// question of confirmations required by policy ask rules or the shell guard, approved or not
+	Prompt string `json:"prompt,omitempty"`
+
+	Created time.Time `json:"created"`
+}
+
+type ApprovalDecision struct {
+	Approved bool `json:"approved"`
+
+	// reason of the rejection fed back to the LLM
+	Reason string `json:"reason,omitempty"`
+
+	// optional arguments edited by the user replacing the proposed ones
+	Arguments map[string]any `json:"arguments,omitempty"`
+}
+
+// Approver asks the user to approve, reject or edit a tool call.
+type Approver interface {
+	Approve(context.Context, *ApprovalRequest) (*ApprovalDecision, error)
+}
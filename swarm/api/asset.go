@@ -19,6 +19,43 @@ type DHNTConfig struct {
 
 	Blob   *ResourceConfig   `json:"blob"`
 	Assets []*ResourceConfig `json:"assets"`
+
+	// optional OpenTelemetry tracing
+	Trace *TraceConfig `json:"trace"`
+
+	// optional secret vault and helper
+	Secrets *SecretsConfig `json:"secrets"`
+}
+
+// SecretsConfig configures the lookup of secrets:
+// the vault, the *_API_KEY environment variables and the helper, in that order.
+type SecretsConfig struct {
+	// encrypted vault file, defaults to <base>/vault.json
+	Vault string `json:"vault"`
+
+	// command run with the argument "get" to look up secrets not found otherwise,
+	// similar to git credential helpers.
+	// it reads owner=<owner> and key=<key> lines on stdin and writes value=<secret> on stdout.
+	Helper string `json:"helper"`
+}
+
+// trace exporters
+const (
+	TraceExporterOtlp = "otlp"
+	TraceExporterFile = "file"
+)
+
+// TraceConfig configures the export of the spans of agents, tools, LLM calls and flow steps.
+type TraceConfig struct {
+	// otlp or file, tracing is disabled if empty
+	Exporter string `json:"exporter"`
+
+	// otlp: collector url, e.g. http://localhost:4318
+	// defaults to the OTEL_EXPORTER_OTLP_ENDPOINT environment variable
+	Endpoint string `json:"endpoint"`
+
+	// file: OTLP JSON lines, defaults to <workspace>/var/log/trace/session_<id>.jsonl
+	File string `json:"file"`
 }
 
 // Return root paths
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+)
+
+const DefaultCacheTTL = 24 * time.Hour
+
+// LLM response cache
+// content addressed by the normalized request, see [Request.CacheKey]
+type ResponseCache interface {
+	// Get returns the cached result for key if it is not older than ttl.
+	// NotFoundError is returned on a miss.
+	Get(key string, ttl time.Duration) (*Result, error)
+	Put(key string, result *Result) error
+}
+
+// arguments that change between calls without affecting the response
+var cacheSkipArgs = []string{
+	"result",
+	"error",
+	"history",
+	"stream",
+	"cache",
+	"cache_ttl",
+	"log_level",
+	"session_budget",
+	"daily_budget",
+	"output_retries",
+}
+
+// CacheKey returns a stable hash of the request content that determines the LLM response:
+// model, messages, tools and the scalar arguments.
+// Volatile fields such as message IDs and timestamps are excluded.
+func (r *Request) CacheKey() (string, error) {
+	type message struct {
+		Role        string `json:"role"`
+		ContentType string `json:"content_type,omitempty"`
+		Content     string `json:"content"`
+	}
+	type tool struct {
+		ID          string         `json:"id"`
+		Description string         `json:"description"`
+		Parameters  map[string]any `json:"parameters"`
+	}
+	var key struct {
+		Provider  string         `json:"provider"`
+		BaseUrl   string         `json:"base_url"`
+		Model     string         `json:"model"`
+		Prompt    string         `json:"prompt"`
+		Query     string         `json:"query"`
+		Messages  []message      `json:"messages"`
+		Tools     []tool         `json:"tools"`
+		Arguments map[string]any `json:"arguments"`
+	}
+
+	if r.Model != nil {
+		key.Provider = r.Model.Provider
+		key.BaseUrl = r.Model.BaseUrl
+		key.Model = r.Model.Model
+	}
+	key.Prompt = r.Prompt
+	key.Query = r.Query
+	for _, v := range r.Messages {
+		key.Messages = append(key.Messages, message{
+			Role:        v.Role,
+			ContentType: v.ContentType,
+			Content:     v.Content,
+		})
+	}
+	for _, v := range r.Tools {
+		key.Tools = append(key.Tools, tool{
+			ID:          v.ID(),
+			Description: v.Description,
+			Parameters:  v.Parameters,
+		})
+	}
+	// tool order is not significant
+	slices.SortFunc(key.Tools, func(a, b tool) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+
+	key.Arguments = make(map[string]any)
+	for k, v := range r.Arguments {
+		if slices.Contains(cacheSkipArgs, k) {
+			continue
+		}
+		// structured output
+		if k == "output_schema" {
+			key.Arguments[k] = v
+			continue
+		}
+		switch v.(type) {
+		case string, bool, int, int32, int64, float32, float64:
+			key.Arguments[k] = v
+		}
+	}
+
+	// map keys are sorted by json
+	b, err := json.Marshal(key)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRequestCacheKey(t *testing.T) {
+	newReq := func(id string, args map[string]any) *Request {
+		return &Request{
+			Model: &Model{Provider: "openai", Model: "gpt-5-nano"},
+			Messages: []*Message{
+				{ID: id, Created: time.Now(), Role: RoleUser, Content: "hi"},
+			},
+			Tools: []*ToolFunc{
+				{Kit: "fs", Name: "read_file"},
+				{Kit: "fs", Name: "list_directory"},
+			},
+			Arguments: args,
+		}
+	}
+
+	k1, err := newReq("a", map[string]any{"temperature": 0.2, "result": "x"}).CacheKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// message ids, timestamps and volatile args are ignored
+	req := newReq("b", map[string]any{"temperature": 0.2, "result": "y"})
+	req.Tools[0], req.Tools[1] = req.Tools[1], req.Tools[0]
+	k2, _ := req.CacheKey()
+	if k1 != k2 {
+		t.Errorf("expected same key: %s %s", k1, k2)
+	}
+
+	k3, _ := newReq("a", map[string]any{"temperature": 0.9}).CacheKey()
+	if k1 == k3 {
+		t.Errorf("expected different key for different arguments")
+	}
+}
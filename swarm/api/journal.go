@@ -0,0 +1,143 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// run and step status
+const (
+	RunStatusRunning   = "running"
+	RunStatusCompleted = "completed"
+	RunStatusFailed    = "failed"
+)
+
+// Run is one invocation of ai recorded in the run journal.
+type Run struct {
+	ID      string    `json:"id"`
+	Session string    `json:"session"`
+	Input   []string  `json:"input"`
+	Status  string    `json:"status"`
+	Error   string    `json:"error,omitempty"`
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+}
+
+// RunStep is the execution of one action within a flow.
+// Key identifies the position of the step in the flow tree, e.g. /sequence#0/1
+// is the second action of the first sequence flow of the run.
+type RunStep struct {
+	RunID  string `json:"run_id"`
+	Key    string `json:"key"`
+	Action string `json:"action"`
+	// json encoded arguments
+	Input  string  `json:"input,omitempty"`
+	Output *Result `json:"output,omitempty"`
+	Status string  `json:"status"`
+	Error  string  `json:"error,omitempty"`
+
+	Started time.Time `json:"started"`
+	Ended   time.Time `json:"ended"`
+}
+
+// RunJournal persists runs and their flow steps so a failed run can be resumed.
+type RunJournal interface {
+	// insert or update the run
+	SaveRun(*Run) error
+	GetRun(id string) (*Run, error)
+	// most recent first
+	ListRuns(limit int) ([]*Run, error)
+
+	// insert or update the step by run id and key
+	SaveStep(*RunStep) error
+	// ordered by start time
+	ListSteps(runID string) ([]*RunStep, error)
+}
+
+const SwarmFlowContextKey ContextKey = "swarm_flow"
+
+// FlowRun tracks the flow steps of the current run.
+// When resuming, the steps completed by the previous attempt are skipped.
+type FlowRun struct {
+	ID      string
+	Journal RunJournal
+
+	// completed steps of the previous attempt by key
+	done map[string]*RunStep
+
+	mu sync.Mutex
+	// number of flows started per parent scope
+	counters map[string]int
+}
+
+// NewFlowRun returns the tracker of run id. If resume is true,
+// the completed steps are loaded from the journal.
+func NewFlowRun(id string, journal RunJournal, resume bool) (*FlowRun, error) {
+	run := &FlowRun{
+		ID:       id,
+		Journal:  journal,
+		done:     make(map[string]*RunStep),
+		counters: make(map[string]int),
+	}
+	if !resume {
+		return run, nil
+	}
+	steps, err := journal.ListSteps(id)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load steps of run %s: %v", id, err)
+	}
+	for _, v := range steps {
+		if v.Status == RunStatusCompleted {
+			run.done[v.Key] = v
+		}
+	}
+	return run, nil
+}
+
+// Scope returns the key of the next flow started within the parent scope.
+// The keys are stable across attempts as long as the flows are started in the same order.
+func (r *FlowRun) Scope(parent, name string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := r.counters[parent]
+	r.counters[parent] = n + 1
+	return fmt.Sprintf("%s/%s#%d", parent, name, n)
+}
+
+// Completed returns the step completed by the previous attempt if the action matches.
+func (r *FlowRun) Completed(key, action string) *RunStep {
+	if v, ok := r.done[key]; ok && v.Action == action {
+		return v
+	}
+	return nil
+}
+
+type flowScope struct {
+	run  *FlowRun
+	path string
+}
+
+// WithFlowRun returns a copy of ctx carrying the flow run.
+func WithFlowRun(ctx context.Context, run *FlowRun) context.Context {
+	return context.WithValue(ctx, SwarmFlowContextKey, &flowScope{run: run})
+}
+
+// WithFlowPath returns a copy of ctx with the current scope of the flow run set to path.
+func WithFlowPath(ctx context.Context, path string) context.Context {
+	run, _ := GetFlowRun(ctx)
+	if run == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, SwarmFlowContextKey, &flowScope{run: run, path: path})
+}
+
+// GetFlowRun returns the flow run of ctx and the current scope; nil if runs are not journaled.
+func GetFlowRun(ctx context.Context) (*FlowRun, string) {
+	if v, ok := ctx.Value(SwarmFlowContextKey).(*flowScope); ok {
+		return v.run, v.path
+	}
+	return nil, ""
+}
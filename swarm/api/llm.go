@@ -17,6 +17,118 @@ type LLMAdapter interface {
 	Call(context.Context, *Request) (*Response, error)
 }
 
+// ContextWindow fits the messages to the context window of the model.
+// It is applied before every call to the provider including the turns of the tool calling loop.
+type ContextWindow interface {
+	Fit(context.Context, []*Message) ([]*Message, error)
+}
+
 type AdapterRegistry interface {
 	Get(key string) (LLMAdapter, error)
+
+	// Register adds or replaces the adapter for the key.
+	// built-in adapters can not be replaced.
+	Register(key string, adapter LLMAdapter) error
+}
+
+// AdapterConfig declares an external LLM adapter in agent yaml.
+// Exactly one of command or url is required.
+//
+// The adapter speaks a small JSON protocol: the request is
+// written as a JSON encoded Request and a JSON encoded Response is expected back,
+// on stdin/stdout for a command and as the POST body for an HTTP endpoint.
+type AdapterConfig struct {
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description" json:"description"`
+
+	// external executable and its arguments
+	Command string   `yaml:"command" json:"command"`
+	Args    []string `yaml:"args" json:"args"`
+
+	// HTTP endpoint
+	Url string `yaml:"url" json:"url"`
+
+	// api token lookup key - sent as bearer token to the HTTP endpoint
+	ApiKey string `yaml:"api_key" json:"api_key"`
+
+	// seconds
+	Timeout int `yaml:"timeout" json:"timeout"`
+}
+
+// Streaming
+
+type StreamEventType string
+
+const (
+	// assistant text delta
+	StreamEventText StreamEventType = "text"
+
+	// tool call fragment
+	// the first fragment of a call carries the id and name, the rest partial json arguments
+	StreamEventToolCall StreamEventType = "tool_call"
+
+	// result of a tool call requested by the LLM
+	StreamEventToolResult StreamEventType = "tool_result"
+)
+
+type StreamEvent struct {
+	Type StreamEventType `json:"type"`
+
+	// text delta
+	Text string `json:"text,omitempty"`
+
+	// tool call fragment or result
+	CallID    string `json:"call_id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+}
+
+// StreamHandler receives the incremental output of a streaming LLM call.
+// It is called from the goroutine making the call and must not block.
+type StreamHandler func(*StreamEvent)
+
+// LLMStreamAdapter is the streaming variant of LLMAdapter.
+// Deltas are delivered to the handler as they arrive;
+// the final response including token usage is returned as with Call.
+type LLMStreamAdapter interface {
+	LLMAdapter
+
+	Stream(context.Context, *Request, StreamHandler) (*Response, error)
+}
+
+const SwarmStreamContextKey ContextKey = "swarm_stream"
+
+// WithStreamHandler returns a copy of ctx carrying the stream handler.
+// LLM calls made with the context are streamed if the adapter supports it.
+func WithStreamHandler(ctx context.Context, h StreamHandler) context.Context {
+	return context.WithValue(ctx, SwarmStreamContextKey, h)
+}
+
+// WithoutStreamHandler returns a copy of ctx without the stream handler.
+// Only the agent run by the user is streamed, not the agents, flow steps and tools it calls.
+func WithoutStreamHandler(ctx context.Context) context.Context {
+	return context.WithValue(ctx, SwarmStreamContextKey, StreamHandler(nil))
+}
+
+// GetStreamHandler returns the stream handler of ctx or nil.
+func GetStreamHandler(ctx context.Context) StreamHandler {
+	if h, ok := ctx.Value(SwarmStreamContextKey).(StreamHandler); ok {
+		return h
+	}
+	return nil
+}
+
+// StreamToolResult reports the result of a tool call requested by the LLM
+// to the stream handler of ctx if any.
+func StreamToolResult(ctx context.Context, callID, name string, result *Result) {
+	h := GetStreamHandler(ctx)
+	if h == nil || result == nil {
+		return
+	}
+	h(&StreamEvent{
+		Type:   StreamEventToolResult,
+		CallID: callID,
+		Name:   name,
+		Text:   result.Value,
+	})
 }
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
@@ -51,6 +52,9 @@ func ToLogLevel(level any) LogLevel {
 }
 
 type CallLogEntry struct {
+	// assigned by stores that can be queried
+	ID int64 `json:"id,omitempty"`
+
 	// tool call
 	Kit       string         `json:"kit"`
 	Name      string         `json:"name"`
@@ -67,6 +71,30 @@ type CallLogEntry struct {
 
 	Started time.Time `json:"started"`
 	Ended   time.Time `json:"ended"`
+
+	// LLM response cache: hit or miss
+	Cache string `json:"cache,omitempty"`
+
+	// policy decision: allow, deny or ask (approved by the user)
+	Policy string `json:"policy,omitempty"`
+
+	// approval of the user: approved, edited or rejected
+	Approval string `json:"approval,omitempty"`
+}
+
+const SwarmCallLogContextKey ContextKey = "swarm_call_log"
+
+// WithCallLogEntry returns a copy of ctx carrying the entry of the tool call being run.
+func WithCallLogEntry(ctx context.Context, entry *CallLogEntry) context.Context {
+	return context.WithValue(ctx, SwarmCallLogContextKey, entry)
+}
+
+// GetCallLogEntry returns the entry of the tool call being run or nil.
+func GetCallLogEntry(ctx context.Context) *CallLogEntry {
+	if v, ok := ctx.Value(SwarmCallLogContextKey).(*CallLogEntry); ok {
+		return v
+	}
+	return nil
 }
 
 type CallLogger interface {
@@ -74,6 +102,53 @@ type CallLogger interface {
 	Save(*CallLogEntry)
 }
 
+// call status in call log queries
+const (
+	CallStatusOK    = "ok"
+	CallStatusError = "error"
+)
+
+// CallLogQuery filters recorded tool calls, empty conditions match all.
+type CallLogQuery struct {
+	// glob patterns
+	Agent string
+	Kit   string
+	Name  string
+
+	// ok or error
+	Status string
+
+	// time range of the start of the calls
+	Since time.Time
+	Until time.Time
+
+	Limit int
+}
+
+// CallLogStats is the latency summary of the calls of a tool.
+type CallLogStats struct {
+	Kit    string
+	Name   string
+	Count  int
+	Errors int
+
+	Mean time.Duration
+	P50  time.Duration
+	P95  time.Duration
+	Max  time.Duration
+}
+
+// CallLogStore is a call logger that can be queried.
+type CallLogStore interface {
+	CallLogger
+
+	// Search returns the matching calls, most recent first.
+	Search(*CallLogQuery) ([]*CallLogEntry, error)
+	Get(id int64) (*CallLogEntry, error)
+	// Stats returns the latency summary of the matching calls by tool.
+	Stats(*CallLogQuery) ([]*CallLogStats, error)
+}
+
 func formatLineage(agent *Agent) string {
 	var tracing func(*Agent) string
 	tracing = func(a *Agent) string {
@@ -99,6 +174,8 @@ func FormatRequestLine(req *Request, adapter string, maxTurns, tries int) string
 		emoji = "Ⓞ"
 	case "xai":
 		emoji = "Ⓧ"
+	case "ollama", "openai-compatible":
+		emoji = "Ⓛ"
 	default:
 		emoji = "???"
 	}
@@ -30,9 +30,14 @@ type Message struct {
 	ContentType string `json:"content_type"`
 	Content     string `json:"content"`
 
-	// system | assistant | user
+	// system | assistant | user | tool
 	Role string `json:"role"`
 
+	// tool calls requested by the assistant
+	ToolCalls []*ToolCall `json:"tool_calls,omitempty"`
+	// the tool call this tool message is the result of
+	ToolCallID string `json:"tool_call_id,omitempty"`
+
 	// user/provider
 	Sender string `json:"sender"`
 
@@ -68,6 +73,9 @@ type Request struct {
 
 	Runner ActionRunner `json:"-"`
 
+	// context window management, messages are sent as is if nil
+	Window ContextWindow `json:"-"`
+
 	// get api token for LLM model
 	Token func() string `json:"-"`
 
@@ -162,12 +170,17 @@ func (r *Request) MemOption() *MemOption {
 type Response struct {
 	// A list of message objects generated during the conversation
 	// with a sender field indicating which Agent the message originated from.
-	// Messages []*Message `json:"messages"`
+	// The assistant tool call and tool result messages exchanged
+	// before the final result, in order.
+	Messages []*Message `json:"messages"`
 
 	// The last agent to handle a message
 	Agent *Agent `json:"agent"`
 
 	Result *Result `json:"result"`
+
+	// response cache: hit or miss, empty if not used
+	Cache string `json:"cache,omitempty"`
 }
 
 // Result encapsulates the possible return values for agent/function.
@@ -194,6 +207,13 @@ type Result struct {
 	OutputTokens int64 `json:"output_tokens"`
 	// The total number of tokens used.
 	TotalTokens int64 `json:"total_tokens"`
+
+	// structured output validated against the agent output_schema
+	Data any `json:"data,omitempty"`
+
+	// the model that answered
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
 }
 
 func (r *Result) String() string {
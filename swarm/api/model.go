@@ -79,6 +79,16 @@ type Model struct {
 
 	// api token lookup key
 	ApiKey string `json:"api_key"`
+
+	// relative preference for the weighted selection strategy
+	Weight int `json:"weight,omitempty"`
+
+	// price in USD per million tokens
+	InputCost  float64 `json:"input_cost,omitempty"`
+	OutputCost float64 `json:"output_cost,omitempty"`
+
+	// max input and output tokens
+	ContextWindow int `json:"context_window,omitempty"`
 }
 
 func (r *Model) String() string {
@@ -97,4 +107,36 @@ type ModelConfig struct {
 	ApiKey string `yaml:"api_key" json:"api_key"`
 
 	Description string `json:"description"`
+
+	// relative preference for the weighted selection strategy
+	Weight int `yaml:"weight" json:"weight"`
+
+	// price in USD per million tokens
+	InputCost  float64 `yaml:"input_cost" json:"input_cost"`
+	OutputCost float64 `yaml:"output_cost" json:"output_cost"`
+
+	// max input and output tokens
+	ContextWindow int `yaml:"context_window" json:"context_window"`
+}
+
+// Model selection strategies for trying a list of models in turn.
+const (
+	// as listed
+	StrategyOrdered = "ordered"
+	// random, biased by model weight
+	StrategyWeighted = "weighted"
+	// lowest price first, models without pricing last
+	StrategyCheapest = "cheapest"
+	// uniformly random
+	StrategyRandom = "random"
+)
+
+// ModelHealth tracks the failures of model providers across calls
+// so that a provider known to be failing is tried last.
+type ModelHealth interface {
+	// Allow reports whether the provider of the model is available.
+	Allow(*Model) bool
+
+	Success(*Model)
+	Failure(*Model, error)
 }
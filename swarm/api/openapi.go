@@ -0,0 +1,33 @@
+package api
+
+// locations of OpenAPI parameters
+const (
+	ParamInPath   = "path"
+	ParamInQuery  = "query"
+	ParamInHeader = "header"
+)
+
+// name of the tool argument holding the request body of openapi tools
+const OpenAPIBodyArg = "body"
+
+// OpenAPIOperation describes the http request of a tool generated from an OpenAPI operation.
+type OpenAPIOperation struct {
+	Method string `json:"method"`
+
+	// path template, e.g. /pets/{id}
+	Path string `json:"path"`
+
+	BaseUrl string `json:"base_url"`
+
+	// parameter name to location: path, query or header
+	Params map[string]string `json:"params"`
+
+	// media type of the request body, empty if the operation has no body
+	ContentType string `json:"content_type"`
+
+	// api token lookup key resolved with Vars.Token
+	ApiKey string `json:"api_key"`
+
+	// header of the api token, sent as Authorization: Bearer <token> if empty
+	AuthHeader string `json:"auth_header"`
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"context"
+)
+
+// policy actions
+const (
+	PolicyAllow = "allow"
+	PolicyDeny  = "deny"
+	PolicyAsk   = "ask"
+)
+
+// pseudo kit of shell commands in policy requests
+const PolicyKitBin = "bin"
+
+// PolicyRule matches tool calls or shell commands.
+// All specified conditions must match, empty conditions match all.
+type PolicyRule struct {
+	Name string `yaml:"name" json:"name"`
+
+	// allow, deny or ask
+	Action string `yaml:"action" json:"action"`
+
+	// glob patterns of the kit and the tool name, e.g. fs, write_*
+	// shell commands belong to the kit "bin" with the command as the tool name.
+	Kit  string `yaml:"kit" json:"kit"`
+	Tool string `yaml:"tool" json:"tool"`
+
+	// regular expressions matched against the string form of the arguments by name
+	Args map[string]string `yaml:"args" json:"args"`
+
+	// shell commands only
+	// glob pattern of the base name of the command, e.g. rm
+	Command string `yaml:"command" json:"command"`
+	// regular expression matched against the command line
+	Argv string `yaml:"argv" json:"argv"`
+
+	// shown when the call is denied or confirmation is asked
+	Reason string `yaml:"reason" json:"reason"`
+}
+
+// PolicyConfig is the declarative policy loaded from policy.yaml.
+type PolicyConfig struct {
+	// action if no rule matches, defaults to allow
+	Default string `yaml:"default" json:"default"`
+
+	// evaluated in order, the first matching rule decides
+	Rules []*PolicyRule `yaml:"rules" json:"rules"`
+
+	// optional safety check of shell commands before they are run
+	ShellGuard *ShellGuardConfig `yaml:"shell_guard" json:"shell_guard"`
+
+	// how approvals of tools declaring approval: required are requested
+	Approval *ApprovalConfig `yaml:"approval" json:"approval"`
+}
+
+// shell guard modes
+const (
+	ShellGuardOff  = "off"
+	ShellGuardAsk  = "ask"
+	ShellGuardDeny = "deny"
+)
+
+// ShellGuardConfig configures the classification of the commands run by sh:bash, sh:exec and /bin/*.
+// Commands not known to be safe are classified by the LLM;
+// unsafe commands require confirmation (ask) or are blocked (deny).
+type ShellGuardConfig struct {
+	// off, ask or deny
+	Mode string `yaml:"mode" json:"mode"`
+
+	// additional commands considered safe with any arguments
+	Allow []string `yaml:"allow" json:"allow"`
+
+	// model alias for the classification, defaults to the model of the agent
+	Model string `yaml:"model" json:"model"`
+}
+
+// ShellGuard checks shell scripts before they are run.
+type ShellGuard interface {
+	// Check returns an error if the script must not be run.
+	Check(ctx context.Context, vars *Vars, agent *Agent, script string) error
+}
+
+// PolicyRequest describes a tool call or shell command to authorize.
+type PolicyRequest struct {
+	Agent string
+
+	Kit  string
+	Name string
+	Args map[string]any
+
+	// command line of shell commands
+	Argv []string
+}
+
+type PolicyDecision struct {
+	Action string
+	// name of the matched rule, empty for the default action
+	Rule   string
+	Reason string
+}
+
+// Policy authorizes tool calls and shell commands.
+type Policy interface {
+	Evaluate(*PolicyRequest) *PolicyDecision
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// DefaultOutputRetries is the number of times the LLM is asked again
+// if its response does not conform to the output schema.
+const DefaultOutputRetries = 2
+
+// OutputSchema returns the JSON schema declared with output_schema
+// either as an object or a JSON string; nil if none.
+func OutputSchema(args map[string]any) (map[string]any, error) {
+	v, ok := args["output_schema"]
+	if !ok || v == nil {
+		return nil, nil
+	}
+	switch vt := v.(type) {
+	case map[string]any:
+		if len(vt) == 0 {
+			return nil, nil
+		}
+		return vt, nil
+	case string:
+		if strings.TrimSpace(vt) == "" {
+			return nil, nil
+		}
+		var m map[string]any
+		if err := json.Unmarshal([]byte(vt), &m); err != nil {
+			return nil, fmt.Errorf("invalid output_schema: %v", err)
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("invalid output_schema: %T", v)
+}
+
+// ParseOutput decodes the JSON value in the LLM response and validates it against the schema.
+// A markdown code fence around the JSON is tolerated.
+func ParseOutput(schema map[string]any, text string) (any, error) {
+	resolved, err := resolveSchema(schema)
+	if err != nil {
+		return nil, err
+	}
+	var data any
+	if err := json.Unmarshal([]byte(trimCodeFence(text)), &data); err != nil {
+		return nil, fmt.Errorf("response is not valid JSON: %v", err)
+	}
+	if err := resolved.Validate(data); err != nil {
+		return nil, fmt.Errorf("response does not match the output schema: %v", err)
+	}
+	return data, nil
+}
+
+func resolveSchema(schema map[string]any) (*jsonschema.Resolved, error) {
+	// yaml/json map to typed schema
+	b, err := json.Marshal(schema)
+	if err != nil {
+		return nil, fmt.Errorf("invalid output_schema: %v", err)
+	}
+	var s jsonschema.Schema
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, fmt.Errorf("invalid output_schema: %v", err)
+	}
+	resolved, err := s.Resolve(nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid output_schema: %v", err)
+	}
+	return resolved, nil
+}
+
+func trimCodeFence(s string) string {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "```") {
+		return s
+	}
+	// drop ```json line
+	if i := strings.Index(s, "\n"); i >= 0 {
+		s = s[i+1:]
+	} else {
+		return ""
+	}
+	s = strings.TrimSpace(s)
+	return strings.TrimSpace(strings.TrimSuffix(s, "```"))
+}
+
+// SchemaInstruction is appended to the system prompt for providers
+// without native structured output.
+func SchemaInstruction(schema map[string]any) string {
+	b, _ := json.MarshalIndent(schema, "", "  ")
+	return fmt.Sprintf("Respond only with a JSON value that conforms to the following JSON schema, without any explanation or markdown:\n\n%s\n", string(b))
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"testing"
+)
+
+func TestParseOutput(t *testing.T) {
+	schema, err := OutputSchema(map[string]any{
+		"output_schema": `{"type":"object","properties":{"name":{"type":"string"},"age":{"type":"integer"}},"required":["name"]}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		text  string
+		valid bool
+	}{
+		{`{"name":"ai","age":3}`, true},
+		{"```json\n{\"name\":\"ai\"}\n```", true},
+		{`{"age":3}`, false},
+		{`{"name":1}`, false},
+		{`name: ai`, false},
+	}
+	for _, tt := range tests {
+		data, err := ParseOutput(schema, tt.text)
+		if (err == nil) != tt.valid {
+			t.Errorf("%q: valid %v, got error %v", tt.text, tt.valid, err)
+			continue
+		}
+		if tt.valid {
+			m, ok := data.(map[string]any)
+			if !ok || m["name"] != "ai" {
+				t.Errorf("%q: unexpected data %v", tt.text, data)
+			}
+		}
+	}
+
+	if v, err := OutputSchema(map[string]any{}); v != nil || err != nil {
+		t.Errorf("expected no schema, got %v %v", v, err)
+	}
+	if _, err := OutputSchema(map[string]any{"output_schema": "{"}); err == nil {
+		t.Errorf("expected error for invalid schema")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/fs"
+	"regexp"
 	"strings"
 	"time"
 )
@@ -36,6 +37,9 @@ type MemOption struct {
 	MaxSpan    int
 	Offset     int
 	Roles      []string
+
+	// only the messages of the session regardless of the span if not empty
+	Session SessionID
 }
 
 func (r *MemOption) String() string {
@@ -43,6 +47,9 @@ func (r *MemOption) String() string {
 	if len(r.Roles) > 0 {
 		roles = strings.Join(r.Roles, ",")
 	}
+	if r.Session != "" {
+		return fmt.Sprintf("session: %v max_history: %v offset: %v roles: [%v]", r.Session, r.MaxHistory, r.Offset, roles)
+	}
 	return fmt.Sprintf("max_history: %v offset: %v max_span: %v roles: [%v]", r.MaxHistory, r.Offset, r.MaxSpan, roles)
 }
 
@@ -52,6 +59,67 @@ type MemStore interface {
 	Get(string) (*Message, error)
 }
 
+// SessionStore is implemented by memory stores that can list and manage
+// the conversations by session.
+type SessionStore interface {
+	// sessions, most recently updated first
+	Sessions() ([]*SessionInfo, error)
+
+	// all messages of the session, oldest first
+	LoadSession(SessionID) ([]*Message, error)
+
+	DeleteSession(SessionID) error
+}
+
+type SearchOption struct {
+	// keywords matched against the message content
+	Query string
+	Limit int
+
+	// optional filters
+	Session SessionID
+	Agent   string
+	Roles   []string
+}
+
+func (r *SearchOption) String() string {
+	var v = fmt.Sprintf("query: %q limit: %v", r.Query, r.Limit)
+	if r.Session != "" {
+		v += fmt.Sprintf(" session: %v", r.Session)
+	}
+	if r.Agent != "" {
+		v += fmt.Sprintf(" agent: %v", r.Agent)
+	}
+	if len(r.Roles) > 0 {
+		v += fmt.Sprintf(" roles: [%v]", strings.Join(r.Roles, ","))
+	}
+	return v
+}
+
+// SearchStore is implemented by memory stores with full text search
+// over the message content.
+type SearchStore interface {
+	// matching messages, most relevant first
+	Search(*SearchOption) ([]*Message, error)
+}
+
+var sessionPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ValidSession reports whether the name of a session is safe to use in file names.
+func ValidSession(s string) bool {
+	return len(s) <= 128 && sessionPattern.MatchString(s)
+}
+
+type SessionInfo struct {
+	ID       SessionID `json:"id"`
+	Messages int       `json:"messages"`
+	Created  time.Time `json:"created"`
+	Updated  time.Time `json:"updated"`
+
+	// first user message
+	Title string `json:"title"`
+}
+
 // Resource Store
 type DirEntry = fs.DirEntry
 
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// UsageEntry records the tokens and estimated cost of one LLM call.
+type UsageEntry struct {
+	Time time.Time `json:"time"`
+
+	Session string `json:"session"`
+	// agent packname
+	Agent string `json:"agent"`
+
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+
+	InputTokens  int64 `json:"input_tokens"`
+	OutputTokens int64 `json:"output_tokens"`
+	TotalTokens  int64 `json:"total_tokens"`
+
+	// estimated in USD, zero if the model has no pricing
+	Cost float64 `json:"cost"`
+}
+
+// UsageFilter selects ledger entries, zero values match all.
+type UsageFilter struct {
+	Since   time.Time
+	Session string
+	Agent   string
+}
+
+func (r *UsageFilter) Match(e *UsageEntry) bool {
+	if !r.Since.IsZero() && e.Time.Before(r.Since) {
+		return false
+	}
+	if r.Session != "" && r.Session != e.Session {
+		return false
+	}
+	if r.Agent != "" && r.Agent != e.Agent {
+		return false
+	}
+	return true
+}
+
+// UsageLedger accumulates the token usage of LLM calls.
+type UsageLedger interface {
+	Record(*UsageEntry) error
+	Query(*UsageFilter) ([]*UsageEntry, error)
+}
+
+// Cost returns the estimated price in USD for the tokens.
+func (r *Model) Cost(input, output int64) float64 {
+	return (float64(input)*r.InputCost + float64(output)*r.OutputCost) / 1_000_000
+}
+
+// UsageTotal is the sum of the usage of a model.
+type UsageTotal struct {
+	Provider string
+	Model    string
+
+	Calls        int
+	InputTokens  int64
+	OutputTokens int64
+	TotalTokens  int64
+	Cost         float64
+}
+
+// SummarizeUsage totals the entries per provider/model, most expensive first.
+// The last item is the grand total.
+func SummarizeUsage(entries []*UsageEntry) []*UsageTotal {
+	var all = &UsageTotal{Provider: "*", Model: "*"}
+	var models = make(map[string]*UsageTotal)
+	add := func(t *UsageTotal, e *UsageEntry) {
+		t.Calls++
+		t.InputTokens += e.InputTokens
+		t.OutputTokens += e.OutputTokens
+		t.TotalTokens += e.TotalTokens
+		t.Cost += e.Cost
+	}
+	for _, e := range entries {
+		key := e.Provider + "/" + e.Model
+		t, ok := models[key]
+		if !ok {
+			t = &UsageTotal{Provider: e.Provider, Model: e.Model}
+			models[key] = t
+		}
+		add(t, e)
+		add(all, e)
+	}
+	var list []*UsageTotal
+	for _, v := range models {
+		list = append(list, v)
+	}
+	slices.SortFunc(list, func(a, b *UsageTotal) int {
+		if c := cmp.Compare(b.Cost, a.Cost); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Provider+"/"+a.Model, b.Provider+"/"+b.Model)
+	})
+	return append(list, all)
+}
+
+// FormatUsage renders the totals as a text table.
+func FormatUsage(totals []*UsageTotal) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%-40s %6s %10s %10s %10s %10s\n", "MODEL", "CALLS", "INPUT", "OUTPUT", "TOTAL", "COST($)")
+	for _, t := range totals {
+		name := t.Provider + "/" + t.Model
+		if t.Provider == "*" {
+			name = "total"
+		}
+		fmt.Fprintf(&sb, "%-40s %6d %10d %10d %10d %10.4f\n", name, t.Calls, t.InputTokens, t.OutputTokens, t.TotalTokens, t.Cost)
+	}
+	return sb.String()
+}
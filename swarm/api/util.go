@@ -70,6 +70,29 @@ func GetIntProp(key string, props map[string]any) (int, error) {
 	}
 }
 
+func GetFloatProp(key string, props map[string]any) (float64, error) {
+	val, ok := props[key]
+	if !ok {
+		if IsRequired(key, props) {
+			return 0, fmt.Errorf("missing property: %s", key)
+		}
+		return 0, nil
+	}
+	switch v := val.(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	default:
+		s := fmt.Sprintf("%v", val)
+		return strconv.ParseFloat(s, 64)
+	}
+}
+
 func GetArrayProp(key string, props map[string]any) ([]string, error) {
 	val, ok := props[key]
 	if !ok {
@@ -155,6 +178,7 @@ func ToResult(data any) *Result {
 		return &Result{
 			MimeType: v.MimeType,
 			Value:    MimeToString(v.MimeType, v.Value),
+			Data:     v.Data,
 		}
 	}
 	if v, ok := data.(*Blob); ok {
@@ -26,8 +26,11 @@ type Vars struct {
 	Base string
 
 	SessionID SessionID
-	User      *User
-	Input     any
+	// the session was chosen with --session, history is scoped to the session
+	NamedSession bool
+
+	User  *User
+	Input any
 
 	Roots     *Roots
 	Workspace Workspace
@@ -39,6 +42,20 @@ type Vars struct {
 	Adapters  AdapterRegistry
 	History   MemStore
 	Log       CallLogger
+	// optional LLM response cache
+	Cache ResponseCache
+	// optional model provider health across calls
+	Health ModelHealth
+	// optional token and cost accounting
+	Usage UsageLedger
+	// optional run and flow step journal
+	Journal RunJournal
+	// optional authorization of tool calls and shell commands
+	Policy Policy
+	// optional safety check of shell commands
+	ShellGuard ShellGuard
+	// optional approval of tool calls declaring approval: required
+	Approver Approver
 }
 
 // Return default query from message and content.
@@ -225,3 +242,17 @@ func (a ArgMap) SetHistory(messages []*Message) ArgMap {
 func (a ArgMap) DeleteHitory() {
 	delete(a, "history")
 }
+
+// Conversation returns the prior turns supplied by the client, e.g. of ai /serve,
+// added to the history after the context of the agent.
+func (a ArgMap) Conversation() []*Message {
+	if v, ok := a["conversation"].([]*Message); ok {
+		return v
+	}
+	return nil
+}
+
+func (a ArgMap) SetConversation(messages []*Message) ArgMap {
+	a["conversation"] = messages
+	return a
+}
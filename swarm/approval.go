@@ -0,0 +1,51 @@
+package swarm
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/qiangli/ai/swarm/api"
+	"github.com/qiangli/ai/swarm/log"
+)
+
+// requestApproval holds the tool call until the user decides.
+// It returns the outcome and the arguments to call the tool with;
+// the outcome is rejected with the message for the LLM if the call must not be made.
+func requestApproval(ctx context.Context, vars *api.Vars, agent string, tf *api.ToolFunc, args map[string]any) (string, map[string]any, string, error) {
+	target := tf.Kit + ":" + tf.Name
+	if vars.Approver == nil {
+		return api.ApprovalRejected, nil, "", fmt.Errorf("%s requires approval but no approver is available", target)
+	}
+	req := &api.ApprovalRequest{
+		ID:        uuid.NewString(),
+		Agent:     agent,
+		Kit:       tf.Kit,
+		Name:      tf.Name,
+		Arguments: args,
+		Created:   time.Now(),
+	}
+	logger := log.GetLogger(ctx)
+
+	d, err := vars.Approver.Approve(ctx, req)
+	if err != nil {
+		logger.Infof("🛡 %s @%s not approved: %v\n", target, agent, err)
+		return api.ApprovalRejected, nil, "", fmt.Errorf("%s requires approval: %v", target, err)
+	}
+	if !d.Approved {
+		logger.Infof("🛡 %s @%s rejected by user: %s\n", target, agent, d.Reason)
+		msg := fmt.Sprintf("The user rejected the call to %s.", target)
+		if d.Reason != "" {
+			msg += " Reason: " + d.Reason
+		}
+		return api.ApprovalRejected, nil, msg, nil
+	}
+	if d.Arguments != nil {
+		logger.Infof("🛡 %s @%s approved by user with edited arguments %+v\n", target, agent, api.FormatArgMap(d.Arguments))
+		return api.ApprovalEdited, d.Arguments, "", nil
+	}
+	logger.Infof("🛡 %s @%s approved by user\n", target, agent)
+	return api.ApprovalApproved, args, "", nil
+}
@@ -0,0 +1,87 @@
+// Package approval requests the approval of tool calls declaring approval: required, e.g.
+//
+//	tools:
+//	  - name: deploy
+//	    approval: required
+//
+// The user approves the call, rejects it with a reason fed back to the LLM or edits the arguments.
+// On a terminal the user is asked on the console; otherwise, e.g. in serve mode,
+// the approval is requested through files or an HTTP callback configured in policy.yaml:
+//
+//	approval:
+//	  mode: http
+//	  url: https://example.com/approve
+//	  token: ${APPROVAL_TOKEN}
+//	  timeout: 300
+package approval
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/term"
+
+	"github.com/qiangli/ai/swarm/api"
+)
+
+// default time to wait for a decision
+const defaultTimeout = 10 * time.Minute
+
+// New returns the approver of the mode, auto if cfg is nil.
+// Request files are kept under the workspace unless a directory is configured.
+func New(cfg *api.ApprovalConfig, workspace string) (api.Approver, error) {
+	if cfg == nil {
+		cfg = &api.ApprovalConfig{}
+	}
+	timeout := defaultTimeout
+	if cfg.Timeout > 0 {
+		timeout = time.Duration(cfg.Timeout) * time.Second
+	}
+	dir := cfg.Dir
+	if dir == "" {
+		dir = filepath.Join(workspace, "var", "approval")
+	}
+
+	mode := cfg.Mode
+	if mode == "" || mode == api.ApprovalAuto {
+		mode = api.ApprovalFile
+		if term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd())) {
+			mode = api.ApprovalConsole
+		}
+	}
+
+	switch mode {
+	case api.ApprovalConsole:
+		return &Console{}, nil
+	case api.ApprovalFile:
+		return &File{
+			Dir:     dir,
+			Timeout: timeout,
+		}, nil
+	case api.ApprovalHttp:
+		if cfg.Url == "" {
+			return nil, fmt.Errorf("approval url is required for http mode")
+		}
+		return &Http{
+			Url:     cfg.Url,
+			Token:   os.ExpandEnv(cfg.Token),
+			Timeout: timeout,
+		}, nil
+	}
+	return nil, fmt.Errorf("invalid approval mode: %q. supported: auto, console, file, http", cfg.Mode)
+}
+
+// NewServer returns the approver of serve modes, file mode unless a mode is configured
+// as requests can't be approved on the terminal the server was started from.
+func NewServer(cfg *api.ApprovalConfig, workspace string) (api.Approver, error) {
+	var c api.ApprovalConfig
+	if cfg != nil {
+		c = *cfg
+	}
+	if c.Mode == "" || c.Mode == api.ApprovalAuto {
+		c.Mode = api.ApprovalFile
+	}
+	return New(&c, workspace)
+}
@@ -0,0 +1,108 @@
+package approval
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/qiangli/ai/swarm/api"
+)
+
+func TestFile(t *testing.T) {
+	dir := t.TempDir()
+	approver := &File{Dir: dir, Timeout: 5 * time.Second, Poll: 10 * time.Millisecond}
+	req := &api.ApprovalRequest{ID: "r1", Kit: "k8s", Name: "deploy", Arguments: map[string]any{"cluster": "prod"}}
+
+	// respond once the request is written
+	go func() {
+		reqFile := filepath.Join(dir, "r1.request.json")
+		for range 200 {
+			if _, err := os.Stat(reqFile); err == nil {
+				os.WriteFile(filepath.Join(dir, "r1.response.json"), []byte(`{"approved": false, "reason": "not today"}`), 0o600)
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	d, err := approver.Approve(context.TODO(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Approved || d.Reason != "not today" {
+		t.Errorf("unexpected decision: %+v", d)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("expected request and response files to be removed, got %v", len(entries))
+	}
+
+	approver.Timeout = 50 * time.Millisecond
+	if _, err := approver.Approve(context.TODO(), &api.ApprovalRequest{ID: "r2"}); err == nil {
+		t.Errorf("expected timeout")
+	}
+}
+
+func TestHttp(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var req api.ApprovalRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(&api.ApprovalDecision{
+			Approved:  true,
+			Arguments: map[string]any{"cluster": "staging", "id": req.ID},
+		})
+	}))
+	defer ts.Close()
+
+	approver := &Http{Url: ts.URL, Token: "secret"}
+	d, err := approver.Approve(context.TODO(), &api.ApprovalRequest{ID: "r1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.Approved || d.Arguments["cluster"] != "staging" || d.Arguments["id"] != "r1" {
+		t.Errorf("unexpected decision: %+v", d)
+	}
+
+	approver.Token = ""
+	if _, err := approver.Approve(context.TODO(), &api.ApprovalRequest{ID: "r2"}); err == nil {
+		t.Errorf("expected unauthorized callback to fail")
+	}
+}
+
+func TestNew(t *testing.T) {
+	if v, err := New(&api.ApprovalConfig{Mode: api.ApprovalFile}, "/ws"); err != nil || v.(*File).Dir != "/ws/var/approval" {
+		t.Errorf("unexpected file approver: %+v %v", v, err)
+	}
+	if _, err := New(&api.ApprovalConfig{Mode: api.ApprovalHttp}, "/ws"); err == nil {
+		t.Errorf("expected error without url")
+	}
+	if _, err := New(&api.ApprovalConfig{Mode: "email"}, "/ws"); err == nil {
+		t.Errorf("expected invalid mode")
+	}
+}
+
+func TestNewServer(t *testing.T) {
+	for _, cfg := range []*api.ApprovalConfig{nil, {Mode: api.ApprovalAuto, Timeout: 5}} {
+		if v, err := NewServer(cfg, "/ws"); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		} else if _, ok := v.(*File); !ok {
+			t.Errorf("expected file approver for %+v: %T", cfg, v)
+		}
+	}
+	if v, err := NewServer(&api.ApprovalConfig{Mode: api.ApprovalHttp, Url: "http://localhost/approve"}, "/ws"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if _, ok := v.(*Http); !ok {
+		t.Errorf("expected configured mode: %T", v)
+	}
+}
@@ -0,0 +1,113 @@
+package approval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/qiangli/ai/internal/bubble"
+	"github.com/qiangli/ai/swarm/api"
+)
+
+const (
+	optionApprove = "Approve"
+	optionReject  = "Reject"
+	optionEdit    = "Edit arguments"
+)
+
+// Console asks the user on the terminal.
+type Console struct {
+	// one prompt at a time for concurrent flows
+	mu sync.Mutex
+}
+
+func (r *Console) Approve(ctx context.Context, req *api.ApprovalRequest) (*api.ApprovalDecision, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if req.Prompt != "" {
+		answer, err := bubble.Confirm(req.Prompt)
+		if err != nil {
+			return nil, err
+		}
+		return &api.ApprovalDecision{Approved: answer == "yes"}, nil
+	}
+
+	args, err := json.MarshalIndent(req.Arguments, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	var edited map[string]any
+	for {
+		prompt := fmt.Sprintf("@%s wants to call %s:%s with:\n%s\n", req.Agent, req.Kit, req.Name, args)
+		choice, err := bubble.Choose(prompt, []string{optionApprove, optionReject, optionEdit}, false)
+		if err != nil {
+			return nil, err
+		}
+		switch choice {
+		case optionApprove:
+			return &api.ApprovalDecision{Approved: true, Arguments: edited}, nil
+		case optionEdit:
+			text, err := editArguments(string(args))
+			if err != nil {
+				return nil, err
+			}
+			// canceled
+			if strings.TrimSpace(text) == "" {
+				continue
+			}
+			var m map[string]any
+			if err := json.Unmarshal([]byte(text), &m); err != nil {
+				fmt.Fprintf(os.Stderr, "invalid JSON arguments: %v\n", err)
+				continue
+			}
+			edited = m
+			args, _ = json.MarshalIndent(m, "", "  ")
+		default:
+			// canceled prompts are rejections
+			reason, err := bubble.Write("Why is the call rejected? (optional)", "Reason for the assistant...", "")
+			if err != nil {
+				return nil, err
+			}
+			return &api.ApprovalDecision{Approved: false, Reason: strings.TrimSpace(reason)}, nil
+		}
+	}
+}
+
+// editArguments opens the arguments in $EDITOR if set, otherwise in the built-in editor.
+func editArguments(text string) (string, error) {
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		return bubble.Edit("Edit the arguments (JSON)", "{}", text)
+	}
+
+	dir, err := os.MkdirTemp("", "approval")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "arguments.json")
+	if err := os.WriteFile(file, []byte(text), 0o600); err != nil {
+		return "", err
+	}
+
+	// the editor may be configured with flags, e.g. "code --wait"
+	fields := strings.Fields(editor)
+	cmd := exec.Command(fields[0], append(fields[1:], file)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("editor %s failed: %v", editor, err)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
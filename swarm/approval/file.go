@@ -0,0 +1,82 @@
+package approval
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/qiangli/ai/swarm/api"
+	"github.com/qiangli/ai/swarm/log"
+)
+
+// File requests approvals by writing <id>.request.json into the directory
+// and waits for the decision in <id>.response.json, e.g.
+//
+//	{"approved": false, "reason": "use the staging cluster"}
+//
+// Confirmations of policy ask rules carry the prompt instead of arguments to edit.
+// Both files are removed once the decision is read.
+type File struct {
+	Dir     string
+	Timeout time.Duration
+
+	// interval of checking for the response, defaults to one second
+	Poll time.Duration
+}
+
+func (r *File) Approve(ctx context.Context, req *api.ApprovalRequest) (*api.ApprovalDecision, error) {
+	if err := os.MkdirAll(r.Dir, 0o700); err != nil {
+		return nil, err
+	}
+	reqFile := filepath.Join(r.Dir, req.ID+".request.json")
+	respFile := filepath.Join(r.Dir, req.ID+".response.json")
+
+	data, err := json.MarshalIndent(req, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(reqFile, data, 0o600); err != nil {
+		return nil, err
+	}
+	defer os.Remove(reqFile)
+	defer os.Remove(respFile)
+
+	log.GetLogger(ctx).Infof("⏸ %s:%s waiting for approval: %s\n", req.Kit, req.Name, respFile)
+
+	poll := r.Poll
+	if poll <= 0 {
+		poll = time.Second
+	}
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+	deadline := time.After(timeout)
+
+	for {
+		data, err := os.ReadFile(respFile)
+		if err == nil {
+			var d api.ApprovalDecision
+			// the response may not be completely written yet
+			if err := json.Unmarshal(data, &d); err == nil {
+				return &d, nil
+			}
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-deadline:
+			return nil, fmt.Errorf("no approval decision in %s after %v", respFile, timeout)
+		case <-ticker.C:
+		}
+	}
+}
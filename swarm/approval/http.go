@@ -0,0 +1,67 @@
+package approval
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/qiangli/ai/swarm/api"
+	"github.com/qiangli/ai/swarm/log"
+)
+
+// Http posts the approval request as JSON to the callback url
+// and reads the decision from the response body.
+// The callback may hold the response until the user decides.
+type Http struct {
+	Url   string
+	Token string
+
+	Timeout time.Duration
+}
+
+func (r *Http) Approve(ctx context.Context, req *api.ApprovalRequest) (*api.ApprovalDecision, error) {
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	hreq, err := http.NewRequestWithContext(ctx, http.MethodPost, r.Url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	hreq.Header.Set("Content-Type", "application/json")
+	if r.Token != "" {
+		hreq.Header.Set("Authorization", "Bearer "+r.Token)
+	}
+
+	log.GetLogger(ctx).Infof("⏸ %s:%s waiting for approval: %s\n", req.Kit, req.Name, r.Url)
+
+	resp, err := http.DefaultClient.Do(hreq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("approval callback failed: %s %s", resp.Status, bytes.TrimSpace(body))
+	}
+	var d api.ApprovalDecision
+	if err := json.Unmarshal(body, &d); err != nil {
+		return nil, fmt.Errorf("invalid approval decision: %v", err)
+	}
+	return &d, nil
+}
@@ -0,0 +1,51 @@
+package swarm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/qiangli/ai/swarm/api"
+)
+
+type stubApprover struct {
+	decision *api.ApprovalDecision
+	req      *api.ApprovalRequest
+}
+
+func (r *stubApprover) Approve(ctx context.Context, req *api.ApprovalRequest) (*api.ApprovalDecision, error) {
+	r.req = req
+	return r.decision, nil
+}
+
+func TestRequestApproval(t *testing.T) {
+	ctx := context.TODO()
+	tf := &api.ToolFunc{Kit: "k8s", Name: "deploy", Approval: api.ApprovalRequired}
+	args := map[string]any{"cluster": "prod"}
+
+	stub := &stubApprover{decision: &api.ApprovalDecision{Approved: true}}
+	vars := &api.Vars{Approver: stub}
+	outcome, approved, _, err := requestApproval(ctx, vars, "ops", tf, args)
+	if err != nil || outcome != api.ApprovalApproved || approved["cluster"] != "prod" {
+		t.Errorf("expected approved call: %s %v %v", outcome, approved, err)
+	}
+	if stub.req.Agent != "ops" || stub.req.Name != "deploy" || stub.req.ID == "" {
+		t.Errorf("unexpected request: %+v", stub.req)
+	}
+
+	stub.decision = &api.ApprovalDecision{Approved: true, Arguments: map[string]any{"cluster": "staging"}}
+	outcome, approved, _, err = requestApproval(ctx, vars, "ops", tf, args)
+	if err != nil || outcome != api.ApprovalEdited || approved["cluster"] != "staging" {
+		t.Errorf("expected edited arguments: %s %v %v", outcome, approved, err)
+	}
+
+	stub.decision = &api.ApprovalDecision{Reason: "use staging"}
+	outcome, _, msg, err := requestApproval(ctx, vars, "ops", tf, args)
+	if err != nil || outcome != api.ApprovalRejected || !strings.Contains(msg, "use staging") {
+		t.Errorf("expected rejection with reason: %s %q %v", outcome, msg, err)
+	}
+
+	if _, _, _, err := requestApproval(ctx, &api.Vars{}, "ops", tf, args); err == nil {
+		t.Errorf("expected error without approver")
+	}
+}
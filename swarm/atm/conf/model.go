@@ -50,6 +50,12 @@ func LoadModel(owner, set, level string, assets api.AssetManager) (*api.Model, e
 			Model:    c.Model,
 			BaseUrl:  c.BaseUrl,
 			ApiKey:   c.ApiKey,
+			//
+			Weight:     c.Weight,
+			InputCost:  c.InputCost,
+			OutputCost: c.OutputCost,
+			//
+			ContextWindow: c.ContextWindow,
 		}
 
 		return m, nil
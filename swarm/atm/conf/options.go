@@ -100,6 +100,9 @@ func ParseActionArgs(argv []string) (api.ArgMap, error) {
 	isInfo := fs.Bool("info", false, "Show progress")
 	isVerbose := fs.Bool("verbose", false, "Show progress and debugging information")
 
+	// console output
+	stream := fs.Bool("stream", true, "Stream LLM output as it is generated")
+
 	// special input
 	// value provided as option
 	stdin := fs.String("stdin", "", "Read input from stdin")
@@ -226,6 +229,9 @@ func ParseActionArgs(argv []string) (api.ArgMap, error) {
 	if *stdin != "" {
 		argm["stdin"] = *stdin
 	}
+	if isSet("stream") {
+		argm["stream"] = *stream
+	}
 
 	// replace all "-" with "_"
 	for k, v := range argm {
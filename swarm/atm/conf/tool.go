@@ -225,8 +225,39 @@ func LoadTools(tc *api.AppConfig, owner string, secrets api.SecretStore) ([]*api
 			return nil, fmt.Errorf("Missing tool type. kit: %s tool: %s", tc.Kit, v.Name)
 		}
 
-		// load separately
+		// contact the mcp server and fetch the list of tools
 		if toolType == string(api.ToolTypeMcp) {
+			connector := mcpConnector(tc, v)
+			var token string
+			if connector.ApiKey != "" && secrets != nil {
+				tk, err := secrets.Get(owner, connector.ApiKey)
+				if err != nil {
+					return nil, err
+				}
+				token = tk
+			}
+			list, err := ListMcpTools(tc.Kit, connector, token, v.Filter)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list mcp tools. kit: %s: %w", tc.Kit, err)
+			}
+			for _, tool := range list {
+				tool.Approval = v.Approval
+				tool.Output = v.Output
+				tool.Config = tc
+				toolMap[tool.ID()] = tool
+			}
+			continue
+		}
+
+		// every operation of the OpenAPI document is a tool
+		if toolType == string(api.ToolTypeOpenAPI) {
+			list, err := LoadOpenAPITools(tc, v)
+			if err != nil {
+				return nil, err
+			}
+			for _, tool := range list {
+				toolMap[tool.ID()] = tool
+			}
 			continue
 		}
 
@@ -238,7 +269,8 @@ func LoadTools(tc *api.AppConfig, owner string, secrets api.SecretStore) ([]*api
 			Parameters:  v.Parameters,
 			Body:        v.Body,
 			//
-			Output: v.Output,
+			Output:   v.Output,
+			Approval: v.Approval,
 			//
 			Arguments: v.Arguments,
 			// Provider: nvl(v.Provider, tc.Provider),
@@ -251,48 +283,6 @@ func LoadTools(tc *api.AppConfig, owner string, secrets api.SecretStore) ([]*api
 		toolMap[tool.ID()] = tool
 	}
 
-	// // contact mcp servers and fetch the list of tools
-	// for _, v := range tc.Tools {
-	// 	var toolType = nvl(v.Type, tc.Type)
-	// 	if toolType != string(api.ToolTypeMcp) {
-	// 		continue
-	// 	}
-	// 	var token string
-	// 	apiKey := nvl(v.ApiKey, tc.ApiKey)
-	// 	if v, err := secrets.Get(owner, apiKey); err != nil {
-	// 		return nil, err
-	// 	} else {
-	// 		token = v
-	// 	}
-	// 	connector := &api.ConnectorConfig{
-	// 		Provider: nvl(v.Provider, tc.Provider),
-	// 		BaseUrl:  nvl(v.BaseUrl, tc.BaseUrl),
-	// 		ApiKey:   nvl(v.ApiKey, tc.ApiKey),
-	// 	}
-	// 	mcpTools, err := listMcpTools(tc.Kit, connector, token)
-	// 	if err != nil {
-	// 		return nil, err
-	// 	}
-
-	// 	// return true if extra is empty or has the name/val in the filter
-	// 	met := func(extra map[string]any) bool {
-	// 		if len(extra) == 0 || len(v.Filter) == 0 {
-	// 			return true
-	// 		}
-	// 		for key, f := range v.Filter {
-	// 			if m, ok := extra[key]; ok && m == f {
-	// 				return true
-	// 			}
-	// 		}
-	// 		return false
-	// 	}
-	// 	for _, tool := range mcpTools {
-	// 		if met(tool.Extra) {
-	// 			toolMap[tool.ID()] = tool
-	// 		}
-	// 	}
-	// }
-
 	// TODO deprecated in favor of tools
 	// connector mcp
 	// if tc.Connector != nil {
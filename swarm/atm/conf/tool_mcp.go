@@ -3,36 +3,58 @@ package conf
 import (
 	"context"
 	"fmt"
-	"maps"
+	"path"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
 
 	"github.com/qiangli/ai/swarm/api"
 	mcpcli "github.com/qiangli/ai/swarm/mcp"
 )
 
-func ListMcpTools(kit string, tc *mcpcli.ConnectorConfig, token string) ([]*api.ToolFunc, error) {
+// ListMcpTools returns the tools of the MCP server whose metadata match the filter.
+// The session is kept open in the default pool for the tool calls.
+func ListMcpTools(kit string, tc *mcpcli.ConnectorConfig, token string, filter map[string]string) ([]*api.ToolFunc, error) {
 	ctx := context.Background()
 
-	if tc.BaseUrl == "" {
-		return nil, fmt.Errorf("Invalid connector config. Missing URL")
+	if tc.BaseUrl == "" && tc.Command == "" {
+		return nil, fmt.Errorf("Invalid connector config. Missing command or URL")
 	}
 
-	// log.Debugf("Connecting to MCP server at %s", tc.Connector.BaseUrl)
-	client := mcpcli.NewMcpClient(tc)
-	session, err := client.Connect(ctx, token)
+	var list []*mcp.Tool
+	err := mcpcli.DefaultPool.Do(ctx, tc, token, func(session *mcp.ClientSession) error {
+		list = nil
+		for v, err := range session.Tools(ctx, nil) {
+			if err != nil {
+				return err
+			}
+			list = append(list, v)
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	defer session.Close()
-
-	// log.Debugf("Connected to server: session ID: %s)", session.ID())
 
-	result, err := session.ListTools(ctx, nil)
-	if err != nil {
-		return nil, err
+	// true if the filter is empty or any of the key/glob patterns matches the metadata
+	met := func(meta map[string]any) bool {
+		if len(filter) == 0 {
+			return true
+		}
+		for key, f := range filter {
+			if m, ok := meta[key]; ok {
+				if ok, _ := path.Match(f, fmt.Sprint(m)); ok {
+					return true
+				}
+			}
+		}
+		return false
 	}
 
 	tools := make([]*api.ToolFunc, 0)
-	for _, v := range result.Tools {
+	for _, v := range list {
+		if !met(v.GetMeta()) {
+			continue
+		}
 		params, err := structToMap(v.InputSchema)
 		if err != nil {
 			return nil, err
@@ -43,22 +65,39 @@ func ListMcpTools(kit string, tc *mcpcli.ConnectorConfig, token string) ([]*api.
 			Name:        v.Name,
 			Description: v.Description,
 			Parameters:  params,
-			Body:        nil,
-			//
-			Output: "",
-			//
-			Arguments: nil,
-			// Provider: tc.Provider,
-			// BaseUrl:  tc.BaseUrl,
-			// ApiKey:   tc.ApiKey,
-		}
-		meta := v.GetMeta()
-		if len(meta) > 0 {
-			tool.Arguments = make(map[string]any)
-			maps.Copy(tool.Arguments, meta)
+			Connector:   tc,
 		}
 		tools = append(tools, tool)
 	}
 
 	return tools, nil
 }
+
+// mcpConnector returns the server of the mcp tool config,
+// the provider, base_url and api_key default to those of the kit.
+func mcpConnector(tc *api.AppConfig, v *api.ToolConfig) *api.ConnectorConfig {
+	connector := &api.ConnectorConfig{
+		Command:  v.Command,
+		Args:     v.Args,
+		Env:      v.Env,
+		Provider: nvl(v.Provider, tc.Provider),
+		BaseUrl:  nvl(v.BaseUrl, tc.BaseUrl),
+		ApiKey:   nvl(v.ApiKey, tc.ApiKey),
+	}
+	// the url of the kit does not apply to local servers
+	if connector.Command != "" {
+		connector.BaseUrl = v.BaseUrl
+	}
+	return connector
+}
+
+// McpConnectors returns the MCP servers of the kit.
+func McpConnectors(tc *api.AppConfig) []*api.ConnectorConfig {
+	var list []*api.ConnectorConfig
+	for _, v := range tc.Tools {
+		if nvl(v.Type, tc.Type) == string(api.ToolTypeMcp) {
+			list = append(list, mcpConnector(tc, v))
+		}
+	}
+	return list
+}
@@ -0,0 +1,325 @@
+package conf
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/getkin/kin-openapi/openapi3"
+
+	"github.com/qiangli/ai/internal/rest"
+	"github.com/qiangli/ai/swarm/api"
+)
+
+// nested schemas are inlined up to this depth, deeper (recursive) schemas accept any value
+const maxSchemaDepth = 8
+
+var nonToolNameChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// max time to fetch and parse an OpenAPI document
+const openAPILoadTimeout = 30 * time.Second
+
+// parsed documents by spec, loaded once per process as tools are looked up repeatedly
+var openAPIDocs sync.Map
+
+// LoadOpenAPITools loads the OpenAPI document of the tool config
+// and returns a tool for every operation.
+func LoadOpenAPITools(tc *api.AppConfig, v *api.ToolConfig) ([]*api.ToolFunc, error) {
+	if v.Spec == "" {
+		return nil, fmt.Errorf("Missing spec for openapi tool. kit: %s tool: %s", tc.Kit, v.Name)
+	}
+	doc, err := cachedOpenAPIDoc(tc, v.Spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load OpenAPI spec %s: %w", v.Spec, err)
+	}
+
+	baseUrl := nvl(tc.BaseUrl, serverUrl(doc, v.Spec))
+	authHeader := apiKeyHeader(doc)
+
+	var tools []*api.ToolFunc
+	for _, p := range doc.Paths.InMatchingOrder() {
+		item := doc.Paths.Value(p)
+		ops := item.Operations()
+		for _, method := range slices.Sorted(maps.Keys(ops)) {
+			op := ops[method]
+			params, props, required := operationParams(item.Parameters, op)
+
+			var contentType string
+			if body := op.RequestBody; body != nil && body.Value != nil {
+				var schema *openapi3.SchemaRef
+				if contentType, schema = requestContent(body.Value.Content); contentType != "" {
+					prop := schemaMap(schema, 0)
+					if body.Value.Description != "" {
+						prop["description"] = body.Value.Description
+					}
+					props[api.OpenAPIBodyArg] = prop
+					if body.Value.Required {
+						required = append(required, api.OpenAPIBodyArg)
+					}
+				}
+			}
+
+			name := operationName(method, p, op.OperationID)
+			desc := strings.TrimSpace(op.Summary + "\n\n" + op.Description)
+			if desc == "" {
+				desc = method + " " + p
+			}
+			tools = append(tools, &api.ToolFunc{
+				Kit:         tc.Kit,
+				Type:        api.ToolTypeOpenAPI,
+				Name:        name,
+				Description: desc,
+				Parameters: api.Parameters{
+					"type":       "object",
+					"properties": props,
+					"required":   required,
+				},
+				Output:    v.Output,
+				Approval:  v.Approval,
+				Arguments: v.Arguments,
+				Operation: &api.OpenAPIOperation{
+					Method:      method,
+					Path:        p,
+					BaseUrl:     baseUrl,
+					Params:      params,
+					ContentType: contentType,
+					ApiKey:      tc.ApiKey,
+					AuthHeader:  authHeader,
+				},
+				Config: tc,
+			})
+		}
+	}
+	return tools, nil
+}
+
+// cachedOpenAPIDoc returns the parsed document of the spec, loading it on first use.
+// failures are not cached.
+func cachedOpenAPIDoc(tc *api.AppConfig, spec string) (*openapi3.T, error) {
+	key := spec
+	if !isURL(spec) && !filepath.IsAbs(spec) && !strings.HasPrefix(spec, "~") && tc.Store != nil {
+		key = fmt.Sprintf("%T:%s", tc.Store, path.Join(tc.BaseDir, spec))
+	}
+	if v, ok := openAPIDocs.Load(key); ok {
+		return v.(*openapi3.T), nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), openAPILoadTimeout)
+	defer cancel()
+	doc, err := loadOpenAPIDoc(ctx, tc, spec)
+	if err != nil {
+		return nil, err
+	}
+	v, _ := openAPIDocs.LoadOrStore(key, doc)
+	return v.(*openapi3.T), nil
+}
+
+// loadOpenAPIDoc reads the document from a url, an absolute path,
+// or a path relative to the tool config in the asset store.
+func loadOpenAPIDoc(ctx context.Context, tc *api.AppConfig, spec string) (*openapi3.T, error) {
+	kit := &rest.SwagKit{}
+	if isURL(spec) || filepath.IsAbs(spec) || strings.HasPrefix(spec, "~") || tc.Store == nil {
+		return kit.Load(ctx, spec)
+	}
+	name := path.Join(tc.BaseDir, spec)
+	var data []byte
+	var err error
+	switch store := tc.Store.(type) {
+	case api.AssetFS:
+		data, err = store.ReadFile(name)
+	case api.Workspace:
+		data, err = store.ReadFile(name, nil)
+	default:
+		return nil, fmt.Errorf("asset not supported: %s", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return kit.LoadData(ctx, data)
+}
+
+func isURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
+
+// serverUrl returns the url of the first server with the default values of its variables.
+// relative urls are resolved against the url of the spec.
+func serverUrl(doc *openapi3.T, spec string) string {
+	if len(doc.Servers) == 0 || doc.Servers[0] == nil {
+		return ""
+	}
+	server := doc.Servers[0]
+	u := server.URL
+	for k, v := range server.Variables {
+		if v != nil {
+			u = strings.ReplaceAll(u, "{"+k+"}", v.Default)
+		}
+	}
+	if isURL(u) || !isURL(spec) {
+		return u
+	}
+	base, err := url.Parse(spec)
+	if err != nil {
+		return u
+	}
+	ref, err := url.Parse(u)
+	if err != nil {
+		return u
+	}
+	return base.ResolveReference(ref).String()
+}
+
+// apiKeyHeader returns the header of the first apiKey security scheme sent in a header.
+func apiKeyHeader(doc *openapi3.T) string {
+	if doc.Components == nil {
+		return ""
+	}
+	var names []string
+	for k := range doc.Components.SecuritySchemes {
+		names = append(names, k)
+	}
+	slices.Sort(names)
+	for _, k := range names {
+		s := doc.Components.SecuritySchemes[k]
+		if s != nil && s.Value != nil && s.Value.Type == "apiKey" && s.Value.In == "header" {
+			return s.Value.Name
+		}
+	}
+	return ""
+}
+
+// operationParams returns the locations and the schema properties of the path, query and header parameters.
+// operation parameters override the path item parameters of the same name.
+func operationParams(common openapi3.Parameters, op *openapi3.Operation) (map[string]string, map[string]any, []string) {
+	var params = make(map[string]string)
+	var props = make(map[string]any)
+	var required []string
+
+	for _, ref := range append(slices.Clone(common), op.Parameters...) {
+		if ref == nil || ref.Value == nil {
+			continue
+		}
+		p := ref.Value
+		switch p.In {
+		case api.ParamInPath, api.ParamInQuery, api.ParamInHeader:
+		default:
+			continue
+		}
+		prop := schemaMap(p.Schema, 0)
+		if p.Description != "" {
+			prop["description"] = p.Description
+		}
+		params[p.Name] = p.In
+		props[p.Name] = prop
+		required = slices.DeleteFunc(required, func(s string) bool { return s == p.Name })
+		if p.Required || p.In == api.ParamInPath {
+			required = append(required, p.Name)
+		}
+	}
+	return params, props, required
+}
+
+// requestContent returns the media type and schema of the request body, json is preferred.
+func requestContent(content openapi3.Content) (string, *openapi3.SchemaRef) {
+	if len(content) == 0 {
+		return "", nil
+	}
+	if v, ok := content["application/json"]; ok && v != nil {
+		return "application/json", v.Schema
+	}
+	var types []string
+	for k := range content {
+		types = append(types, k)
+	}
+	slices.Sort(types)
+	for _, k := range types {
+		if strings.HasSuffix(k, "+json") {
+			return k, content[k].Schema
+		}
+	}
+	return types[0], content[types[0]].Schema
+}
+
+// schemaMap returns the json schema with references inlined.
+func schemaMap(ref *openapi3.SchemaRef, depth int) map[string]any {
+	var m = make(map[string]any)
+	s := inlineSchema(ref, depth)
+	if s == nil || s.Value == nil {
+		return m
+	}
+	data, err := json.Marshal(s.Value)
+	if err != nil {
+		return m
+	}
+	_ = json.Unmarshal(data, &m)
+	return m
+}
+
+func inlineSchema(ref *openapi3.SchemaRef, depth int) *openapi3.SchemaRef {
+	if ref == nil || ref.Value == nil {
+		return ref
+	}
+	if depth > maxSchemaDepth {
+		return &openapi3.SchemaRef{Value: &openapi3.Schema{}}
+	}
+	s := *ref.Value
+	inline := func(v *openapi3.SchemaRef) *openapi3.SchemaRef {
+		return inlineSchema(v, depth+1)
+	}
+	inlineAll := func(list openapi3.SchemaRefs) openapi3.SchemaRefs {
+		if list == nil {
+			return nil
+		}
+		var refs = make(openapi3.SchemaRefs, len(list))
+		for i, v := range list {
+			refs[i] = inline(v)
+		}
+		return refs
+	}
+	if s.Properties != nil {
+		props := make(openapi3.Schemas, len(s.Properties))
+		for k, v := range s.Properties {
+			props[k] = inline(v)
+		}
+		s.Properties = props
+	}
+	s.Items = inline(s.Items)
+	s.Not = inline(s.Not)
+	s.AllOf = inlineAll(s.AllOf)
+	s.AnyOf = inlineAll(s.AnyOf)
+	s.OneOf = inlineAll(s.OneOf)
+	if s.AdditionalProperties.Schema != nil {
+		s.AdditionalProperties.Schema = inline(s.AdditionalProperties.Schema)
+	}
+	return &openapi3.SchemaRef{Value: &s}
+}
+
+// operationName returns the snake case operation id, or method_path if the id is missing.
+// e.g. listPets: list_pets, GET /pets/{id}: get_pets_id
+func operationName(method, p, id string) string {
+	if id == "" {
+		id = method + "_" + p
+	}
+	var b strings.Builder
+	runes := []rune(id)
+	for i, c := range runes {
+		if unicode.IsUpper(c) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				b.WriteByte('_')
+			}
+			c = unicode.ToLower(c)
+		}
+		b.WriteRune(c)
+	}
+	return strings.Trim(nonToolNameChars.ReplaceAllString(b.String(), "_"), "_")
+}
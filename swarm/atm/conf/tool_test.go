@@ -2,8 +2,13 @@ package conf
 
 import (
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"sync/atomic"
 	"testing"
+
+	"github.com/qiangli/ai/swarm/api"
 )
 
 func TestLoadToolData(t *testing.T) {
@@ -29,3 +34,46 @@ func TestLoadToolData(t *testing.T) {
 		t.Logf("%s", v.Body.Script)
 	}
 }
+
+func TestOperationName(t *testing.T) {
+	tests := []struct {
+		method, path, id, want string
+	}{
+		{"GET", "/pets", "listPets", "list_pets"},
+		{"GET", "/pets", "getHTTPStatus", "get_http_status"},
+		{"DELETE", "/pets/{petId}", "", "delete_pets_pet_id"},
+		{"GET", "/v1/users.list", "", "get_v1_users_list"},
+	}
+	for _, tt := range tests {
+		if got := operationName(tt.method, tt.path, tt.id); got != tt.want {
+			t.Errorf("operationName(%q, %q, %q) = %q, want %q", tt.method, tt.path, tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestLoadOpenAPIToolsCached(t *testing.T) {
+	const spec = `{"openapi":"3.0.0","info":{"title":"pets","version":"1"},
+		"paths":{"/pets":{"get":{"operationId":"listPets","responses":{"200":{"description":"ok"}}}}}}`
+	var fetched atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, spec)
+	}))
+	defer ts.Close()
+
+	tc := &api.AppConfig{Kit: "pets"}
+	v := &api.ToolConfig{Name: "pets", Spec: ts.URL + "/openapi.json"}
+	for range 3 {
+		tools, err := LoadOpenAPITools(tc, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tools) != 1 || tools[0].Name != "list_pets" {
+			t.Fatalf("unexpected tools: %+v", tools)
+		}
+	}
+	if n := fetched.Load(); n != 1 {
+		t.Errorf("spec fetched %v times", n)
+	}
+}
@@ -0,0 +1,210 @@
+package atm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/qiangli/ai/swarm/api"
+	"github.com/qiangli/ai/swarm/log"
+	"github.com/qiangli/ai/swarm/telemetry"
+)
+
+// enterFlow returns ctx scoped to a new flow of the current run.
+func enterFlow(ctx context.Context, name string) context.Context {
+	run, path := api.GetFlowRun(ctx)
+	if run == nil {
+		return ctx
+	}
+	return api.WithFlowPath(ctx, run.Scope(path, name))
+}
+
+// flowBranch returns ctx scoped to the nth branch of the current flow,
+// e.g. a loop iteration or an item of map/reduce.
+func flowBranch(ctx context.Context, n int) context.Context {
+	run, path := api.GetFlowRun(ctx)
+	if run == nil {
+		return ctx
+	}
+	return api.WithFlowPath(ctx, fmt.Sprintf("%s/%d", path, n))
+}
+
+// runStep runs the action as the nth step of the current flow and records it in the run journal.
+// A step completed by a previous attempt of the run is not run again, its output is returned instead.
+func runStep(ctx context.Context, runner api.ActionRunner, n int, action string, argm map[string]any) (any, error) {
+	run, path := api.GetFlowRun(ctx)
+	key := fmt.Sprintf("%s/%d", path, n)
+
+	// intermediate outputs are not part of the reply
+	ctx = api.WithoutStreamHandler(ctx)
+
+	ctx, span := telemetry.Start(ctx, "flow.step "+action,
+		telemetry.AttrAction.String(action),
+		telemetry.AttrFlowStep.String(key),
+	)
+	data, err := runJournaledStep(ctx, run, key, runner, action, argm)
+	telemetry.End(span, err)
+	return data, err
+}
+
+func runJournaledStep(ctx context.Context, run *api.FlowRun, key string, runner api.ActionRunner, action string, argm map[string]any) (any, error) {
+	if run == nil || run.Journal == nil {
+		return runner.Run(ctx, action, argm)
+	}
+
+	if done := run.Completed(key, action); done != nil {
+		trace.SpanFromContext(ctx).SetAttributes(telemetry.AttrFlowSkipped.Bool(true))
+		log.GetLogger(ctx).Infof("⏭ %s %s (completed)\n", key, action)
+		if done.Output == nil {
+			return nil, nil
+		}
+		return done.Output, nil
+	}
+
+	step := &api.RunStep{
+		RunID:   run.ID,
+		Key:     key,
+		Action:  action,
+		Input:   encodeStepInput(runner, action, argm),
+		Status:  api.RunStatusRunning,
+		Started: time.Now(),
+	}
+	saveStep(ctx, run, step)
+
+	data, err := runner.Run(api.WithFlowPath(ctx, key), action, argm)
+
+	step.Ended = time.Now()
+	if err != nil {
+		step.Status = api.RunStatusFailed
+		step.Error = err.Error()
+	} else {
+		step.Status = api.RunStatusCompleted
+		step.Output = api.ToResult(data)
+	}
+	saveStep(ctx, run, step)
+
+	return data, err
+}
+
+// journal failures should not abort the flow
+func saveStep(ctx context.Context, run *api.FlowRun, step *api.RunStep) {
+	if err := run.Journal.SaveStep(step); err != nil {
+		log.GetLogger(ctx).Errorf("failed to save step %s of run %s: %v\n", step.Key, run.ID, err)
+	}
+}
+
+// encodeStepInput returns the arguments declared by the tool of the action,
+// runtime values shared by the flow such as the history are left out.
+// arguments that can't be encoded, e.g. functions, are left out.
+func encodeStepInput(runner api.ActionRunner, action string, argm map[string]any) string {
+	var keys []string
+	if tr, ok := runner.(api.ToolResolver); ok {
+		if tf, err := tr.Resolve(action); err == nil && tf != nil {
+			keys = tf.Parameters.Properties()
+			// not declared by the tool
+			if len(keys) == 0 {
+				return "{}"
+			}
+		}
+	}
+	if keys == nil {
+		for k := range argm {
+			if k != "history" {
+				keys = append(keys, k)
+			}
+		}
+	}
+	var input = make(map[string]json.RawMessage)
+	for _, k := range keys {
+		v, ok := argm[k]
+		if !ok {
+			continue
+		}
+		if b, err := json.Marshal(v); err == nil {
+			input[k] = b
+		}
+	}
+	b, _ := json.Marshal(input)
+	return string(b)
+}
+
+// default number of runs listed by flow:list_runs
+const defaultListRuns = 20
+
+// ListRuns lists the most recent runs in the journal.
+func (r *SystemKit) ListRuns(ctx context.Context, vars *api.Vars, _ string, argm api.ArgMap) (*api.Result, error) {
+	if vars.Journal == nil {
+		return nil, fmt.Errorf("run journal not available")
+	}
+	limit := argm.GetInt("limit")
+	if limit <= 0 {
+		limit = defaultListRuns
+	}
+	runs, err := vars.Journal.ListRuns(limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(runs) == 0 {
+		return api.ToResult("No runs recorded\n"), nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%-36s %-10s %-19s %s\n", "ID", "STATUS", "CREATED", "INPUT")
+	for _, v := range runs {
+		fmt.Fprintf(&sb, "%-36s %-10s %-19s %s\n", v.ID, v.Status, v.Created.Local().Format(time.DateTime), clip(strings.Join(v.Input, " "), 80))
+	}
+	return api.ToResult(sb.String()), nil
+}
+
+// GetRun shows a run and its flow steps.
+func (r *SystemKit) GetRun(ctx context.Context, vars *api.Vars, _ string, argm api.ArgMap) (*api.Result, error) {
+	if vars.Journal == nil {
+		return nil, fmt.Errorf("run journal not available")
+	}
+	id := argm.GetString("id")
+	if id == "" {
+		return nil, fmt.Errorf("run id is required")
+	}
+	run, err := vars.Journal.GetRun(id)
+	if err != nil {
+		return nil, err
+	}
+	steps, err := vars.Journal.ListSteps(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Run: %s\nStatus: %s\nCreated: %s\nUpdated: %s\nInput: %s\n",
+		run.ID, run.Status, run.Created.Local().Format(time.DateTime), run.Updated.Local().Format(time.DateTime), strings.Join(run.Input, " "))
+	if run.Error != "" {
+		fmt.Fprintf(&sb, "Error: %s\n", run.Error)
+	}
+	fmt.Fprintf(&sb, "\nSteps: %v\n\n", len(steps))
+	for _, v := range steps {
+		var elapsed time.Duration
+		if !v.Ended.IsZero() {
+			elapsed = v.Ended.Sub(v.Started).Round(time.Millisecond)
+		}
+		fmt.Fprintf(&sb, "%-30s %-24s %-10s %s\n", v.Key, v.Action, v.Status, elapsed)
+		if v.Error != "" {
+			fmt.Fprintf(&sb, "  error: %s\n", clip(oneLine(v.Error), 200))
+		}
+		if v.Output != nil && v.Output.Value != "" {
+			fmt.Fprintf(&sb, "  output: %s\n", clip(oneLine(v.Output.Value), 200))
+		}
+	}
+	return &api.Result{
+		Value: sb.String(),
+		Data:  map[string]any{"run": run, "steps": steps},
+	}, nil
+}
+
+// one line per entry
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
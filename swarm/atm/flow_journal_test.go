@@ -0,0 +1,126 @@
+package atm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/qiangli/ai/swarm/api"
+	"github.com/qiangli/ai/swarm/db"
+)
+
+// resumeRunner counts the calls per action and fails test:flaky while fail is set.
+type resumeRunner struct {
+	calls map[string]int
+	fail  bool
+}
+
+func (r *resumeRunner) Run(ctx context.Context, tid string, args map[string]any) (any, error) {
+	r.calls[tid]++
+	switch tid {
+	case "test:upper":
+		return &api.Result{Value: strings.ToUpper(api.ToString(args["message"]))}, nil
+	case "test:flaky":
+		if r.fail {
+			return nil, fmt.Errorf("flaky")
+		}
+		return &api.Result{Value: api.ToString(args["result"]) + "!"}, nil
+	}
+	return nil, fmt.Errorf("unknown action: %s", tid)
+}
+
+// Resolve declares message for test:upper and result for test:flaky.
+func (r *resumeRunner) Resolve(tid string) (*api.ToolFunc, error) {
+	param := map[string]string{"test:upper": "message", "test:flaky": "result"}[tid]
+	if param == "" {
+		return nil, fmt.Errorf("unknown action: %s", tid)
+	}
+	return &api.ToolFunc{
+		Parameters: api.Parameters{
+			"type":       "object",
+			"properties": map[string]any{param: map[string]any{"type": "string"}},
+		},
+	}, nil
+}
+
+func TestFlowResume(t *testing.T) {
+	journal, err := db.OpenRunJournal(t.TempDir(), "journal.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer journal.Close()
+
+	runner := &resumeRunner{calls: make(map[string]int), fail: true}
+	vars := &api.Vars{RootAgent: &api.Agent{Runner: runner}, Journal: journal}
+	kit := &SystemKit{}
+
+	run := func(resume bool) (*api.Result, error) {
+		flow, err := api.NewFlowRun("r1", journal, resume)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx := api.WithFlowRun(context.TODO(), flow)
+		argm := api.ArgMap{
+			"actions": []string{"test:upper", "test:flaky"},
+			"message": "hello",
+			"history": []*api.Message{{Role: api.RoleUser, Content: "earlier"}},
+		}
+		return kit.Sequence(ctx, vars, "sequence", argm)
+	}
+
+	if _, err := run(false); err == nil {
+		t.Fatal("expected first attempt to fail")
+	}
+
+	runner.fail = false
+	result, err := run(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Value != "HELLO!" {
+		t.Errorf("unexpected result: %q", result.Value)
+	}
+	if runner.calls["test:upper"] != 1 || runner.calls["test:flaky"] != 2 {
+		t.Errorf("completed step should not run again: %v", runner.calls)
+	}
+
+	steps, err := journal.ListSteps("r1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(steps) != 2 {
+		t.Fatalf("expected 2 steps, got %v", len(steps))
+	}
+	for _, v := range steps {
+		if v.Status != api.RunStatusCompleted {
+			t.Errorf("step %s: %s", v.Key, v.Status)
+		}
+	}
+	if steps[0].Key != "/sequence#0/0" {
+		t.Errorf("unexpected key: %s", steps[0].Key)
+	}
+	// only the declared arguments are journaled
+	if steps[0].Input != `{"message":"hello"}` || !strings.HasPrefix(steps[1].Input, `{"result":`) {
+		t.Errorf("unexpected step inputs: %s %s", steps[0].Input, steps[1].Input)
+	}
+
+	// list and inspect
+	if err := journal.SaveRun(&api.Run{ID: "r1", Input: []string{"/flow:sequence"}, Status: api.RunStatusCompleted}); err != nil {
+		t.Fatal(err)
+	}
+	out, err := kit.GetRun(context.TODO(), vars, "get_run", api.ArgMap{"id": "r1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.Value, "test:flaky") || !strings.Contains(out.Value, "HELLO!") {
+		t.Errorf("unexpected run details: %s", out.Value)
+	}
+	out, err = kit.ListRuns(context.TODO(), vars, "list_runs", api.ArgMap{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.Value, "r1") {
+		t.Errorf("run not listed: %s", out.Value)
+	}
+}
@@ -2,7 +2,6 @@ package atm
 
 import (
 	"context"
-	"fmt"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 
@@ -12,10 +11,13 @@ import (
 )
 
 type McpKit struct {
+	pool *mcpcli.Pool
 }
 
 func NewMcpKit() *McpKit {
-	return &McpKit{}
+	return &McpKit{
+		pool: mcpcli.DefaultPool,
+	}
 }
 
 func (r *McpKit) Call(ctx context.Context, vars *api.Vars, _ *api.Agent, tf *api.ToolFunc, args map[string]any) (any, error) {
@@ -23,41 +25,39 @@ func (r *McpKit) Call(ctx context.Context, vars *api.Vars, _ *api.Agent, tf *api
 
 	log.GetLogger(ctx).Debugf("🎖️ calling MCP tool: %s with args: %+v\n", tid, args)
 
-	apiKey, _ := api.GetStrProp("api_key", args)
-	baseUrl, _ := api.GetStrProp("base_url", args)
-	provider, _ := api.GetStrProp("provider", args)
-
-	client := mcpcli.NewMcpClient(&mcpcli.ConnectorConfig{
-		BaseUrl:  baseUrl,
-		ApiKey:   apiKey,
-		Provider: provider,
-	})
-
-	tk, err := vars.Token(apiKey)
-	if err != nil {
-		return "", err
-	}
-	session, err := client.Connect(ctx, tk)
-	if err != nil {
-		return "", err
+	cfg := tf.Connector
+	if cfg == nil {
+		apiKey, _ := api.GetStrProp("api_key", args)
+		baseUrl, _ := api.GetStrProp("base_url", args)
+		provider, _ := api.GetStrProp("provider", args)
+		cfg = &mcpcli.ConnectorConfig{
+			BaseUrl:  baseUrl,
+			ApiKey:   apiKey,
+			Provider: provider,
+		}
 	}
-	defer session.Close()
 
-	log.GetLogger(ctx).Debugf("Connected to mcp server session ID: %s)", session.ID())
+	var tk string
+	if cfg.ApiKey != "" {
+		v, err := vars.Token(cfg.ApiKey)
+		if err != nil {
+			return "", err
+		}
+		tk = v
+	}
 
-	result, err := session.CallTool(ctx, &mcp.CallToolParams{
-		Name:      tf.Name,
-		Arguments: args,
+	var result *mcp.CallToolResult
+	err := r.pool.Do(ctx, cfg, tk, func(session *mcp.ClientSession) error {
+		v, err := session.CallTool(ctx, &mcp.CallToolParams{
+			Name:      tf.Name,
+			Arguments: args,
+		})
+		result = v
+		return err
 	})
 	if err != nil {
 		return "", err
 	}
 
-	for _, content := range result.Content {
-		if v, ok := content.(*mcp.TextContent); ok {
-			return v.Text, nil
-		}
-	}
-
-	return "", fmt.Errorf("No response")
+	return mcpcli.ToolResult(result)
 }
@@ -0,0 +1,131 @@
+package atm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/qiangli/ai/swarm/api"
+	"github.com/qiangli/ai/swarm/log"
+)
+
+// OpenAPIKit calls the operations of OpenAPI documents over http.
+type OpenAPIKit struct {
+	client *http.Client
+}
+
+func NewOpenAPIKit() *OpenAPIKit {
+	return &OpenAPIKit{
+		client: http.DefaultClient,
+	}
+}
+
+func (r *OpenAPIKit) Call(ctx context.Context, vars *api.Vars, _ *api.Agent, tf *api.ToolFunc, args map[string]any) (any, error) {
+	op := tf.Operation
+	if op == nil {
+		return nil, fmt.Errorf("missing OpenAPI operation: %s", tf.ID())
+	}
+	if op.BaseUrl == "" {
+		return nil, fmt.Errorf("missing base_url for OpenAPI tool: %s", tf.ID())
+	}
+
+	req, err := r.newRequest(ctx, op, args)
+	if err != nil {
+		return nil, err
+	}
+	if op.ApiKey != "" {
+		tk, err := vars.Token(op.ApiKey)
+		if err != nil {
+			return nil, err
+		}
+		if op.AuthHeader != "" {
+			req.Header.Set(op.AuthHeader, tk)
+		} else {
+			req.Header.Set("Authorization", "Bearer "+tk)
+		}
+	}
+
+	log.GetLogger(ctx).Debugf("🌐 %s %s\n", req.Method, req.URL)
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("%s %s: %s: %s", req.Method, req.URL.Path, resp.Status, strings.TrimSpace(string(data)))
+	}
+	return string(data), nil
+}
+
+// newRequest builds the http request from the arguments of the operation parameters and body.
+func (r *OpenAPIKit) newRequest(ctx context.Context, op *api.OpenAPIOperation, args map[string]any) (*http.Request, error) {
+	p := op.Path
+	query := url.Values{}
+	header := http.Header{}
+	for name, in := range op.Params {
+		v, ok := args[name]
+		if !ok || v == nil {
+			if in == api.ParamInPath {
+				return nil, fmt.Errorf("missing path parameter: %s", name)
+			}
+			continue
+		}
+		switch in {
+		case api.ParamInPath:
+			p = strings.ReplaceAll(p, "{"+name+"}", url.PathEscape(api.ToString(v)))
+		case api.ParamInQuery:
+			if list, ok := v.([]any); ok {
+				for _, e := range list {
+					query.Add(name, api.ToString(e))
+				}
+			} else {
+				query.Set(name, api.ToString(v))
+			}
+		case api.ParamInHeader:
+			header.Set(name, api.ToString(v))
+		}
+	}
+
+	u := strings.TrimSuffix(op.BaseUrl, "/") + p
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var body io.Reader
+	if v, ok := args[api.OpenAPIBodyArg]; ok && v != nil && op.ContentType != "" {
+		switch b := v.(type) {
+		case string:
+			body = strings.NewReader(b)
+		case []byte:
+			body = bytes.NewReader(b)
+		default:
+			data, err := json.Marshal(b)
+			if err != nil {
+				return nil, fmt.Errorf("invalid request body: %v", err)
+			}
+			body = bytes.NewReader(data)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, strings.ToUpper(op.Method), u, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = header
+	if body != nil {
+		req.Header.Set("Content-Type", op.ContentType)
+	}
+	req.Header.Set("Accept", "application/json, */*")
+	return req, nil
+}
@@ -0,0 +1,163 @@
+package atm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/qiangli/ai/swarm/api"
+	"github.com/qiangli/ai/swarm/atm/conf"
+)
+
+const petstoreSpec = `
+openapi: 3.0.3
+info:
+  title: Petstore
+  version: 1.0.0
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      summary: List pets
+      parameters:
+        - name: limit
+          in: query
+          schema:
+            type: integer
+      responses:
+        "200":
+          description: ok
+    post:
+      operationId: createPet
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: "#/components/schemas/Pet"
+      responses:
+        "201":
+          description: created
+  /pets/{petId}:
+    parameters:
+      - name: petId
+        in: path
+        required: true
+        schema:
+          type: string
+    get:
+      responses:
+        "200":
+          description: ok
+components:
+  schemas:
+    Pet:
+      type: object
+      required: [name]
+      properties:
+        name:
+          type: string
+        tag:
+          type: string
+`
+
+type testSecrets map[string]string
+
+func (r testSecrets) Get(owner, key string) (string, error) {
+	return r[key], nil
+}
+
+func TestOpenAPIKit(t *testing.T) {
+	type call struct {
+		method, path, query, auth, body string
+	}
+	var got call
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		got = call{r.Method, r.URL.Path, r.URL.RawQuery, r.Header.Get("Authorization"), string(b)}
+		if r.URL.Path == "/pets/missing" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	spec := filepath.Join(t.TempDir(), "petstore.yaml")
+	if err := os.WriteFile(spec, []byte(petstoreSpec), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tc := &api.AppConfig{
+		Kit:     "pets",
+		Type:    string(api.ToolTypeOpenAPI),
+		BaseUrl: server.URL,
+		ApiKey:  "pets",
+		Tools: []*api.ToolConfig{
+			{Name: "petstore", Spec: spec},
+		},
+	}
+	list, err := conf.LoadTools(tc, "", nil)
+	if err != nil {
+		t.Fatalf("LoadTools: %v", err)
+	}
+	tools := make(map[string]*api.ToolFunc)
+	for _, v := range list {
+		tools[v.Name] = v
+	}
+	for _, name := range []string{"list_pets", "create_pet", "get_pets_pet_id"} {
+		if _, ok := tools[name]; !ok {
+			t.Fatalf("missing tool %q in %v", name, tools)
+		}
+	}
+
+	// referenced schema is inlined
+	props := tools["create_pet"].Parameters["properties"].(map[string]any)
+	body, _ := json.Marshal(props["body"])
+	if string(body) != `{"properties":{"name":{"type":"string"},"tag":{"type":"string"}},"required":["name"],"type":"object"}` {
+		t.Errorf("body schema: %s", body)
+	}
+	if req := tools["get_pets_pet_id"].Parameters["required"].([]string); len(req) != 1 || req[0] != "petId" {
+		t.Errorf("required: %v", req)
+	}
+
+	vars := &api.Vars{
+		User:    &api.User{Email: "test@example.com"},
+		Secrets: testSecrets{"pets": "secret"},
+	}
+	kit := NewOpenAPIKit()
+	ctx := context.Background()
+
+	tests := []struct {
+		tool string
+		args map[string]any
+		want call
+	}{
+		{"list_pets", map[string]any{"limit": 2}, call{"GET", "/pets", "limit=2", "Bearer secret", ""}},
+		{"create_pet", map[string]any{"body": map[string]any{"name": "rex"}}, call{"POST", "/pets", "", "Bearer secret", `{"name":"rex"}`}},
+		{"get_pets_pet_id", map[string]any{"petId": "a b"}, call{"GET", "/pets/a b", "", "Bearer secret", ""}},
+	}
+	for _, tt := range tests {
+		out, err := kit.Call(ctx, vars, nil, tools[tt.tool], tt.args)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.tool, err)
+		}
+		if out != `{"ok":true}` {
+			t.Errorf("%s: output %v", tt.tool, out)
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.tool, got, tt.want)
+		}
+	}
+
+	if _, err := kit.Call(ctx, vars, nil, tools["get_pets_pet_id"], map[string]any{}); err == nil {
+		t.Errorf("expected missing path parameter error")
+	}
+	if _, err := kit.Call(ctx, vars, nil, tools["get_pets_pet_id"], map[string]any{"petId": "missing"}); err == nil {
+		t.Errorf("expected not found error")
+	}
+}
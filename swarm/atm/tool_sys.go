@@ -9,11 +9,17 @@ import (
 
 type SystemKit struct {
 	git *GitKit
+	log *LogKit
+	mcp *McpResKit
+	ses *SessionKit
 }
 
 func NewSystemKit() *SystemKit {
 	return &SystemKit{
 		git: &GitKit{},
+		log: &LogKit{},
+		mcp: &McpResKit{},
+		ses: &SessionKit{},
 	}
 }
 
@@ -22,6 +28,18 @@ func (r *SystemKit) Call(ctx context.Context, vars *api.Vars, agent *api.Agent,
 	if tf.Kit == "git" {
 		return r.git.Call(ctx, vars, agent, tf, args)
 	}
+	// dispatch log:*
+	if tf.Kit == "log" {
+		return r.log.Call(ctx, vars, agent, tf, args)
+	}
+	// dispatch mcp:*
+	if tf.Kit == "mcp" {
+		return r.mcp.Call(ctx, vars, agent, tf, args)
+	}
+	// dispatch session:*
+	if tf.Kit == "session" {
+		return r.ses.Call(ctx, vars, agent, tf, args)
+	}
 
 	// TODO refactor
 	callArgs := []any{ctx, vars, tf.Name, args}
@@ -7,9 +7,12 @@ import (
 	"maps"
 	"math"
 	"math/rand"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/expr-lang/expr"
+
 	"github.com/qiangli/ai/swarm/api"
 	"github.com/qiangli/ai/swarm/log"
 	"github.com/qiangli/ai/swarm/util"
@@ -20,6 +23,7 @@ import (
 func (r *SystemKit) Sequence(ctx context.Context, vars *api.Vars, _ string, argm api.ArgMap) (*api.Result, error) {
 	var actions = argm.Actions()
 
+	ctx = enterFlow(ctx, "sequence")
 	result, err := InternalSequence(ctx, vars.RootAgent.Runner, actions, argm)
 	if err != nil {
 		return nil, err
@@ -29,10 +33,11 @@ func (r *SystemKit) Sequence(ctx context.Context, vars *api.Vars, _ string, argm
 }
 
 // TODO merge with ai kit
+// The actions are recorded as steps of the flow scope of ctx if the run is journaled.
 func InternalSequence(ctx context.Context, runner api.ActionRunner, actions []string, argm api.ArgMap) (*api.Result, error) {
 	var result any
-	for _, v := range actions {
-		data, err := runner.Run(ctx, v, argm)
+	for i, v := range actions {
+		data, err := runStep(ctx, runner, i, v, argm)
 		if err != nil {
 			argm["error"] = err
 			return nil, err
@@ -53,6 +58,8 @@ func (r *SystemKit) Parallel(ctx context.Context, vars *api.Vars, _ string, argm
 	// needed to prevent data race issues
 	var nargs = make([]map[string]any, len(actions))
 
+	ctx = enterFlow(ctx, "parallel")
+
 	// TODO lock machinism for actions to update args thread-safe?
 	var wg sync.WaitGroup
 	for i, v := range actions {
@@ -61,7 +68,7 @@ func (r *SystemKit) Parallel(ctx context.Context, vars *api.Vars, _ string, argm
 			defer wg.Done()
 			nargs[i] = make(map[string]any)
 			maps.Copy(nargs[i], argm)
-			data, err := vars.RootAgent.Runner.Run(ctx, v, nargs[i])
+			data, err := runStep(ctx, vars.RootAgent.Runner, i, v, nargs[i])
 			if err != nil {
 				resps[i] = err.Error()
 			} else {
@@ -94,7 +101,8 @@ func (r *SystemKit) Choice(ctx context.Context, vars *api.Vars, _ string, argm a
 	which = rand.Intn(len(actions))
 
 	v := actions[which]
-	data, err := vars.RootAgent.Runner.Run(ctx, v, argm)
+	ctx = enterFlow(ctx, "choice")
+	data, err := runStep(ctx, vars.RootAgent.Runner, which, v, argm)
 	if err != nil {
 		return nil, err
 	}
@@ -116,7 +124,7 @@ func (r *SystemKit) Chain(ctx context.Context, vars *api.Vars, _ string, argm ap
 		if len(actions) == 0 {
 			return nil, nil
 		}
-		return InternalSequence(ctx, vars.RootAgent.Runner, actions, argm)
+		return InternalSequence(enterFlow(ctx, "chain"), vars.RootAgent.Runner, actions, argm)
 	}
 
 	out, err := StartChainActions(ctx, vars, chain, argm, final)
@@ -142,11 +150,12 @@ func (r *SystemKit) Loop(ctx context.Context, vars *api.Vars, _ string, argm api
 	}
 	msg := argm.GetString("report")
 
+	ctx = enterFlow(ctx, "loop")
 	for i := 1; i < max; i++ {
 		if msg != "" {
 			log.GetLogger(ctx).Infof("%s\n", msg)
 		}
-		result, err = InternalSequence(ctx, vars.RootAgent.Runner, actions, argm)
+		result, err = InternalSequence(flowBranch(ctx, i), vars.RootAgent.Runner, actions, argm)
 		if err != nil {
 			return nil, err
 		}
@@ -159,8 +168,9 @@ func (r *SystemKit) Loop(ctx context.Context, vars *api.Vars, _ string, argm api
 func (r *SystemKit) Fallback(ctx context.Context, vars *api.Vars, _ string, argm api.ArgMap) (*api.Result, error) {
 	var actions = argm.Actions()
 	var respErr error
-	for _, v := range actions {
-		result, err := vars.RootAgent.Runner.Run(ctx, v, argm)
+	ctx = enterFlow(ctx, "fallback")
+	for i, v := range actions {
+		result, err := runStep(ctx, vars.RootAgent.Runner, i, v, argm)
 		if err == nil {
 			return api.ToResult(result), nil
 		}
@@ -168,3 +178,258 @@ func (r *SystemKit) Fallback(ctx context.Context, vars *api.Vars, _ string, argm
 	}
 	return nil, respErr
 }
+
+// default number of items processed at the same time by flow:map
+const defaultMapConcurrency = 4
+
+// MapResult is the outcome of applying the actions to one item.
+type MapResult struct {
+	Index  int    `json:"index"`
+	Item   any    `json:"item"`
+	Result string `json:"result,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// FlowType Map applies the actions in sequence to each element of the items,
+// at most max_concurrency items at a time. The item and its position are available as "item" and "index"
+// in the arguments of the actions; the item is also the message unless a message is provided.
+// Failures are collected per item, an error is returned only if all items failed.
+func (r *SystemKit) Map(ctx context.Context, vars *api.Vars, _ string, argm api.ArgMap) (*api.Result, error) {
+	var actions = argm.Actions()
+	if len(actions) == 0 {
+		return nil, fmt.Errorf("actions is required")
+	}
+	items, err := flowItems(argm)
+	if err != nil {
+		return nil, err
+	}
+	limit := argm.GetInt("max_concurrency")
+	if limit <= 0 {
+		limit = defaultMapConcurrency
+	}
+	_, hasMessage := argm["message"]
+
+	ctx = enterFlow(ctx, "map")
+
+	var results = make([]*MapResult, len(items))
+	var sem = make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i, item := range items {
+		wg.Add(1)
+		go func(i int, item any) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			nargs := make(map[string]any)
+			maps.Copy(nargs, argm)
+			nargs["item"] = item
+			nargs["index"] = i
+			if !hasMessage {
+				nargs["message"] = api.ToString(item)
+			}
+
+			res := &MapResult{Index: i, Item: item}
+			if ctx.Err() != nil {
+				res.Error = ctx.Err().Error()
+			} else if out, err := InternalSequence(flowBranch(ctx, i), vars.RootAgent.Runner, actions, nargs); err != nil {
+				res.Error = err.Error()
+			} else if out != nil {
+				res.Result = out.Value
+			}
+			results[i] = res
+		}(i, item)
+	}
+	wg.Wait()
+
+	var errs []string
+	for _, v := range results {
+		if v.Error != "" {
+			errs = append(errs, fmt.Sprintf("item %v: %s", v.Index, v.Error))
+		}
+	}
+	if len(items) > 0 && len(errs) == len(items) {
+		return nil, fmt.Errorf("all items failed:\n%s", strings.Join(errs, "\n"))
+	}
+	if len(errs) > 0 {
+		log.GetLogger(ctx).Infof("map: %v of %v items failed\n", len(errs), len(items))
+	}
+
+	data, err := json.Marshal(results)
+	if err != nil {
+		return nil, err
+	}
+	return &api.Result{
+		Value: string(data),
+		Data:  results,
+	}, nil
+}
+
+// FlowType Reduce applies the actions in sequence to each element of the items one after another,
+// accumulating the results. The accumulated value is available as "accumulator" along with "item" and "index"
+// in the arguments of the actions and is replaced by the result of each step.
+// The initial value is taken from "initial", otherwise the result of a previous action or the message.
+func (r *SystemKit) Reduce(ctx context.Context, vars *api.Vars, _ string, argm api.ArgMap) (*api.Result, error) {
+	var actions = argm.Actions()
+	if len(actions) == 0 {
+		return nil, fmt.Errorf("actions is required")
+	}
+	items, err := flowItems(argm)
+	if err != nil {
+		return nil, err
+	}
+
+	var acc string
+	if v, ok := argm["initial"]; ok {
+		acc = api.ToString(v)
+	} else if v, ok := argm["result"]; ok {
+		acc = api.ToString(v)
+	} else {
+		acc = argm.GetString("message")
+	}
+
+	ctx = enterFlow(ctx, "reduce")
+	for i, item := range items {
+		argm["accumulator"] = acc
+		argm["item"] = item
+		argm["index"] = i
+		out, err := InternalSequence(flowBranch(ctx, i), vars.RootAgent.Runner, actions, argm)
+		if err != nil {
+			return nil, fmt.Errorf("item %v: %w", i, err)
+		}
+		if out != nil {
+			acc = out.Value
+		}
+	}
+	argm["accumulator"] = acc
+
+	return &api.Result{
+		Value: acc,
+	}, nil
+}
+
+// flowItems returns the items as a list.
+// items may be an array, a JSON array string or text with one item per line.
+func flowItems(argm api.ArgMap) ([]any, error) {
+	v, ok := argm["items"]
+	if !ok || v == nil {
+		return nil, fmt.Errorf("items is required")
+	}
+	switch vt := v.(type) {
+	case []any:
+		return vt, nil
+	case []string:
+		var list []any
+		for _, s := range vt {
+			list = append(list, s)
+		}
+		return list, nil
+	case string:
+		var list []any
+		if err := json.Unmarshal([]byte(vt), &list); err == nil {
+			return list, nil
+		}
+		for _, line := range strings.Split(vt, "\n") {
+			if s := strings.TrimSpace(line); s != "" {
+				list = append(list, s)
+			}
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("invalid items: %T", v)
+}
+
+// FlowType If evaluates the boolean expression "condition" against the arguments
+// and runs the "then" action(s) if true, otherwise the "else" action(s) if any.
+// The previous result is passed through if no action is run.
+//
+// Example: result contains 'FAIL'
+func (r *SystemKit) If(ctx context.Context, vars *api.Vars, _ string, argm api.ArgMap) (*api.Result, error) {
+	condition := argm.GetString("condition")
+	if condition == "" {
+		return nil, fmt.Errorf("condition is required")
+	}
+	v, err := evalFlowExpr(condition, argm, expr.AsBool())
+	if err != nil {
+		return nil, err
+	}
+
+	branch := "else"
+	if v.(bool) {
+		branch = "then"
+	}
+	log.GetLogger(ctx).Debugf("if %q: %s\n", condition, branch)
+
+	actions := api.ToStringArray(argm[branch])
+	if len(actions) == 0 {
+		return api.ToResult(argm["result"]), nil
+	}
+	return InternalSequence(enterFlow(ctx, "if"), vars.RootAgent.Runner, actions, argm)
+}
+
+// FlowType Switch evaluates the "expression" against the arguments and runs the action(s)
+// of the case matching the value, otherwise the "default" action(s) if any.
+// Case keys are compared with the string form of the value.
+func (r *SystemKit) Switch(ctx context.Context, vars *api.Vars, _ string, argm api.ArgMap) (*api.Result, error) {
+	expression := argm.GetString("expression")
+	if expression == "" {
+		return nil, fmt.Errorf("expression is required")
+	}
+	var cases = make(map[string]any)
+	switch vt := argm["cases"].(type) {
+	case string:
+		if err := json.Unmarshal([]byte(vt), &cases); err != nil {
+			return nil, fmt.Errorf("invalid cases: %v", err)
+		}
+	default:
+		v, err := api.ToMap(vt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cases: %v", err)
+		}
+		cases = v
+	}
+	v, err := evalFlowExpr(expression, argm)
+	if err != nil {
+		return nil, err
+	}
+
+	key := api.ToString(v)
+	var actions []string
+	if c, ok := cases[key]; ok {
+		actions = api.ToStringArray(c)
+	} else {
+		actions = api.ToStringArray(argm["default"])
+	}
+	log.GetLogger(ctx).Debugf("switch %q: %q %v\n", expression, key, actions)
+
+	if len(actions) == 0 {
+		return api.ToResult(argm["result"]), nil
+	}
+	return InternalSequence(enterFlow(ctx, "switch"), vars.RootAgent.Runner, actions, argm)
+}
+
+// evalFlowExpr evaluates the expr expression with the arguments as the environment.
+// result is the text of the previous result and data its structured output if any.
+func evalFlowExpr(code string, argm api.ArgMap, opts ...expr.Option) (any, error) {
+	env := make(map[string]any, len(argm)+1)
+	maps.Copy(env, argm)
+	if v, ok := argm["result"]; ok {
+		if res, ok := v.(*api.Result); ok && res != nil {
+			if res.Data != nil {
+				env["data"] = res.Data
+			}
+		}
+		env["result"] = api.ToString(v)
+	}
+	// undefined variables evaluate to nil instead of failing to compile
+	opts = append([]expr.Option{expr.Env(env), expr.AllowUndefinedVariables()}, opts...)
+	program, err := expr.Compile(code, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", code, err)
+	}
+	v, err := expr.Run(program, env)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate %q: %w", code, err)
+	}
+	return v, nil
+}
@@ -0,0 +1,187 @@
+package atm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/qiangli/ai/swarm/api"
+)
+
+// flowRunner upper-cases or lower-cases the message, fails on "bad" and concatenates for reduce.
+type flowRunner struct {
+	running atomic.Int32
+	peak    atomic.Int32
+	// a step was run with the stream handler
+	streamed atomic.Bool
+}
+
+func (r *flowRunner) Run(ctx context.Context, tid string, args map[string]any) (any, error) {
+	if api.GetStreamHandler(ctx) != nil {
+		r.streamed.Store(true)
+	}
+	n := r.running.Add(1)
+	defer r.running.Add(-1)
+	for {
+		p := r.peak.Load()
+		if n <= p || r.peak.CompareAndSwap(p, n) {
+			break
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	switch tid {
+	case "test:upper":
+		msg := api.ToString(args["message"])
+		if msg == "bad" {
+			return nil, fmt.Errorf("bad item")
+		}
+		return &api.Result{Value: strings.ToUpper(msg)}, nil
+	case "test:lower":
+		return &api.Result{Value: strings.ToLower(api.ToString(args["message"]))}, nil
+	case "test:join":
+		return &api.Result{Value: fmt.Sprintf("%v+%v", args["accumulator"], args["item"])}, nil
+	}
+	return nil, fmt.Errorf("unknown action: %s", tid)
+}
+
+func TestFlowMap(t *testing.T) {
+	runner := &flowRunner{}
+	vars := &api.Vars{RootAgent: &api.Agent{Runner: runner}}
+	kit := &SystemKit{}
+
+	argm := api.ArgMap{
+		"actions":         []string{"test:upper"},
+		"items":           "a\nbad\nc\nd\ne\n",
+		"max_concurrency": 2,
+	}
+	ctx := api.WithStreamHandler(context.TODO(), func(*api.StreamEvent) {})
+	result, err := kit.Map(ctx, vars, "map", argm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, ok := result.Data.([]*MapResult)
+	if !ok || len(list) != 5 {
+		t.Fatalf("expected 5 results, got %v", result.Data)
+	}
+	if list[0].Result != "A" || list[4].Result != "E" {
+		t.Errorf("unexpected results: %s", result.Value)
+	}
+	if list[1].Error == "" || list[1].Result != "" {
+		t.Errorf("expected error for item 1: %+v", list[1])
+	}
+	if p := runner.peak.Load(); p > 2 {
+		t.Errorf("concurrency exceeded: %v", p)
+	}
+	if runner.streamed.Load() {
+		t.Errorf("steps run with the stream handler")
+	}
+
+	// all failed
+	argm["items"] = []any{"bad"}
+	if _, err := kit.Map(context.TODO(), vars, "map", argm); err == nil {
+		t.Errorf("expected error when all items fail")
+	}
+}
+
+func TestFlowReduce(t *testing.T) {
+	vars := &api.Vars{RootAgent: &api.Agent{Runner: &flowRunner{}}}
+	kit := &SystemKit{}
+
+	argm := api.ArgMap{
+		"actions": []string{"test:join"},
+		"items":   `["a","b","c"]`,
+		"initial": "0",
+	}
+	result, err := kit.Reduce(context.TODO(), vars, "reduce", argm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Value != "0+a+b+c" {
+		t.Errorf("unexpected result: %q", result.Value)
+	}
+
+	delete(argm, "items")
+	if _, err := kit.Reduce(context.TODO(), vars, "reduce", argm); err == nil {
+		t.Errorf("expected error without items")
+	}
+}
+
+func TestFlowIf(t *testing.T) {
+	vars := &api.Vars{RootAgent: &api.Agent{Runner: &flowRunner{}}}
+	kit := &SystemKit{}
+
+	// then upper-cases, else lower-cases the message
+	tests := []struct {
+		result any
+		want   string
+	}{
+		{&api.Result{Value: "tests FAIL"}, "FIX IT"},
+		{"all ok", "fix it"},
+	}
+	for _, tt := range tests {
+		argm := api.ArgMap{
+			"condition": "result contains 'FAIL'",
+			"then":      "test:upper",
+			"else":      []any{"test:lower"},
+			"result":    tt.result,
+			"message":   "Fix It",
+		}
+		got, err := kit.If(context.TODO(), vars, "if", argm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Value != tt.want {
+			t.Errorf("got %q want %q", got.Value, tt.want)
+		}
+	}
+
+	// no else: pass through
+	argm := api.ArgMap{"condition": "result == 'x'", "then": "test:upper", "result": "y"}
+	got, err := kit.If(context.TODO(), vars, "if", argm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Value != "y" {
+		t.Errorf("expected previous result, got %q", got.Value)
+	}
+
+	argm["condition"] = "1 + 1"
+	if _, err := kit.If(context.TODO(), vars, "if", argm); err == nil {
+		t.Errorf("expected error for non boolean condition")
+	}
+}
+
+func TestFlowSwitch(t *testing.T) {
+	vars := &api.Vars{RootAgent: &api.Agent{Runner: &flowRunner{}}}
+	kit := &SystemKit{}
+
+	argm := api.ArgMap{
+		"expression": "data.category",
+		"cases":      `{"bug":"test:upper","docs":"test:none"}`,
+		"default":    "test:join",
+		"message":    "fix it",
+		"result":     &api.Result{Value: `{"category":"bug"}`, Data: map[string]any{"category": "bug"}},
+	}
+	got, err := kit.Switch(context.TODO(), vars, "switch", argm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Value != "FIX IT" {
+		t.Errorf("unexpected result: %q", got.Value)
+	}
+
+	argm["expression"] = "len(message)"
+	argm["item"] = "x"
+	argm["accumulator"] = "y"
+	got, err = kit.Switch(context.TODO(), vars, "switch", argm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Value != "y+x" {
+		t.Errorf("expected default action, got %q", got.Value)
+	}
+}
@@ -0,0 +1,216 @@
+package atm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/qiangli/ai/swarm/api"
+	"github.com/qiangli/ai/swarm/log"
+)
+
+// LogKit queries the call log: log:search, log:stats, log:show and log:replay.
+type LogKit struct {
+}
+
+func (r *LogKit) Call(ctx context.Context, vars *api.Vars, agent *api.Agent, tf *api.ToolFunc, args map[string]any) (any, error) {
+	callArgs := []any{ctx, vars, agent, tf, api.ArgMap(args)}
+	v, err := CallKit(r, tf.Kit, tf.Name, callArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call tool %s:%s error: %w", tf.Kit, tf.Name, err)
+	}
+	return v, err
+}
+
+// default number of calls listed by log:search
+const defaultSearchCalls = 50
+
+func callLogStore(vars *api.Vars) (api.CallLogStore, error) {
+	store, ok := vars.Log.(api.CallLogStore)
+	if !ok {
+		return nil, fmt.Errorf("call log is not searchable")
+	}
+	return store, nil
+}
+
+// callLogQuery builds the query from the filter arguments:
+// agent, toolkit and tool glob patterns, status ok or error,
+// since and until as time, date or duration before now, e.g. 24h.
+func callLogQuery(argm api.ArgMap) (*api.CallLogQuery, error) {
+	q := &api.CallLogQuery{
+		Kit:    argm.GetString("toolkit"),
+		Name:   argm.GetString("tool"),
+		Status: argm.GetString("status"),
+		Limit:  argm.GetInt("limit"),
+	}
+	// the agent argument may be an agent instance
+	if v, ok := argm["agent"].(string); ok {
+		q.Agent = v
+	}
+	switch q.Status {
+	case "", api.CallStatusOK, api.CallStatusError:
+	default:
+		return nil, fmt.Errorf("invalid status: %q. supported: ok, error", q.Status)
+	}
+	var err error
+	if q.Since, err = parseLogTime(argm.GetString("since")); err != nil {
+		return nil, err
+	}
+	if q.Until, err = parseLogTime(argm.GetString("until")); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+func parseLogTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	for _, layout := range []string{time.RFC3339, time.DateTime, time.DateOnly} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time: %q. expected duration (24h), date (2006-01-02) or time (2006-01-02 15:04:05)", s)
+}
+
+// Search lists the recorded tool calls matching the filters, most recent first.
+func (r *LogKit) Search(ctx context.Context, vars *api.Vars, _ *api.Agent, _ *api.ToolFunc, argm api.ArgMap) (*api.Result, error) {
+	store, err := callLogStore(vars)
+	if err != nil {
+		return nil, err
+	}
+	q, err := callLogQuery(argm)
+	if err != nil {
+		return nil, err
+	}
+	if q.Limit <= 0 {
+		q.Limit = defaultSearchCalls
+	}
+	calls, err := store.Search(q)
+	if err != nil {
+		return nil, err
+	}
+	if len(calls) == 0 {
+		return api.ToResult("No calls found\n"), nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%-6s %-19s %-24s %-30s %-6s %10s %s\n", "ID", "STARTED", "AGENT", "TOOL", "STATUS", "LATENCY", "ARGUMENTS")
+	for _, v := range calls {
+		status := api.CallStatusOK
+		if v.Error != nil {
+			status = api.CallStatusError
+		}
+		fmt.Fprintf(&sb, "%-6v %-19s %-24s %-30s %-6s %10s %s\n", v.ID, v.Started.Local().Format(time.DateTime), clip(v.Agent, 24),
+			clip(v.Kit+":"+v.Name, 30), status, callLatency(v), clip(oneLine(api.FormatArgMap(v.Arguments)), 60))
+	}
+	return &api.Result{
+		Value: sb.String(),
+		Data:  calls,
+	}, nil
+}
+
+// Stats summarizes the latency of the matching calls by tool.
+func (r *LogKit) Stats(ctx context.Context, vars *api.Vars, _ *api.Agent, _ *api.ToolFunc, argm api.ArgMap) (*api.Result, error) {
+	store, err := callLogStore(vars)
+	if err != nil {
+		return nil, err
+	}
+	q, err := callLogQuery(argm)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := store.Stats(q)
+	if err != nil {
+		return nil, err
+	}
+	if len(stats) == 0 {
+		return api.ToResult("No calls found\n"), nil
+	}
+
+	round := func(d time.Duration) time.Duration {
+		return d.Round(time.Millisecond)
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%-30s %6s %6s %10s %10s %10s %10s\n", "TOOL", "CALLS", "ERRORS", "MEAN", "P50", "P95", "MAX")
+	for _, v := range stats {
+		fmt.Fprintf(&sb, "%-30s %6v %6v %10s %10s %10s %10s\n", clip(v.Kit+":"+v.Name, 30), v.Count, v.Errors,
+			round(v.Mean), round(v.P50), round(v.P95), round(v.Max))
+	}
+	return &api.Result{
+		Value: sb.String(),
+		Data:  stats,
+	}, nil
+}
+
+// Show prints a recorded call with its arguments and result.
+func (r *LogKit) Show(ctx context.Context, vars *api.Vars, _ *api.Agent, _ *api.ToolFunc, argm api.ArgMap) (*api.Result, error) {
+	call, err := getCall(vars, argm)
+	if err != nil {
+		return nil, err
+	}
+	args, _ := json.MarshalIndent(call.Arguments, "", "  ")
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "ID: %v\nAgent: %s\nTool: %s:%s\nStarted: %s\nLatency: %s\n", call.ID, call.Agent, call.Kit, call.Name,
+		call.Started.Local().Format(time.DateTime), callLatency(call))
+	for _, v := range [][2]string{{"Cache", call.Cache}, {"Policy", call.Policy}, {"Approval", call.Approval}} {
+		if v[1] != "" {
+			fmt.Fprintf(&sb, "%s: %s\n", v[0], v[1])
+		}
+	}
+	fmt.Fprintf(&sb, "Arguments:\n%s\n", args)
+	if call.Error != nil {
+		fmt.Fprintf(&sb, "Error: %v\n", call.Error)
+	}
+	if call.Result != nil {
+		fmt.Fprintf(&sb, "Result:\n%s\n", call.Result.Value)
+	}
+	return &api.Result{
+		Value: sb.String(),
+		Data:  call,
+	}, nil
+}
+
+// Replay calls the tool of a recorded call again with the original arguments.
+func (r *LogKit) Replay(ctx context.Context, vars *api.Vars, _ *api.Agent, _ *api.ToolFunc, argm api.ArgMap) (any, error) {
+	call, err := getCall(vars, argm)
+	if err != nil {
+		return nil, err
+	}
+	if vars.RootAgent == nil || vars.RootAgent.Runner == nil {
+		return nil, fmt.Errorf("no runner to replay call %v", call.ID)
+	}
+	args := make(map[string]any, len(call.Arguments))
+	for k, v := range call.Arguments {
+		args[k] = v
+	}
+	tid := api.NewKitname(call.Kit, call.Name).String()
+	log.GetLogger(ctx).Infof("↻ replay %v %s:%s\n", call.ID, call.Kit, call.Name)
+	return vars.RootAgent.Runner.Run(ctx, tid, args)
+}
+
+func getCall(vars *api.Vars, argm api.ArgMap) (*api.CallLogEntry, error) {
+	store, err := callLogStore(vars)
+	if err != nil {
+		return nil, err
+	}
+	id := argm.GetInt64("id")
+	if id <= 0 {
+		return nil, fmt.Errorf("call id is required as listed by log:search")
+	}
+	return store.Get(id)
+}
+
+func callLatency(v *api.CallLogEntry) time.Duration {
+	if v.Ended.IsZero() {
+		return 0
+	}
+	return v.Ended.Sub(v.Started).Round(time.Millisecond)
+}
@@ -0,0 +1,96 @@
+package atm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qiangli/ai/swarm/api"
+	"github.com/qiangli/ai/swarm/db"
+)
+
+// replayRunner records the replayed call.
+type replayRunner struct {
+	tid  string
+	args map[string]any
+}
+
+func (r *replayRunner) Run(ctx context.Context, tid string, args map[string]any) (any, error) {
+	r.tid = tid
+	r.args = args
+	return &api.Result{Value: "replayed"}, nil
+}
+
+func TestLogKit(t *testing.T) {
+	store, err := db.OpenCallLog(t.TempDir(), "calllog.db", "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	now := time.Now()
+	store.Save(&api.CallLogEntry{
+		Agent:     "ops/deploy",
+		Kit:       "fs",
+		Name:      "read_file",
+		Arguments: map[string]any{"path": "/tmp/a"},
+		Started:   now.Add(-2 * time.Hour),
+		Ended:     now.Add(-2*time.Hour + 20*time.Millisecond),
+	})
+	store.Save(&api.CallLogEntry{
+		Agent:     "ops/deploy",
+		Kit:       "sh",
+		Name:      "exec",
+		Arguments: map[string]any{"command": "false"},
+		Error:     fmt.Errorf("exit status 1"),
+		Started:   now.Add(-time.Minute),
+		Ended:     now,
+	})
+
+	runner := &replayRunner{}
+	vars := &api.Vars{Log: store, RootAgent: &api.Agent{Runner: runner}}
+	kit := &LogKit{}
+	ctx := context.TODO()
+
+	result, err := kit.Search(ctx, vars, nil, nil, api.ArgMap{"since": "1h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := result.Data.([]*api.CallLogEntry)
+	if len(calls) != 1 || calls[0].Kit != "sh" || !strings.Contains(result.Value, "error") {
+		t.Errorf("unexpected search result: %s", result.Value)
+	}
+	if _, err := kit.Search(ctx, vars, nil, nil, api.ArgMap{"status": "failed"}); err == nil {
+		t.Errorf("expected invalid status")
+	}
+	if _, err := kit.Search(ctx, vars, nil, nil, api.ArgMap{"since": "yesterday"}); err == nil {
+		t.Errorf("expected invalid time")
+	}
+
+	result, err = kit.Stats(ctx, vars, nil, nil, api.ArgMap{"toolkit": "fs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := result.Data.([]*api.CallLogStats)
+	if len(stats) != 1 || stats[0].Count != 1 || stats[0].Max != 20*time.Millisecond {
+		t.Errorf("unexpected stats: %s", result.Value)
+	}
+
+	out, err := kit.Replay(ctx, vars, nil, nil, api.ArgMap{"id": calls[0].ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runner.tid != "sh:exec" || runner.args["command"] != "false" || out.(*api.Result).Value != "replayed" {
+		t.Errorf("unexpected replay: %s %v", runner.tid, runner.args)
+	}
+	if _, err := kit.Show(ctx, vars, nil, nil, api.ArgMap{}); err == nil {
+		t.Errorf("expected missing id")
+	}
+
+	// file call logs can't be searched
+	if _, err := kit.Search(ctx, &api.Vars{}, nil, nil, api.ArgMap{}); err == nil {
+		t.Errorf("expected error without call log store")
+	}
+}
@@ -0,0 +1,213 @@
+package atm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/qiangli/ai/swarm/api"
+	"github.com/qiangli/ai/swarm/atm/conf"
+	mcpcli "github.com/qiangli/ai/swarm/mcp"
+)
+
+// prefix of agent context and instruction read from a MCP resource: mcp:<kit>:<uri>
+const McpResourcePrefix = "mcp:"
+
+// McpResKit reaches the resources and prompts of the MCP servers of a tool kit:
+// mcp:list_resources, mcp:read_resource, mcp:list_prompts and mcp:get_prompt.
+type McpResKit struct {
+}
+
+func (r *McpResKit) Call(ctx context.Context, vars *api.Vars, agent *api.Agent, tf *api.ToolFunc, args map[string]any) (any, error) {
+	callArgs := []any{ctx, vars, agent, tf, api.ArgMap(args)}
+	v, err := CallKit(r, tf.Kit, tf.Name, callArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call tool %s:%s error: %w", tf.Kit, tf.Name, err)
+	}
+	return v, err
+}
+
+// mcpServer is a MCP server of a tool kit with its resolved token.
+type mcpServer struct {
+	cfg   *api.ConnectorConfig
+	token string
+}
+
+// mcpServers returns the MCP servers declared by the tool kit.
+func mcpServers(vars *api.Vars, kit string) ([]*mcpServer, error) {
+	if kit == "" {
+		return nil, fmt.Errorf("server is required: the name of a tool kit of type mcp")
+	}
+	tc, err := vars.Assets.FindToolkit(vars.User.Email, kit)
+	if err != nil {
+		return nil, err
+	}
+	if tc == nil {
+		return nil, fmt.Errorf("tool kit not found: %s", kit)
+	}
+	var list []*mcpServer
+	for _, cfg := range conf.McpConnectors(tc) {
+		var tk string
+		if cfg.ApiKey != "" {
+			if tk, err = vars.Token(cfg.ApiKey); err != nil {
+				return nil, err
+			}
+		}
+		list = append(list, &mcpServer{cfg: cfg, token: tk})
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("no MCP server in tool kit: %s", kit)
+	}
+	return list, nil
+}
+
+func (r *McpResKit) ListResources(ctx context.Context, vars *api.Vars, _ *api.Agent, _ *api.ToolFunc, argm api.ArgMap) (*api.Result, error) {
+	servers, err := mcpServers(vars, argm.GetString("server"))
+	if err != nil {
+		return nil, err
+	}
+	var sb strings.Builder
+	for _, s := range servers {
+		list, err := mcpcli.DefaultPool.Resources(ctx, s.cfg, s.token)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range list {
+			fmt.Fprintf(&sb, "%s\t%s\t%s\t%s\n", v.URI, v.Name, v.MIMEType, v.Description)
+		}
+	}
+	if sb.Len() == 0 {
+		return api.ToResult("No resources found\n"), nil
+	}
+	return api.ToResult(sb.String()), nil
+}
+
+func (r *McpResKit) ReadResource(ctx context.Context, vars *api.Vars, _ *api.Agent, _ *api.ToolFunc, argm api.ArgMap) (*api.Result, error) {
+	uri := argm.GetString("uri")
+	if uri == "" {
+		return nil, fmt.Errorf("uri is required as listed by mcp:list_resources")
+	}
+	s, err := ReadMcpResource(ctx, vars, argm.GetString("server"), uri)
+	if err != nil {
+		return nil, err
+	}
+	return api.ToResult(s), nil
+}
+
+func (r *McpResKit) ListPrompts(ctx context.Context, vars *api.Vars, _ *api.Agent, _ *api.ToolFunc, argm api.ArgMap) (*api.Result, error) {
+	servers, err := mcpServers(vars, argm.GetString("server"))
+	if err != nil {
+		return nil, err
+	}
+	var sb strings.Builder
+	for _, s := range servers {
+		list, err := mcpcli.DefaultPool.Prompts(ctx, s.cfg, s.token)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range list {
+			var params []string
+			for _, a := range v.Arguments {
+				p := a.Name
+				if !a.Required {
+					p += "?"
+				}
+				params = append(params, p)
+			}
+			fmt.Fprintf(&sb, "%s(%s)\t%s\n", v.Name, strings.Join(params, ", "), v.Description)
+		}
+	}
+	if sb.Len() == 0 {
+		return api.ToResult("No prompts found\n"), nil
+	}
+	return api.ToResult(sb.String()), nil
+}
+
+func (r *McpResKit) GetPrompt(ctx context.Context, vars *api.Vars, _ *api.Agent, _ *api.ToolFunc, argm api.ArgMap) (*api.Result, error) {
+	name := argm.GetString("prompt")
+	if name == "" {
+		return nil, fmt.Errorf("prompt is required as listed by mcp:list_prompts")
+	}
+	var params = make(map[string]string)
+	if v, ok := argm["arguments"]; ok && v != nil {
+		m, err := api.ToMap(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid prompt arguments: %v", err)
+		}
+		for k, v := range m {
+			params[k] = api.ToString(v)
+		}
+	}
+	s, err := GetMcpPrompt(ctx, vars, argm.GetString("server"), name, params)
+	if err != nil {
+		return nil, err
+	}
+	return api.ToResult(s), nil
+}
+
+// ReadMcpResource returns the content of the resource from the first MCP server of the kit that has it.
+func ReadMcpResource(ctx context.Context, vars *api.Vars, kit, uri string) (string, error) {
+	servers, err := mcpServers(vars, kit)
+	if err != nil {
+		return "", err
+	}
+	var errs []error
+	for _, s := range servers {
+		result, err := mcpcli.DefaultPool.ReadResource(ctx, s.cfg, s.token, uri)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		return mcpcli.ResourceText(result), nil
+	}
+	return "", fmt.Errorf("failed to read resource %s: %w", uri, errors.Join(errs...))
+}
+
+// GetMcpPrompt returns the messages of the prompt from the first MCP server of the kit that has it.
+func GetMcpPrompt(ctx context.Context, vars *api.Vars, kit, name string, args map[string]string) (string, error) {
+	servers, err := mcpServers(vars, kit)
+	if err != nil {
+		return "", err
+	}
+	var errs []error
+	for _, s := range servers {
+		result, err := mcpcli.DefaultPool.GetPrompt(ctx, s.cfg, s.token, name, args)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		return mcpcli.PromptText(result), nil
+	}
+	return "", fmt.Errorf("failed to get prompt %s: %w", name, errors.Join(errs...))
+}
+
+// ResolveMcpResource returns the content of the resource referenced as mcp:<kit>:<uri>, e.g.
+// mcp:docs:file:///README.md; other strings are returned as is.
+func ResolveMcpResource(ctx context.Context, vars *api.Vars, s string) (string, error) {
+	if !strings.HasPrefix(s, McpResourcePrefix) {
+		return s, nil
+	}
+	kit, uri, ok := strings.Cut(strings.TrimSpace(s[len(McpResourcePrefix):]), ":")
+	if !ok || kit == "" || uri == "" {
+		return "", fmt.Errorf("invalid MCP resource reference: %q. expected mcp:<kit>:<uri>", s)
+	}
+	return ReadMcpResource(ctx, vars, kit, uri)
+}
+
+// mcp lists as text for templates
+func listMcpResources(ctx context.Context, vars *api.Vars, kit string) (string, error) {
+	v, err := (&McpResKit{}).ListResources(ctx, vars, nil, nil, api.ArgMap{"server": kit})
+	if err != nil {
+		return "", err
+	}
+	return v.Value, nil
+}
+
+func listMcpPrompts(ctx context.Context, vars *api.Vars, kit string) (string, error) {
+	v, err := (&McpResKit{}).ListPrompts(ctx, vars, nil, nil, api.ArgMap{"server": kit})
+	if err != nil {
+		return "", err
+	}
+	return v.Value, nil
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/signal"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -102,11 +103,18 @@ func (r *SystemKit) ServeLoop(ctx context.Context, vars *api.Vars, name string,
 	}
 	var lastIn string
 
+	// true if the current response has been streamed to stdout
+	var streamed bool
+
 	serveWriteOutputs := func(out string) error {
 		for _, dst := range outputs {
 			dst = strings.TrimSpace(dst)
 			switch {
 			case dst == "stdout":
+				if streamed {
+					fmt.Fprint(os.Stdout, "\n")
+					continue
+				}
 				fmt.Fprint(os.Stdout, out)
 			case dst == "clipboard":
 				if err := clipboard.WriteAll(out); err != nil {
@@ -194,10 +202,14 @@ func (r *SystemKit) ServeLoop(ctx context.Context, vars *api.Vars, name string,
 			continue
 		}
 
-		out, err = serveRunAction(ctx, vars, action, format, payload)
+		// stream LLM output to stdout as it arrives
+		sctx, sout := serveStream(ctx, outputs, input == "stdin")
+
+		out, err = serveRunAction(sctx, vars, action, format, payload)
 		if err != nil {
 			out = fmt.Sprintf("%v\n", err)
 		} else {
+			streamed = sout.Streamed(out)
 			out = out + "\n"
 		}
 
@@ -210,6 +222,7 @@ func (r *SystemKit) ServeLoop(ctx context.Context, vars *api.Vars, name string,
 			// best effort
 			_, _ = fmt.Fprintln(os.Stderr, err.Error())
 		}
+		streamed = false
 
 		// in clipboard mode, small sleep to avoid busy loop
 		if input == "clipboard" {
@@ -218,6 +231,52 @@ func (r *SystemKit) ServeLoop(ctx context.Context, vars *api.Vars, name string,
 	}
 }
 
+// serveStdout collects the text streamed to stdout for a single request.
+type serveStdout struct {
+	mu     sync.Mutex
+	prompt bool
+	buf    strings.Builder
+}
+
+func (r *serveStdout) Handle(ev *api.StreamEvent) {
+	if ev.Type != api.StreamEventText {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.buf.Len() == 0 && r.prompt {
+		fmt.Fprint(os.Stdout, "ai> ")
+	}
+	fmt.Fprint(os.Stdout, ev.Text)
+	r.buf.WriteString(ev.Text)
+}
+
+// Streamed returns true if out has already been written to stdout.
+func (r *serveStdout) Streamed(out string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	out = strings.TrimSpace(out)
+	if out == "" {
+		return false
+	}
+	return strings.HasSuffix(strings.TrimSpace(r.buf.String()), out)
+}
+
+// serveStream installs a stream handler if stdout is one of the outputs.
+func serveStream(ctx context.Context, outputs []string, prompt bool) (context.Context, *serveStdout) {
+	var sout = &serveStdout{
+		prompt: prompt,
+	}
+	for _, dst := range outputs {
+		if strings.TrimSpace(dst) == "stdout" {
+			return api.WithStreamHandler(ctx, sout.Handle), sout
+		}
+	}
+	return ctx, sout
+}
+
 func serveInit(ctx context.Context, vars *api.Vars, input string) error {
 	switch {
 	case input == "stdin":
@@ -0,0 +1,217 @@
+package atm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/qiangli/ai/swarm/api"
+)
+
+// SessionKit manages the saved conversations:
+// session:list, session:show, session:fork, session:delete and session:export.
+type SessionKit struct {
+}
+
+func (r *SessionKit) Call(ctx context.Context, vars *api.Vars, agent *api.Agent, tf *api.ToolFunc, args map[string]any) (any, error) {
+	callArgs := []any{ctx, vars, agent, tf, api.ArgMap(args)}
+	v, err := CallKit(r, tf.Kit, tf.Name, callArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call tool %s:%s error: %w", tf.Kit, tf.Name, err)
+	}
+	return v, err
+}
+
+func sessionStore(vars *api.Vars) (api.SessionStore, error) {
+	store, ok := vars.History.(api.SessionStore)
+	if !ok {
+		return nil, fmt.Errorf("sessions are not supported by the memory store")
+	}
+	return store, nil
+}
+
+// sessionID returns the id argument, the current session if empty.
+func sessionID(vars *api.Vars, argm api.ArgMap) api.SessionID {
+	if id := argm.GetString("id"); id != "" {
+		return api.SessionID(id)
+	}
+	return vars.SessionID
+}
+
+// List lists the sessions, most recently updated first.
+func (r *SessionKit) List(ctx context.Context, vars *api.Vars, _ *api.Agent, _ *api.ToolFunc, argm api.ArgMap) (*api.Result, error) {
+	store, err := sessionStore(vars)
+	if err != nil {
+		return nil, err
+	}
+	list, err := store.Sessions()
+	if err != nil {
+		return nil, err
+	}
+	if limit := argm.GetInt("limit"); limit > 0 && len(list) > limit {
+		list = list[:limit]
+	}
+	if len(list) == 0 {
+		return &api.Result{Value: "No sessions found\n"}, nil
+	}
+	var sb strings.Builder
+	for _, v := range list {
+		title := strings.Join(strings.Fields(v.Title), " ")
+		if len(title) > 60 {
+			title = title[:60] + "..."
+		}
+		fmt.Fprintf(&sb, "%s\t%s\t%v\t%s\n", v.ID, v.Updated.Local().Format(time.DateTime), v.Messages, title)
+	}
+	return &api.Result{
+		Value: sb.String(),
+		Data:  list,
+	}, nil
+}
+
+// Show prints the messages of the session.
+func (r *SessionKit) Show(ctx context.Context, vars *api.Vars, _ *api.Agent, _ *api.ToolFunc, argm api.ArgMap) (*api.Result, error) {
+	store, err := sessionStore(vars)
+	if err != nil {
+		return nil, err
+	}
+	id := sessionID(vars, argm)
+	list, err := store.LoadSession(id)
+	if err != nil {
+		return nil, err
+	}
+	return &api.Result{
+		Value: formatSession(id, list, "text"),
+		Data:  list,
+	}, nil
+}
+
+// Fork copies the messages of the session to a new session to continue with: ai --session <to>
+func (r *SessionKit) Fork(ctx context.Context, vars *api.Vars, _ *api.Agent, _ *api.ToolFunc, argm api.ArgMap) (*api.Result, error) {
+	store, err := sessionStore(vars)
+	if err != nil {
+		return nil, err
+	}
+	id := sessionID(vars, argm)
+	list, err := store.LoadSession(id)
+	if err != nil {
+		return nil, err
+	}
+	to := api.SessionID(argm.GetString("to"))
+	if to == "" {
+		to = api.SessionID(uuid.NewString())
+	}
+	if !api.ValidSession(string(to)) {
+		return nil, fmt.Errorf("invalid session: %q. letters, digits, '.', '_' and '-' only", to)
+	}
+	if to == id {
+		return nil, fmt.Errorf("cannot fork session %s to itself", id)
+	}
+	if _, err := store.LoadSession(to); err == nil {
+		return nil, fmt.Errorf("session already exists: %s", to)
+	}
+	var messages []*api.Message
+	for _, v := range list {
+		m := *v
+		m.ID = uuid.NewString()
+		m.Session = to
+		messages = append(messages, &m)
+	}
+	if err := vars.History.Save(messages); err != nil {
+		return nil, err
+	}
+	return &api.Result{
+		Value: fmt.Sprintf("Forked session %s to %s with %v messages. Continue with: ai --session %s\n", id, to, len(messages), to),
+	}, nil
+}
+
+// Delete removes the messages of the session.
+func (r *SessionKit) Delete(ctx context.Context, vars *api.Vars, _ *api.Agent, _ *api.ToolFunc, argm api.ArgMap) (*api.Result, error) {
+	store, err := sessionStore(vars)
+	if err != nil {
+		return nil, err
+	}
+	id := api.SessionID(argm.GetString("id"))
+	if id == "" {
+		return nil, fmt.Errorf("session id is required as listed by session:list")
+	}
+	if err := store.DeleteSession(id); err != nil {
+		return nil, err
+	}
+	return &api.Result{
+		Value: fmt.Sprintf("Deleted session %s\n", id),
+	}, nil
+}
+
+// Export returns the messages of the session as json or markdown.
+func (r *SessionKit) Export(ctx context.Context, vars *api.Vars, _ *api.Agent, _ *api.ToolFunc, argm api.ArgMap) (*api.Result, error) {
+	store, err := sessionStore(vars)
+	if err != nil {
+		return nil, err
+	}
+	id := sessionID(vars, argm)
+	list, err := store.LoadSession(id)
+	if err != nil {
+		return nil, err
+	}
+	format := argm.GetString("format")
+	switch format {
+	case "", "json":
+		data, err := json.MarshalIndent(list, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return &api.Result{
+			MimeType: "application/json",
+			Value:    string(data),
+		}, nil
+	case "markdown", "md":
+		return &api.Result{
+			MimeType: "text/markdown",
+			Value:    formatSession(id, list, "markdown"),
+		}, nil
+	}
+	return nil, fmt.Errorf("invalid format: %q. supported: json, markdown", format)
+}
+
+func formatSession(id api.SessionID, list []*api.Message, format string) string {
+	var sb strings.Builder
+	if format == "markdown" {
+		fmt.Fprintf(&sb, "# Session %s\n\n", id)
+		for _, v := range list {
+			fmt.Fprintf(&sb, "## %s", v.Role)
+			if v.Agent != "" {
+				fmt.Fprintf(&sb, " (%s)", v.Agent)
+			}
+			fmt.Fprintf(&sb, "\n\n_%s_\n\n%s\n\n", v.Created.Local().Format(time.DateTime), v.Content)
+			for _, tc := range v.ToolCalls {
+				fmt.Fprintf(&sb, "- `%s` %s\n\n", tc.Command, formatToolArgs(tc))
+			}
+		}
+		return sb.String()
+	}
+	fmt.Fprintf(&sb, "Session: %s\nMessages: %v\n\n", id, len(list))
+	for _, v := range list {
+		fmt.Fprintf(&sb, "[%s] %s", v.Created.Local().Format(time.DateTime), v.Role)
+		if v.Agent != "" {
+			fmt.Fprintf(&sb, " @%s", v.Agent)
+		}
+		fmt.Fprintf(&sb, "\n%s\n", v.Content)
+		for _, tc := range v.ToolCalls {
+			fmt.Fprintf(&sb, "→ %s %s\n", tc.Command, formatToolArgs(tc))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+func formatToolArgs(tc *api.ToolCall) string {
+	data, err := json.Marshal(tc.Arguments)
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
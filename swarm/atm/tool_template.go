@@ -80,6 +80,42 @@ func NewFuncMap(vars *api.Vars) template.FuncMap {
 	fm["encodeMD"] = encodeMD
 	fm["decodeMD"] = decodeMD
 
+	// MCP resources and prompts of the servers of a tool kit
+	fm["listMcpResources"] = func(kit string) string {
+		v, err := listMcpResources(context.TODO(), vars, kit)
+		if err != nil {
+			return err.Error()
+		}
+		return v
+	}
+	fm["readMcpResource"] = func(kit, uri string) string {
+		v, err := ReadMcpResource(context.TODO(), vars, kit, uri)
+		if err != nil {
+			return err.Error()
+		}
+		return v
+	}
+	fm["listMcpPrompts"] = func(kit string) string {
+		v, err := listMcpPrompts(context.TODO(), vars, kit)
+		if err != nil {
+			return err.Error()
+		}
+		return v
+	}
+	// getMcpPrompt "kit" "name" "key=value"...
+	fm["getMcpPrompt"] = func(kit, name string, args ...string) string {
+		var params = make(map[string]string)
+		for _, v := range args {
+			k, val, _ := strings.Cut(v, "=")
+			params[k] = val
+		}
+		v, err := GetMcpPrompt(context.TODO(), vars, kit, name, params)
+		if err != nil {
+			return err.Error()
+		}
+		return v
+	}
+
 	// custom
 
 	// core utils
@@ -5,17 +5,24 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/qiangli/ai/swarm/api"
 	"github.com/qiangli/ai/swarm/atm"
 	"github.com/qiangli/ai/swarm/atm/conf"
 	"github.com/qiangli/ai/swarm/log"
 	"github.com/qiangli/ai/swarm/resource"
+	"github.com/qiangli/ai/swarm/telemetry"
 )
 
 type Swarm struct {
 	//
 	vars *api.Vars
+
+	// the state is shared, served MCP tool calls are run one at a time
+	mu sync.Mutex
 }
 
 // set "workspace", "user", "input" in global env.
@@ -146,7 +153,10 @@ func (sw *Swarm) Format(ctx context.Context, argm map[string]any) (*api.Result,
 }
 
 func (sw *Swarm) Exec(ctx context.Context, input any) (*api.Result, error) {
-	return sw.exec(ctx, sw.vars.RootAgent, input)
+	ctx, span := telemetry.Start(ctx, "swarm.exec", telemetry.AttrSession.String(string(sw.vars.SessionID)))
+	result, err := sw.exec(ctx, sw.vars.RootAgent, input)
+	telemetry.End(span, err)
+	return result, err
 }
 
 func (sw *Swarm) exec(ctx context.Context, parent *api.Agent, input any) (*api.Result, error) {
@@ -154,6 +164,7 @@ func (sw *Swarm) exec(ctx context.Context, parent *api.Agent, input any) (*api.R
 	if err != nil {
 		return nil, err
 	}
+	trace.SpanFromContext(ctx).SetAttributes(telemetry.AttrAction.String(argm.Kitname().String()))
 	return sw.execm(ctx, parent, argm)
 }
 
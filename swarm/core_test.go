@@ -9,10 +9,10 @@ import (
 
 	"github.com/qiangli/ai/swarm/api"
 	"github.com/qiangli/ai/swarm/atm"
+	"github.com/qiangli/ai/swarm/db"
 	"github.com/qiangli/ai/swarm/llm/adapter"
 	"github.com/qiangli/ai/swarm/util/calllog"
 	"github.com/qiangli/ai/swarm/util/conf"
-	hist "github.com/qiangli/ai/swarm/util/history"
 	"github.com/qiangli/shell/vfs"
 	"github.com/qiangli/shell/vos"
 )
@@ -67,7 +67,7 @@ func defaultVars(cfg *api.App) (*api.Vars, error) {
 		return nil, err
 	}
 
-	mem, err := hist.NewFileMemStore(roots.Workspace.Path)
+	mem, err := db.OpenMemoryStore(filepath.Join(roots.Workspace.Path, "var", "lib"), "memory.db")
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,269 @@
+package db
+
+import (
+	"cmp"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/qiangli/ai/swarm/api"
+)
+
+// CallLog stores tool calls in sqlite.
+type CallLog struct {
+	ds      *DataStore
+	base    string
+	session string
+}
+
+// OpenCallLog opens the call log shared by all sessions, calls are saved with the session id.
+func OpenCallLog(base, file, session string) (*CallLog, error) {
+	const calls = `CREATE TABLE IF NOT EXISTS calls (
+			"id" INTEGER PRIMARY KEY AUTOINCREMENT,
+			"session" TEXT,
+			"agent" TEXT,
+			"kit" TEXT,
+			"name" TEXT,
+			"arguments" TEXT,
+			"error" TEXT,
+			"result" TEXT,
+			"started" TEXT,
+			"ended" TEXT,
+			"duration" INTEGER,
+			"cache" TEXT,
+			"policy" TEXT,
+			"approval" TEXT
+		  );`
+	const index = `CREATE INDEX IF NOT EXISTS calls_started ON calls (started);`
+
+	if err := os.MkdirAll(base, 0755); err != nil {
+		return nil, err
+	}
+	ds, err := NewDB(filepath.Join(base, file))
+	if err != nil {
+		return nil, err
+	}
+	// tools of concurrent flows are logged from multiple goroutines
+	ds.db.SetMaxOpenConns(1)
+
+	for _, ddl := range []string{calls, index} {
+		if _, err := ds.CreateTable(ddl); err != nil {
+			ds.Close()
+			return nil, err
+		}
+	}
+	return &CallLog{ds: ds, base: base, session: session}, nil
+}
+
+func (r *CallLog) Close() error {
+	return r.ds.Close()
+}
+
+func (r *CallLog) Base() string {
+	return r.base
+}
+
+// Save records the call, failures are ignored as with the file call log.
+func (r *CallLog) Save(entry *api.CallLogEntry) {
+	r.save(entry)
+}
+
+func (r *CallLog) save(entry *api.CallLogEntry) error {
+	const query = `
+		INSERT INTO calls (session, agent, kit, name, arguments, error, result, started, ended, duration, cache, policy, approval)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	var errText string
+	if entry.Error != nil {
+		errText = entry.Error.Error()
+	}
+	var result sql.NullString
+	if entry.Result != nil {
+		b, err := json.Marshal(entry.Result)
+		if err != nil {
+			return err
+		}
+		result = sql.NullString{String: string(b), Valid: true}
+	}
+	var duration int64
+	if !entry.Ended.IsZero() {
+		duration = int64(entry.Ended.Sub(entry.Started))
+	}
+	v, err := r.ds.Execute(query, r.session, entry.Agent, entry.Kit, entry.Name, encodeArguments(entry.Arguments), errText, result,
+		formatTime(entry.Started), formatTime(entry.Ended), duration, entry.Cache, entry.Policy, entry.Approval)
+	if err != nil {
+		return err
+	}
+	entry.ID, _ = v.LastInsertId()
+	return nil
+}
+
+func (r *CallLog) Get(id int64) (*api.CallLogEntry, error) {
+	list, err := r.query(`WHERE id = ?`, []any{id}, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("call not found: %v", id)
+	}
+	return list[0], nil
+}
+
+func (r *CallLog) Search(q *api.CallLogQuery) ([]*api.CallLogEntry, error) {
+	where, args := callFilter(q)
+	return r.query(where, args, q.Limit)
+}
+
+func (r *CallLog) Stats(q *api.CallLogQuery) ([]*api.CallLogStats, error) {
+	where, args := callFilter(q)
+	rows, err := r.ds.Query(`SELECT kit, name, duration, error FROM calls `+where+` ORDER BY kit, name, duration`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []*api.CallLogStats
+	var durations []time.Duration
+	summarize := func() {
+		if len(list) == 0 {
+			return
+		}
+		s := list[len(list)-1]
+		var total time.Duration
+		for _, d := range durations {
+			total += d
+		}
+		s.Mean = total / time.Duration(len(durations))
+		s.P50 = percentile(durations, 50)
+		s.P95 = percentile(durations, 95)
+		s.Max = durations[len(durations)-1]
+	}
+	for rows.Next() {
+		var kit, name, errText string
+		var d int64
+		if err := rows.Scan(&kit, &name, &d, &errText); err != nil {
+			return nil, err
+		}
+		if len(list) == 0 || list[len(list)-1].Kit != kit || list[len(list)-1].Name != name {
+			summarize()
+			list = append(list, &api.CallLogStats{Kit: kit, Name: name})
+			durations = durations[:0]
+		}
+		s := list[len(list)-1]
+		s.Count++
+		if errText != "" {
+			s.Errors++
+		}
+		durations = append(durations, time.Duration(d))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	summarize()
+
+	// slowest tools first
+	slices.SortStableFunc(list, func(a, b *api.CallLogStats) int {
+		return cmp.Compare(b.P95, a.P95)
+	})
+	return list, nil
+}
+
+// nearest rank of the sorted durations
+func percentile(sorted []time.Duration, p int) time.Duration {
+	i := (len(sorted)*p+99)/100 - 1
+	return sorted[max(i, 0)]
+}
+
+func callFilter(q *api.CallLogQuery) (string, []any) {
+	var cond []string
+	var args []any
+	glob := func(col, pattern string) {
+		if pattern != "" {
+			cond = append(cond, col+" GLOB ?")
+			args = append(args, pattern)
+		}
+	}
+	glob("agent", q.Agent)
+	glob("kit", q.Kit)
+	glob("name", q.Name)
+	switch q.Status {
+	case api.CallStatusOK:
+		cond = append(cond, "error = ''")
+	case api.CallStatusError:
+		cond = append(cond, "error != ''")
+	}
+	if !q.Since.IsZero() {
+		cond = append(cond, "started >= ?")
+		args = append(args, formatTime(q.Since))
+	}
+	if !q.Until.IsZero() {
+		cond = append(cond, "started < ?")
+		args = append(args, formatTime(q.Until))
+	}
+	if len(cond) == 0 {
+		return "", nil
+	}
+	return "WHERE " + strings.Join(cond, " AND "), args
+}
+
+func (r *CallLog) query(where string, args []any, limit int) ([]*api.CallLogEntry, error) {
+	query := `
+		SELECT id, agent, kit, name, arguments, error, result, started, ended, cache, policy, approval
+		FROM calls ` + where + `
+		ORDER BY started DESC, id DESC
+		LIMIT ?`
+
+	if limit <= 0 {
+		limit = -1
+	}
+	rows, err := r.ds.Query(query, append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []*api.CallLogEntry
+	for rows.Next() {
+		var entry api.CallLogEntry
+		var arguments, errText, started, ended string
+		var result sql.NullString
+		if err := rows.Scan(&entry.ID, &entry.Agent, &entry.Kit, &entry.Name, &arguments, &errText, &result, &started, &ended, &entry.Cache, &entry.Policy, &entry.Approval); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(arguments), &entry.Arguments); err != nil {
+			return nil, err
+		}
+		if errText != "" {
+			entry.Error = errors.New(errText)
+		}
+		if result.Valid {
+			var v api.Result
+			if err := json.Unmarshal([]byte(result.String), &v); err != nil {
+				return nil, err
+			}
+			entry.Result = &v
+		}
+		entry.Started = parseTime(started)
+		entry.Ended = parseTime(ended)
+		list = append(list, &entry)
+	}
+	return list, rows.Err()
+}
+
+// arguments that can't be encoded, e.g. functions, are left out.
+func encodeArguments(args map[string]any) string {
+	var m = make(map[string]json.RawMessage)
+	for k, v := range args {
+		if b, err := json.Marshal(v); err == nil {
+			m[k] = b
+		}
+	}
+	b, _ := json.Marshal(m)
+	return string(b)
+}
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/qiangli/ai/swarm/api"
+)
+
+func TestCallLog(t *testing.T) {
+	store, err := OpenCallLog(t.TempDir(), "calllog.db", "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	start := time.Now().Add(-time.Hour)
+	for i := range 10 {
+		entry := &api.CallLogEntry{
+			Agent:     "ops/deploy",
+			Kit:       "fs",
+			Name:      "read_file",
+			Arguments: map[string]any{"path": fmt.Sprintf("/tmp/%v", i), "fn": func() {}},
+			Result:    &api.Result{Value: "ok"},
+			Started:   start.Add(time.Duration(i) * time.Minute),
+		}
+		entry.Ended = entry.Started.Add(time.Duration(i+1) * time.Millisecond)
+		store.Save(entry)
+	}
+	store.Save(&api.CallLogEntry{
+		Agent:   "ops/build",
+		Kit:     "sh",
+		Name:    "exec",
+		Error:   fmt.Errorf("exit status 1"),
+		Started: start.Add(30 * time.Minute),
+		Ended:   start.Add(30*time.Minute + time.Second),
+	})
+
+	all, err := store.Search(&api.CallLogQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 11 {
+		t.Fatalf("expected 11 calls, got %v", len(all))
+	}
+	if all[0].Kit != "sh" || all[0].Error == nil || all[0].Error.Error() != "exit status 1" {
+		t.Errorf("expected most recent failed call first: %+v", all[0])
+	}
+
+	failed, _ := store.Search(&api.CallLogQuery{Status: api.CallStatusError})
+	if len(failed) != 1 {
+		t.Errorf("expected 1 failed call, got %v", len(failed))
+	}
+	recent, _ := store.Search(&api.CallLogQuery{Agent: "ops/*", Kit: "fs", Since: start.Add(5 * time.Minute), Limit: 3})
+	if len(recent) != 3 || recent[0].Arguments["path"] != "/tmp/9" {
+		t.Errorf("unexpected filtered calls: %+v", recent)
+	}
+	if _, ok := recent[0].Arguments["fn"]; ok {
+		t.Errorf("expected arguments that can't be encoded to be left out")
+	}
+
+	got, err := store.Get(recent[0].ID)
+	if err != nil || got.Result == nil || got.Result.Value != "ok" {
+		t.Errorf("unexpected call: %+v %v", got, err)
+	}
+	if _, err := store.Get(999); err == nil {
+		t.Errorf("expected missing call")
+	}
+
+	stats, err := store.Stats(&api.CallLogQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 2 || stats[0].Kit != "sh" || stats[0].Errors != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	fs := stats[1]
+	if fs.Count != 10 || fs.P50 != 5*time.Millisecond || fs.P95 != 10*time.Millisecond || fs.Max != 10*time.Millisecond {
+		t.Errorf("unexpected latency: %+v", fs)
+	}
+}
@@ -0,0 +1,213 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/qiangli/ai/swarm/api"
+)
+
+// RunJournal stores runs and flow steps in sqlite.
+type RunJournal struct {
+	ds *DataStore
+}
+
+func OpenRunJournal(base string, file string) (*RunJournal, error) {
+	const runs = `CREATE TABLE IF NOT EXISTS runs (
+			"id" TEXT NOT NULL PRIMARY KEY,
+			"session" TEXT,
+			"input" TEXT,
+			"status" TEXT,
+			"error" TEXT,
+			"created" TEXT,
+			"updated" TEXT
+		  );`
+	const steps = `CREATE TABLE IF NOT EXISTS steps (
+			"run_id" TEXT NOT NULL,
+			"key" TEXT NOT NULL,
+			"action" TEXT,
+			"input" TEXT,
+			"output" TEXT,
+			"status" TEXT,
+			"error" TEXT,
+			"started" TEXT,
+			"ended" TEXT,
+			PRIMARY KEY ("run_id", "key")
+		  );`
+
+	if err := os.MkdirAll(base, 0755); err != nil {
+		return nil, err
+	}
+	ds, err := NewDB(filepath.Join(base, file))
+	if err != nil {
+		return nil, err
+	}
+	// steps of concurrent flows are written from multiple goroutines
+	ds.db.SetMaxOpenConns(1)
+
+	for _, ddl := range []string{runs, steps} {
+		if _, err := ds.CreateTable(ddl); err != nil {
+			ds.Close()
+			return nil, err
+		}
+	}
+	return &RunJournal{ds: ds}, nil
+}
+
+func (r *RunJournal) Close() error {
+	return r.ds.Close()
+}
+
+func (r *RunJournal) SaveRun(run *api.Run) error {
+	const query = `
+		INSERT INTO runs (id, session, input, status, error, created, updated)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			status = excluded.status,
+			error = excluded.error,
+			updated = excluded.updated`
+
+	now := time.Now()
+	if run.Created.IsZero() {
+		run.Created = now
+	}
+	run.Updated = now
+	input, err := json.Marshal(run.Input)
+	if err != nil {
+		return err
+	}
+	_, err = r.ds.Execute(query, run.ID, run.Session, string(input), run.Status, run.Error, formatTime(run.Created), formatTime(run.Updated))
+	return err
+}
+
+func (r *RunJournal) GetRun(id string) (*api.Run, error) {
+	const query = `
+		SELECT id, session, input, status, error, created, updated
+		FROM runs
+		WHERE id = ?`
+
+	list, err := r.queryRuns(query, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("run not found: %s", id)
+	}
+	return list[0], nil
+}
+
+func (r *RunJournal) ListRuns(limit int) ([]*api.Run, error) {
+	const query = `
+		SELECT id, session, input, status, error, created, updated
+		FROM runs
+		ORDER BY created DESC
+		LIMIT ?`
+
+	if limit <= 0 {
+		limit = -1
+	}
+	return r.queryRuns(query, limit)
+}
+
+func (r *RunJournal) queryRuns(query string, args ...any) ([]*api.Run, error) {
+	rows, err := r.ds.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []*api.Run
+	for rows.Next() {
+		var run api.Run
+		var input, created, updated string
+		if err := rows.Scan(&run.ID, &run.Session, &input, &run.Status, &run.Error, &created, &updated); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(input), &run.Input); err != nil {
+			return nil, err
+		}
+		run.Created = parseTime(created)
+		run.Updated = parseTime(updated)
+		list = append(list, &run)
+	}
+	return list, rows.Err()
+}
+
+func (r *RunJournal) SaveStep(step *api.RunStep) error {
+	const query = `
+		INSERT INTO steps (run_id, key, action, input, output, status, error, started, ended)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(run_id, key) DO UPDATE SET
+			action = excluded.action,
+			input = excluded.input,
+			output = excluded.output,
+			status = excluded.status,
+			error = excluded.error,
+			started = excluded.started,
+			ended = excluded.ended`
+
+	var output sql.NullString
+	if step.Output != nil {
+		b, err := json.Marshal(step.Output)
+		if err != nil {
+			return err
+		}
+		output = sql.NullString{String: string(b), Valid: true}
+	}
+	_, err := r.ds.Execute(query, step.RunID, step.Key, step.Action, step.Input, output, step.Status, step.Error, formatTime(step.Started), formatTime(step.Ended))
+	return err
+}
+
+func (r *RunJournal) ListSteps(runID string) ([]*api.RunStep, error) {
+	const query = `
+		SELECT run_id, key, action, input, output, status, error, started, ended
+		FROM steps
+		WHERE run_id = ?
+		ORDER BY started ASC, key ASC`
+
+	rows, err := r.ds.Query(query, runID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []*api.RunStep
+	for rows.Next() {
+		var step api.RunStep
+		var output sql.NullString
+		var started, ended string
+		if err := rows.Scan(&step.RunID, &step.Key, &step.Action, &step.Input, &output, &step.Status, &step.Error, &started, &ended); err != nil {
+			return nil, err
+		}
+		if output.Valid {
+			var result api.Result
+			if err := json.Unmarshal([]byte(output.String), &result); err != nil {
+				return nil, err
+			}
+			step.Output = &result
+		}
+		step.Started = parseTime(started)
+		step.Ended = parseTime(ended)
+		list = append(list, &step)
+	}
+	return list, rows.Err()
+}
+
+// fixed width so that the text sorts in time order
+const timeLayout = "2006-01-02T15:04:05.000000000Z"
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(timeLayout)
+}
+
+func parseTime(s string) time.Time {
+	t, _ := time.Parse(timeLayout, s)
+	return t
+}
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/qiangli/ai/swarm/api"
+)
+
+func TestRunJournal(t *testing.T) {
+	j, err := OpenRunJournal(t.TempDir(), "journal.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	first := &api.Run{ID: "r1", Input: []string{"/flow:sequence", "--actions", "a,b"}, Status: api.RunStatusRunning}
+	if err := j.SaveRun(first); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+	if err := j.SaveRun(&api.Run{ID: "r2", Status: api.RunStatusRunning}); err != nil {
+		t.Fatal(err)
+	}
+	first.Status = api.RunStatusFailed
+	first.Error = "boom"
+	if err := j.SaveRun(first); err != nil {
+		t.Fatal(err)
+	}
+
+	run, err := j.GetRun("r1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Status != api.RunStatusFailed || run.Error != "boom" || len(run.Input) != 3 {
+		t.Errorf("unexpected run: %+v", run)
+	}
+	if _, err := j.GetRun("missing"); err == nil {
+		t.Errorf("expected error for unknown run")
+	}
+
+	runs, err := j.ListRuns(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 || runs[0].ID != "r2" {
+		t.Errorf("expected most recent run first: %+v", runs)
+	}
+
+	now := time.Now()
+	step := &api.RunStep{RunID: "r1", Key: "/sequence#0/0", Action: "a", Status: api.RunStatusRunning, Started: now}
+	if err := j.SaveStep(step); err != nil {
+		t.Fatal(err)
+	}
+	step.Status = api.RunStatusCompleted
+	step.Output = &api.Result{Value: "A"}
+	step.Ended = now.Add(time.Second)
+	if err := j.SaveStep(step); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.SaveStep(&api.RunStep{RunID: "r1", Key: "/sequence#0/1", Action: "b", Status: api.RunStatusFailed, Error: "bad", Started: now.Add(time.Second)}); err != nil {
+		t.Fatal(err)
+	}
+
+	steps, err := j.ListSteps("r1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(steps) != 2 {
+		t.Fatalf("expected 2 steps, got %v", len(steps))
+	}
+	if steps[0].Status != api.RunStatusCompleted || steps[0].Output == nil || steps[0].Output.Value != "A" {
+		t.Errorf("unexpected step: %+v", steps[0])
+	}
+	if steps[1].Output != nil || steps[1].Error != "bad" {
+		t.Errorf("unexpected step: %+v", steps[1])
+	}
+}
@@ -1,7 +1,10 @@
 package db
 
 import (
+	"database/sql"
+	"encoding/json"
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
 	"time"
@@ -12,158 +15,434 @@ import (
 type Message = api.Message
 type MemOption = api.MemOption
 
+// MemoryStore is the conversation history in sqlite,
+// implementing api.MemStore, api.SessionStore and api.SearchStore.
 type MemoryStore struct {
 	ds *DataStore
 }
 
-const layout = "2006-01-02 15:04:05.999999 -0700 MST"
+// migrations upgrade the schema in order, the number applied is kept as the user_version of the database.
+var migrations = []func(tx *sql.Tx) error{
+	migrateMessages,
+	migrateSearch,
+}
 
-func OpenMemoryStore(base string, file string) (*MemoryStore, error) {
-	const ddl = `CREATE TABLE IF NOT EXISTS chats (
-			"id" TEXT NOT NULL,		
-			"session" TEXT,
-			"created" DATETIME,
-			"content_type" TEXT,
-			"content" TEXT,
-			"role" TEXT,
-			"sender" TEXT		
+// migrateMessages creates the messages table and moves the rows of the earlier chats table if any.
+func migrateMessages(tx *sql.Tx) error {
+	const messages = `CREATE TABLE IF NOT EXISTS messages (
+			"seq" INTEGER PRIMARY KEY AUTOINCREMENT,
+			"id" TEXT NOT NULL,
+			"session" TEXT NOT NULL DEFAULT '',
+			"created" INTEGER NOT NULL DEFAULT 0,
+			"content_type" TEXT NOT NULL DEFAULT '',
+			"content" TEXT NOT NULL DEFAULT '',
+			"role" TEXT NOT NULL DEFAULT '',
+			"tool_calls" TEXT NOT NULL DEFAULT '',
+			"tool_call_id" TEXT NOT NULL DEFAULT '',
+			"sender" TEXT NOT NULL DEFAULT '',
+			"agent" TEXT NOT NULL DEFAULT '',
+			"context" INTEGER NOT NULL DEFAULT 0
 		  );`
+	var ddls = []string{
+		messages,
+		`CREATE INDEX IF NOT EXISTS messages_id ON messages (id);`,
+		`CREATE INDEX IF NOT EXISTS messages_session ON messages (session, created);`,
+		`CREATE INDEX IF NOT EXISTS messages_agent ON messages (agent, created);`,
+		`CREATE INDEX IF NOT EXISTS messages_created ON messages (created);`,
+	}
+	for _, ddl := range ddls {
+		if _, err := tx.Exec(ddl); err != nil {
+			return err
+		}
+	}
 
-	dbPath := filepath.Join(base, file)
-
-	ds, err := NewDB(dbPath)
+	var n int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'chats'`).Scan(&n); err != nil {
+		return err
+	}
+	if n == 0 {
+		return nil
+	}
+	rows, err := tx.Query(`SELECT id, COALESCE(session, ''), COALESCE(created, ''), COALESCE(content_type, ''),
+		COALESCE(content, ''), COALESCE(role, ''), COALESCE(sender, '') FROM chats ORDER BY rowid`)
 	if err != nil {
+		return err
+	}
+	var list []*Message
+	for rows.Next() {
+		var msg Message
+		var created string
+		if err := rows.Scan(&msg.ID, &msg.Session, &created, &msg.ContentType, &msg.Content, &msg.Role, &msg.Sender); err != nil {
+			rows.Close()
+			return err
+		}
+		msg.Created = parseChatTime(created)
+		list = append(list, &msg)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if err := insertMessages(tx, list); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`DROP TABLE chats`)
+	return err
+}
+
+// parseChatTime parses the time of the chats table saved in the format of time.Time.String
+func parseChatTime(s string) time.Time {
+	const layout = "2006-01-02 15:04:05.999999999 -0700 MST"
+	if idx := strings.LastIndex(s, " m="); idx != -1 {
+		s = s[:idx]
+	}
+	for _, l := range []string{layout, time.RFC3339Nano} {
+		if t, err := time.Parse(l, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// migrateSearch adds the full text index of the message content, kept in sync by triggers.
+func migrateSearch(tx *sql.Tx) error {
+	var ddls = []string{
+		`CREATE VIRTUAL TABLE IF NOT EXISTS messages_fts USING fts5(
+			content,
+			content='messages',
+			content_rowid='seq',
+			tokenize='porter unicode61'
+		  );`,
+		`CREATE TRIGGER IF NOT EXISTS messages_ai AFTER INSERT ON messages BEGIN
+			INSERT INTO messages_fts (rowid, content) VALUES (new.seq, new.content);
+		  END;`,
+		`CREATE TRIGGER IF NOT EXISTS messages_ad AFTER DELETE ON messages BEGIN
+			INSERT INTO messages_fts (messages_fts, rowid, content) VALUES ('delete', old.seq, old.content);
+		  END;`,
+		`CREATE TRIGGER IF NOT EXISTS messages_au AFTER UPDATE ON messages BEGIN
+			INSERT INTO messages_fts (messages_fts, rowid, content) VALUES ('delete', old.seq, old.content);
+			INSERT INTO messages_fts (rowid, content) VALUES (new.seq, new.content);
+		  END;`,
+		// index the existing messages
+		`INSERT INTO messages_fts (messages_fts) VALUES ('rebuild');`,
+	}
+	for _, ddl := range ddls {
+		if _, err := tx.Exec(ddl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OpenMemoryStore opens the history database, upgrading the schema if needed.
+func OpenMemoryStore(base string, file string) (*MemoryStore, error) {
+	if err := os.MkdirAll(base, 0755); err != nil {
 		return nil, err
 	}
-	if _, err := ds.CreateTable(ddl); err != nil {
+	ds, err := NewDB(filepath.Join(base, file))
+	if err != nil {
 		return nil, err
 	}
+	// messages are saved by concurrent flows
+	ds.db.SetMaxOpenConns(1)
 
+	if err := migrate(ds.db); err != nil {
+		ds.Close()
+		return nil, fmt.Errorf("Failed to migrate %s: %w", file, err)
+	}
 	return &MemoryStore{ds: ds}, nil
 }
 
+func migrate(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return err
+	}
+	for i := version; i < len(migrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if err := migrations[i](tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+		// pragma does not take parameters
+		if _, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, i+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (m *MemoryStore) Close() error {
 	return m.ds.Close()
 }
 
 func (m *MemoryStore) Save(messages []*Message) error {
+	tx, err := m.ds.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := insertMessages(tx, messages); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func insertMessages(tx *sql.Tx, messages []*Message) error {
 	const query = `
-        INSERT INTO chats (id, session, created, content_type, content, role, sender)
-        VALUES (?, ?, ?, ?, ?, ?, ?)`
+		INSERT INTO messages (id, session, created, content_type, content, role, tool_calls, tool_call_id, sender, agent, context)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
 
 	for _, v := range messages {
-		if _, err := m.ds.Execute(query, v.ID, v.Session, v.Created, v.ContentType, v.Content, v.Role, v.Sender); err != nil {
+		var calls string
+		if len(v.ToolCalls) > 0 {
+			b, err := json.Marshal(v.ToolCalls)
+			if err != nil {
+				return err
+			}
+			calls = string(b)
+		}
+		if _, err := stmt.Exec(v.ID, string(v.Session), toNano(v.Created), v.ContentType, v.Content, v.Role,
+			calls, v.ToolCallID, v.Sender, string(v.Agent), v.Context); err != nil {
 			return err
 		}
 	}
-
 	return nil
 }
 
-func (m *MemoryStore) Load(opt *MemOption) ([]*Message, error) {
-	var defaultRoles = []string{"assistant", "user"}
-	// if opt == nil {
-	// 	opt = &api.MemOption{
-	// 		MaxHistory: 3,
-	// 		MaxSpan:    1440,
-	// 		Offset:     0,
-	// 	}
-	// }
-	if opt == nil || opt.MaxHistory == 0 || opt.MaxSpan == 0 {
-		return []*Message{}, nil
-	}
-	if len(opt.Roles) == 0 {
-		opt.Roles = defaultRoles
-	}
-
-	var messages []*Message
-	maxSpan := time.Now().Add(-time.Duration(opt.MaxSpan) * time.Minute).Unix()
-
-	rolePlaceholders := strings.Repeat("?,", len(opt.Roles))
-	rolePlaceholders = rolePlaceholders[:len(rolePlaceholders)-1]
-
-	// var query = fmt.Sprintf(`
-	// 	SELECT id, session, created, content_type, content, role, sender
-	// 	FROM chats
-	// 	WHERE created >= ?
-	// 	AND role IN (%s)
-	// 	ORDER BY created ASC
-	// 	LIMIT ? OFFSET ?
-	// 	`, rolePlaceholders)
-
-	var query = fmt.Sprintf(`
-		SELECT id, session, MAX(created) as created, content_type, content, role, sender
-		FROM (
-			SELECT * 
-			FROM chats
-			WHERE created >= ?
-			AND role IN (%s)
-			ORDER BY created DESC
-		)
-		GROUP BY id
-		ORDER BY created ASC
-		LIMIT ? OFFSET ?
-		`, rolePlaceholders)
-
-	// Build the args slice
-	var args []any
-	args = append(args, maxSpan)
-	for _, role := range opt.Roles {
-		args = append(args, role)
-	}
-	args = append(args, opt.MaxHistory, opt.Offset)
+const columns = `m.id, m.session, m.created, m.content_type, m.content, m.role, m.tool_calls, m.tool_call_id, m.sender, m.agent, m.context`
 
+func (m *MemoryStore) query(query string, args ...any) ([]*Message, error) {
 	rows, err := m.ds.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	var list []*Message
 	for rows.Next() {
 		var msg Message
-		var created string
-		if err := rows.Scan(&msg.ID, &msg.Session, &created, &msg.ContentType, &msg.Content, &msg.Role, &msg.Sender); err != nil {
+		var created int64
+		var calls string
+		if err := rows.Scan(&msg.ID, &msg.Session, &created, &msg.ContentType, &msg.Content, &msg.Role,
+			&calls, &msg.ToolCallID, &msg.Sender, &msg.Agent, &msg.Context); err != nil {
 			return nil, err
 		}
-		if idx := strings.LastIndex(created, " m="); idx != -1 {
-			created = created[:idx]
-		}
-		msg.Created, err = time.Parse(layout, created)
-		if err != nil {
-			return nil, err
+		msg.Created = fromNano(created)
+		if calls != "" {
+			if err := json.Unmarshal([]byte(calls), &msg.ToolCalls); err != nil {
+				return nil, err
+			}
 		}
+		list = append(list, &msg)
+	}
+	return list, rows.Err()
+}
 
-		messages = append(messages, &msg)
+// Load returns the most recent messages within the span in minutes,
+// or those of the session at any time if the session is not empty.
+// Context copies and non text messages are excluded.
+func (m *MemoryStore) Load(opt *MemOption) ([]*Message, error) {
+	if opt == nil || opt.MaxHistory <= 0 || (opt.MaxSpan <= 0 && opt.Session == "") {
+		return nil, nil
+	}
+	var where = []string{
+		"m.context = 0",
+		"(m.content_type = '' OR m.content_type LIKE 'text/%')",
+	}
+	var args []any
+	if opt.Session != "" {
+		where = append(where, "m.session = ?")
+		args = append(args, string(opt.Session))
+	} else {
+		where = append(where, "m.created >= ?")
+		args = append(args, time.Now().Add(-time.Duration(opt.MaxSpan)*time.Minute).UnixNano())
 	}
-	return messages, nil
+	where, args = whereRoles(where, args, opt.Roles)
+
+	var query = fmt.Sprintf(`
+		SELECT %s
+		FROM messages m
+		WHERE %s
+		ORDER BY m.created DESC, m.seq DESC
+		LIMIT ? OFFSET ?`, columns, strings.Join(where, " AND "))
+	args = append(args, opt.MaxHistory, max(opt.Offset, 0))
+
+	list, err := m.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	// oldest first
+	for left, right := 0, len(list)-1; left < right; left, right = left+1, right-1 {
+		list[left], list[right] = list[right], list[left]
+	}
+	return list, nil
 }
 
+func whereRoles(where []string, args []any, roles []string) ([]string, []any) {
+	if len(roles) == 0 {
+		return where, args
+	}
+	where = append(where, fmt.Sprintf("m.role IN (%s)", strings.TrimSuffix(strings.Repeat("?,", len(roles)), ",")))
+	for _, v := range roles {
+		args = append(args, v)
+	}
+	return where, args
+}
+
+// Get returns the latest message of the id.
 func (m *MemoryStore) Get(id string) (*Message, error) {
+	var query = fmt.Sprintf(`
+		SELECT %s
+		FROM messages m
+		WHERE m.id = ?
+		ORDER BY m.context ASC, m.seq DESC
+		LIMIT 1`, columns)
+
+	list, err := m.query(query, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, api.NewNotFoundError("message id: " + id)
+	}
+	return list[0], nil
+}
+
+func (m *MemoryStore) Sessions() ([]*api.SessionInfo, error) {
 	const query = `
-		SELECT id, session, created, content_type, content, role, sender
-		FROM chats
-		WHERE id = ?`
+		SELECT m.session, COUNT(*), MIN(m.created), MAX(m.created),
+			COALESCE((SELECT t.content FROM messages t
+				WHERE t.session = m.session AND t.context = 0 AND t.role = 'user'
+				ORDER BY t.created, t.seq LIMIT 1), '')
+		FROM messages m
+		WHERE m.session != '' AND m.context = 0
+		GROUP BY m.session
+		ORDER BY MAX(m.created) DESC`
 
-	row, err := m.ds.Query(query, id)
+	rows, err := m.ds.Query(query)
 	if err != nil {
 		return nil, err
 	}
-	defer row.Close()
+	defer rows.Close()
 
-	var msg Message
-	if row.Next() {
-		var created string
-		if err := row.Scan(&msg.ID, &msg.Session, &created, &msg.ContentType, &msg.Content, &msg.Role, &msg.Sender); err != nil {
+	var list []*api.SessionInfo
+	for rows.Next() {
+		var v api.SessionInfo
+		var created, updated int64
+		if err := rows.Scan(&v.ID, &v.Messages, &created, &updated, &v.Title); err != nil {
 			return nil, err
 		}
-		if idx := strings.LastIndex(created, " m="); idx != -1 {
-			created = created[:idx]
-		}
-		msg.Created, err = time.Parse(layout, created)
-		if err != nil {
-			return nil, err
+		v.Created = fromNano(created)
+		v.Updated = fromNano(updated)
+		list = append(list, &v)
+	}
+	return list, rows.Err()
+}
+
+// LoadSession returns the messages of the session without the copies kept as context of later calls.
+func (m *MemoryStore) LoadSession(session api.SessionID) ([]*Message, error) {
+	var query = fmt.Sprintf(`
+		SELECT %s
+		FROM messages m
+		WHERE m.session = ? AND m.context = 0
+		ORDER BY m.created, m.seq`, columns)
+
+	list, err := m.query(query, string(session))
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, api.NewNotFoundError("session: " + string(session))
+	}
+	return list, nil
+}
+
+func (m *MemoryStore) DeleteSession(session api.SessionID) error {
+	result, err := m.ds.Execute(`DELETE FROM messages WHERE session = ?`, string(session))
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return api.NewNotFoundError("session: " + string(session))
+	}
+	return nil
+}
+
+// Search returns the messages matching any of the keywords, best matches first.
+func (m *MemoryStore) Search(opt *api.SearchOption) ([]*Message, error) {
+	match := matchQuery(opt.Query)
+	if match == "" {
+		return nil, fmt.Errorf("search query is required")
+	}
+	limit := opt.Limit
+	if limit <= 0 {
+		limit = 10
+	}
+	var where = []string{
+		"messages_fts MATCH ?",
+		"m.context = 0",
+	}
+	var args = []any{match}
+	if opt.Session != "" {
+		where = append(where, "m.session = ?")
+		args = append(args, string(opt.Session))
+	}
+	if opt.Agent != "" {
+		where = append(where, "m.agent = ?")
+		args = append(args, opt.Agent)
+	}
+	where, args = whereRoles(where, args, opt.Roles)
+
+	var query = fmt.Sprintf(`
+		SELECT %s
+		FROM messages_fts
+		JOIN messages m ON m.seq = messages_fts.rowid
+		WHERE %s
+		ORDER BY bm25(messages_fts), m.created DESC
+		LIMIT ?`, columns, strings.Join(where, " AND "))
+	args = append(args, limit)
+
+	return m.query(query, args...)
+}
+
+// matchQuery quotes the keywords so that the query is not parsed as fts5 syntax.
+func matchQuery(s string) string {
+	var terms []string
+	for _, v := range strings.Fields(s) {
+		v = strings.Trim(v, `"`)
+		if v == "" {
+			continue
 		}
+		terms = append(terms, `"`+strings.ReplaceAll(v, `"`, `""`)+`"`)
+	}
+	return strings.Join(terms, " OR ")
+}
+
+// times are kept as unix nanoseconds, zero for the zero time
+func toNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
 
+func fromNano(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
 	}
-	return &msg, nil
+	return time.Unix(0, n)
 }
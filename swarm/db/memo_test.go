@@ -1,28 +1,178 @@
 package db
 
 import (
-	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	_ "modernc.org/sqlite"
 
 	"github.com/qiangli/ai/swarm/api"
 )
 
-func TestDBLoad(t *testing.T) {
-	if testing.Short() {
-		t.Skip("skipping test in short mode.")
+func TestMemoryStore(t *testing.T) {
+	store, err := OpenMemoryStore(t.TempDir(), "memory.db")
+	if err != nil {
+		t.Fatal(err)
 	}
-	home, _ := os.UserHomeDir()
-	mem, _ := OpenMemoryStore(filepath.Join(home, "/.ai"), "test.db")
-	opt := &api.MemOption{
-		MaxSpan:    14400,
-		Roles:      []string{"user", "assistant"},
-		MaxHistory: 100,
-		Offset:     0,
+	defer store.Close()
+
+	now := time.Now()
+	msg := func(session, id, role, content string, ago time.Duration) *api.Message {
+		return &api.Message{
+			ID:      id,
+			Session: api.SessionID(session),
+			Created: now.Add(-ago),
+			Role:    role,
+			Content: content,
+			Agent:   "swe",
+		}
+	}
+	// an old conversation, continued later with the earlier messages as context
+	old := []*api.Message{
+		msg("refactor", "1", api.RoleUser, "split the parser", 72*time.Hour),
+		msg("refactor", "2", api.RoleAssistant, "done", 72*time.Hour),
+	}
+	if err := store.Save(old); err != nil {
+		t.Fatal(err)
+	}
+	ctx := msg("refactor", "1", api.RoleUser, "split the parser", 72*time.Hour)
+	ctx.Context = true
+	call := msg("refactor", "3a", api.RoleAssistant, "", time.Minute)
+	call.ToolCalls = []*api.ToolCall{api.NewToolCall("c1", "go_test", nil)}
+	result := msg("refactor", "3b", api.RoleTool, "ok parsers", time.Minute)
+	result.ToolCallID = "c1"
+	if err := store.Save([]*api.Message{
+		ctx,
+		msg("refactor", "3", api.RoleUser, "add tests", time.Minute),
+		call,
+		result,
+		msg("refactor", "4", api.RoleAssistant, "added", time.Minute),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save([]*api.Message{
+		msg("other", "5", api.RoleUser, "hi", time.Second),
+		msg("other", "6", api.RoleAssistant, "hello", time.Second),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	roles := []string{api.RoleUser, api.RoleAssistant}
+
+	// scoped to the session regardless of the span
+	list, err := store.Load(&api.MemOption{MaxHistory: 10, MaxSpan: 60, Roles: roles, Session: "refactor"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 5 || list[0].ID != "1" || list[4].ID != "4" {
+		t.Errorf("loaded %v messages: %+v", len(list), list)
+	}
+	// across sessions within the span
+	list, err = store.Load(&api.MemOption{MaxHistory: 10, MaxSpan: 60, Roles: roles})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 5 {
+		t.Errorf("loaded %v messages", len(list))
+	}
+	// the most recent, oldest first
+	list, err = store.Load(&api.MemOption{MaxHistory: 2, MaxSpan: 60, Offset: 1, Roles: roles})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].ID != "4" || list[1].ID != "5" {
+		t.Errorf("offset: %+v", list)
+	}
+
+	if v, err := store.Get("3a"); err != nil || len(v.ToolCalls) != 1 || v.ToolCalls[0].Command != "go_test" || v.Agent != "swe" {
+		t.Errorf("get: %+v %v", v, err)
+	}
+	if _, err := store.Get("missing"); err == nil {
+		t.Errorf("expected not found")
 	}
 
-	messages, _ := mem.Load(opt)
-	t.Logf("%v\n", messages)
+	sessions, err := store.Sessions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 || sessions[0].ID != "other" {
+		t.Fatalf("sessions: %+v", sessions)
+	}
+	if v := sessions[1]; v.Messages != 6 || v.Title != "split the parser" {
+		t.Errorf("session: %+v", v)
+	}
+
+	// stemmed keywords, context copies excluded
+	found, err := store.Search(&api.SearchOption{Query: "parser", Limit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 2 {
+		t.Errorf("search: %+v", found)
+	}
+	found, err = store.Search(&api.SearchOption{Query: `parser "hello`, Roles: []string{api.RoleAssistant}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0].ID != "6" {
+		t.Errorf("search roles: %+v", found)
+	}
+
+	if err := store.DeleteSession("refactor"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.LoadSession("refactor"); err == nil {
+		t.Errorf("session not deleted")
+	}
+	if msgs, err := store.LoadSession("other"); err != nil || len(msgs) != 2 {
+		t.Errorf("other session: %v %v", msgs, err)
+	}
+	if found, err := store.Search(&api.SearchOption{Query: "parser"}); err != nil || len(found) != 0 {
+		t.Errorf("deleted messages found: %v %v", found, err)
+	}
+}
+
+func TestMemoryStoreMigrate(t *testing.T) {
+	base := t.TempDir()
+
+	// the earlier chats table
+	ds, err := NewDB(filepath.Join(base, "memory.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ds.CreateTable(`CREATE TABLE chats (id TEXT NOT NULL, session TEXT, created DATETIME,
+		content_type TEXT, content TEXT, role TEXT, sender TEXT);`); err != nil {
+		t.Fatal(err)
+	}
+	created := time.Now().Add(-time.Hour).Round(time.Microsecond)
+	if _, err := ds.Execute(`INSERT INTO chats VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		"1", "s1", created.String(), "text/plain", "migrate the schema", "user", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	ds.Close()
+
+	store, err := OpenMemoryStore(base, "memory.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	v, err := store.Get("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !v.Created.Equal(created) || v.Sender != "alice" {
+		t.Errorf("migrated: %+v", v)
+	}
+	if found, err := store.Search(&api.SearchOption{Query: "schema"}); err != nil || len(found) != 1 {
+		t.Errorf("migrated message not indexed: %v %v", found, err)
+	}
+
+	// reopen, already migrated
+	again, err := OpenMemoryStore(base, "memory.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	again.Close()
 }
@@ -4,26 +4,57 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sync"
 
 	"github.com/qiangli/ai/swarm/api"
 	"github.com/qiangli/ai/swarm/llm/anthropic"
 	"github.com/qiangli/ai/swarm/llm/gemini"
+	"github.com/qiangli/ai/swarm/llm/ollama"
 	"github.com/qiangli/ai/swarm/llm/openai"
 	"github.com/qiangli/ai/swarm/llm/xai"
 )
 
 type adapters struct {
+	mu sync.RWMutex
+
+	// registered at runtime
+	custom map[string]api.LLMAdapter
 }
 
 func (r *adapters) Get(key string) (api.LLMAdapter, error) {
 	if v, ok := adapterRegistry[key]; ok {
 		return v, nil
 	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if v, ok := r.custom[key]; ok {
+		return v, nil
+	}
 	return nil, fmt.Errorf("LLM adapter %q not found", key)
 }
 
+func (r *adapters) Register(key string, adapter api.LLMAdapter) error {
+	if key == "" {
+		return fmt.Errorf("adapter name is required")
+	}
+	if adapter == nil {
+		return fmt.Errorf("adapter %q is nil", key)
+	}
+	if _, ok := adapterRegistry[key]; ok {
+		return fmt.Errorf("built-in adapter %q can not be replaced", key)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.custom == nil {
+		r.custom = make(map[string]api.LLMAdapter)
+	}
+	r.custom[key] = adapter
+	return nil
+}
+
 var defaultAdapters = &adapters{}
 
+// built-in adapters
 var adapterRegistry map[string]api.LLMAdapter
 
 func init() {
@@ -42,6 +73,31 @@ func GetAdapters() api.AdapterRegistry {
 	return defaultAdapters
 }
 
+// Register adds a third party adapter to the default registry.
+// It is intended to be called from the init function of the package providing the adapter.
+func Register(key string, adapter api.LLMAdapter) error {
+	return defaultAdapters.Register(key, adapter)
+}
+
+// IsKeyOptional returns true if the provider may be used without an api key.
+// self-hosted servers such as ollama do not require authentication by default.
+func IsKeyOptional(provider string) bool {
+	return ollama.IsProvider(provider)
+}
+
+// HasNativeOutputSchema returns true if the chat adapter maps the output_schema
+// to the structured output of the provider.
+// gemini does not support a JSON response with function calling.
+func HasNativeOutputSchema(provider string, hasTools bool) bool {
+	switch provider {
+	case "openai", "xai", ollama.ProviderOllama, ollama.ProviderOpenAICompatible:
+		return true
+	case "gemini":
+		return !hasTools
+	}
+	return false
+}
+
 type EchoAdapter struct{}
 
 func (r *EchoAdapter) Call(ctx context.Context, req *api.Request) (*api.Response, error) {
@@ -114,6 +170,47 @@ func (r *ChatAdapter) Call(ctx context.Context, req *api.Request) (*api.Response
 		resp, err = anthropic.Send(ctx, req)
 	case "xai":
 		resp, err = xai.Send(ctx, req)
+	case ollama.ProviderOllama, ollama.ProviderOpenAICompatible:
+		resp, err = ollama.Send(ctx, req)
+	default:
+		return nil, fmt.Errorf("Unknown provider: %s", provider)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	if resp == nil {
+		return nil, fmt.Errorf("No response")
+	}
+	return resp, nil
+}
+
+// Stream implements api.LLMStreamAdapter.
+func (r *ChatAdapter) Stream(ctx context.Context, req *api.Request, h api.StreamHandler) (*api.Response, error) {
+	var err error
+	var resp *api.Response
+
+	if req.Model == nil {
+		return nil, fmt.Errorf("No LLM model provided")
+	}
+	if h == nil {
+		return r.Call(ctx, req)
+	}
+
+	provider := req.Model.Provider
+
+	//
+	switch provider {
+	case "gemini":
+		resp, err = gemini.Stream(ctx, req, h)
+	case "openai":
+		resp, err = openai.Stream(ctx, req, h)
+	case "anthropic":
+		resp, err = anthropic.Stream(ctx, req, h)
+	case "xai":
+		resp, err = xai.Stream(ctx, req, h)
+	case ollama.ProviderOllama, ollama.ProviderOpenAICompatible:
+		resp, err = ollama.Stream(ctx, req, h)
 	default:
 		return nil, fmt.Errorf("Unknown provider: %s", provider)
 	}
@@ -186,6 +283,8 @@ func (r *TextAdapter) Call(ctx context.Context, req *api.Request) (*api.Response
 		resp, err = anthropic.Send(ctx, req)
 	case "xai":
 		resp, err = xai.Send(ctx, req)
+	case ollama.ProviderOllama, ollama.ProviderOpenAICompatible:
+		resp, err = ollama.Send(ctx, req)
 	default:
 		return nil, fmt.Errorf("Unknown provider: %s", provider)
 	}
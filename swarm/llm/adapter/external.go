@@ -0,0 +1,145 @@
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/qiangli/ai/swarm/api"
+)
+
+const defaultExternalTimeout = 300 // 5 min
+
+// ExternalAdapter forwards the request to an external executable or HTTP endpoint
+// declared in agent yaml.
+//
+// The request is sent as a JSON encoded api.Request and
+// a JSON encoded api.Response is expected in return.
+type ExternalAdapter struct {
+	Config *api.AdapterConfig
+
+	// pack declaring the adapter
+	Pack string
+
+	// api token for the HTTP endpoint, optional
+	Token func() string
+}
+
+func NewExternalAdapter(cfg *api.AdapterConfig, token func() string) (*ExternalAdapter, error) {
+	if cfg == nil || cfg.Name == "" {
+		return nil, fmt.Errorf("adapter name is required")
+	}
+	if (cfg.Command == "") == (cfg.Url == "") {
+		return nil, fmt.Errorf("adapter %q: exactly one of command or url is required", cfg.Name)
+	}
+	return &ExternalAdapter{
+		Config: cfg,
+		Token:  token,
+	}, nil
+}
+
+func (r *ExternalAdapter) Call(ctx context.Context, req *api.Request) (*api.Response, error) {
+	data, err := encodeRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("adapter %q: %w", r.Config.Name, err)
+	}
+
+	timeout := r.Config.Timeout
+	if timeout <= 0 {
+		timeout = defaultExternalTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	var out []byte
+	if r.Config.Command != "" {
+		out, err = r.exec(ctx, data)
+	} else {
+		out, err = r.post(ctx, data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("adapter %q: %w", r.Config.Name, err)
+	}
+
+	var resp api.Response
+	if err := json.Unmarshal(out, &resp); err != nil {
+		return nil, fmt.Errorf("adapter %q: invalid response: %w", r.Config.Name, err)
+	}
+	if resp.Result == nil {
+		return nil, fmt.Errorf("adapter %q: no result", r.Config.Name)
+	}
+	resp.Agent = req.Agent
+	return &resp, nil
+}
+
+func (r *ExternalAdapter) exec(ctx context.Context, data []byte) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, r.Config.Command, r.Config.Args...)
+	cmd.Stdin = bytes.NewReader(data)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%v: %s", err, msg)
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *ExternalAdapter) post(ctx context.Context, data []byte) ([]byte, error) {
+	hreq, err := http.NewRequestWithContext(ctx, http.MethodPost, r.Config.Url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	hreq.Header.Set("Content-Type", "application/json")
+	hreq.Header.Set("Accept", "application/json")
+	if r.Token != nil {
+		if token := r.Token(); token != "" {
+			hreq.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+
+	hresp, err := http.DefaultClient.Do(hreq)
+	if err != nil {
+		return nil, err
+	}
+	defer hresp.Body.Close()
+
+	body, err := io.ReadAll(hresp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if hresp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", hresp.Status, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+// encodeRequest marshals the request for the wire.
+// the agent argument is sent by name; arguments that can not be represented in JSON are dropped.
+func encodeRequest(req *api.Request) ([]byte, error) {
+	wire := *req
+	if len(req.Arguments) > 0 {
+		args := api.NewArguments()
+		for k, v := range req.Arguments {
+			if a, ok := v.(*api.Agent); ok {
+				args[k] = api.NewPackname(a.Pack, a.Name)
+				continue
+			}
+			if _, err := json.Marshal(v); err != nil {
+				continue
+			}
+			args[k] = v
+		}
+		wire.Arguments = args
+	}
+	return json.Marshal(&wire)
+}
@@ -0,0 +1,115 @@
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"testing"
+
+	"github.com/qiangli/ai/swarm/api"
+)
+
+func TestExternalAdapterHTTP(t *testing.T) {
+	var got api.Request
+	var auth string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"result":{"role":"assistant","value":"pong"}}`))
+	}))
+	defer ts.Close()
+
+	a, err := NewExternalAdapter(&api.AdapterConfig{Name: "proxy", Url: ts.URL}, func() string { return "secret" })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	agent := &api.Agent{Pack: "test", Name: "sub"}
+	req := &api.Request{
+		Agent: agent,
+		Query: "ping",
+		Arguments: api.ArgMap{
+			"agent": agent,
+			"query": "ping",
+			"fn":    func() {},
+		},
+	}
+	resp, err := a.Call(context.TODO(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Result.Value != "pong" {
+		t.Errorf("value: got %q", resp.Result.Value)
+	}
+	if resp.Agent != agent {
+		t.Errorf("agent not set on response")
+	}
+	if auth != "Bearer secret" {
+		t.Errorf("authorization: got %q", auth)
+	}
+	if got.Query != "ping" {
+		t.Errorf("query: got %q", got.Query)
+	}
+	if got.Arguments["agent"] != "test/sub" {
+		t.Errorf("agent argument: got %v", got.Arguments["agent"])
+	}
+	if _, ok := got.Arguments["fn"]; ok {
+		t.Errorf("unsupported argument should be dropped")
+	}
+	// caller arguments untouched
+	if req.Arguments["agent"] != agent {
+		t.Errorf("request arguments modified")
+	}
+}
+
+func TestExternalAdapterCommand(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found")
+	}
+	a, err := NewExternalAdapter(&api.AdapterConfig{
+		Name:    "script",
+		Command: "sh",
+		Args:    []string{"-c", `cat >/dev/null; echo '{"result":{"value":"hello"}}'`},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := a.Call(context.TODO(), &api.Request{Query: "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Result.Value != "hello" {
+		t.Errorf("value: got %q", resp.Result.Value)
+	}
+
+	a.Config.Args = []string{"-c", `echo failed >&2; exit 1`}
+	if _, err := a.Call(context.TODO(), &api.Request{}); err == nil {
+		t.Errorf("expected error for failed command")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	if err := Register("chat", &EchoAdapter{}); err == nil {
+		t.Errorf("built-in adapter should not be replaced")
+	}
+	if _, err := NewExternalAdapter(&api.AdapterConfig{Name: "bad"}, nil); err == nil {
+		t.Errorf("expected error without command or url")
+	}
+
+	if err := Register("test-echo", &EchoAdapter{}); err != nil {
+		t.Fatal(err)
+	}
+	v, err := GetAdapters().Get("test-echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := v.(*EchoAdapter); !ok {
+		t.Errorf("unexpected adapter: %T", v)
+	}
+}
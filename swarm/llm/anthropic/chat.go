@@ -11,6 +11,7 @@ import (
 	"github.com/anthropics/anthropic-sdk-go/option"
 
 	"github.com/qiangli/ai/swarm/api"
+	"github.com/qiangli/ai/swarm/llm/loop"
 	"github.com/qiangli/ai/swarm/log"
 )
 
@@ -54,31 +55,27 @@ func NewClient(model *api.Model, apiKey string) anthropic.Client {
 func Send(ctx context.Context, req *api.Request) (*api.Response, error) {
 	log.GetLogger(ctx).Debugf(">ANTHROPIC:\n req: %+v\n", req)
 
-	resp, err := call(ctx, req)
+	resp, err := call(ctx, req, nil)
 
 	log.GetLogger(ctx).Debugf(">ANTHROPIC:\n resp: %+v err: %v\n", resp, err)
 	return resp, err
 }
 
-func call(ctx context.Context, req *api.Request) (*api.Response, error) {
+// Stream is the streaming variant of Send.
+// text and tool call deltas are delivered to the handler as they arrive.
+func Stream(ctx context.Context, req *api.Request, h api.StreamHandler) (*api.Response, error) {
+	log.GetLogger(ctx).Debugf(">ANTHROPIC:\n stream req: %+v\n", req)
+
+	resp, err := call(ctx, req, h)
+
+	log.GetLogger(ctx).Debugf(">ANTHROPIC:\n stream resp: %+v err: %v\n", resp, err)
+	return resp, err
+}
+
+func call(ctx context.Context, req *api.Request, h api.StreamHandler) (*api.Response, error) {
 	client := NewClient(req.Model, req.Token())
 	model := anthropic.Model(req.Model.Model)
 
-	var system []anthropic.TextBlockParam
-	var messages []anthropic.MessageParam
-	for _, v := range req.Messages {
-		switch v.Role {
-		case "system":
-			system = append(system, anthropic.TextBlockParam{Text: v.Content})
-		case "assistant":
-			messages = append(messages, anthropic.NewAssistantMessage(anthropic.NewTextBlock(v.Content)))
-		case "user":
-			messages = append(messages, anthropic.NewUserMessage(anthropic.NewTextBlock(v.Content)))
-		default:
-			log.GetLogger(ctx).Errorf("Role not supported: %s", v.Role)
-		}
-	}
-
 	var toolParams []*anthropic.ToolParam
 	if len(req.Tools) > 0 {
 		for _, f := range req.Tools {
@@ -94,116 +91,118 @@ func call(ctx context.Context, req *api.Request) (*api.Response, error) {
 		tools[i] = anthropic.ToolUnionParam{OfTool: toolParam}
 	}
 
-	maxTurns := req.MaxTurns()
-	if maxTurns <= 0 {
-		maxTurns = api.DefaultMaxTurns
-	}
-	resp := &api.Response{}
-
 	// TOOD
 	// https://platform.claude.com/docs/en/api/kotlin/completions/create
 	var temperature = anthropic.Float(1.0)
 
-	for tries := range maxTurns {
-		log.GetLogger(ctx).Infof(api.FormatRequestLine(req, "chat", maxTurns, tries))
+	complete := func(ctx context.Context, list []*api.Message) (*loop.Turn, error) {
+		system, messages := toMessages(ctx, list)
 
-		log.GetLogger(ctx).Debugf("📡 sending request to %s: %v of %v\n%+v\n", req.Model.BaseUrl, tries, maxTurns, req)
-
-		completion, err := client.Messages.New(ctx, anthropic.MessageNewParams{
+		params := anthropic.MessageNewParams{
 			Model:       model,
 			System:      system,
 			Messages:    messages,
 			Tools:       tools,
 			MaxTokens:   8192,
 			Temperature: temperature,
-		})
+		}
 
+		var completion *anthropic.Message
+		var err error
+		if h != nil {
+			completion, err = streamMessage(ctx, client, params, h)
+		} else {
+			completion, err = client.Messages.New(ctx, params)
+		}
 		if err != nil {
-			log.GetLogger(ctx).Errorf("❌ %s\n", err)
 			return nil, err
 		}
 
-		log.GetLogger(ctx).Infof("(%v)\n", completion.StopReason)
-
 		var b bytes.Buffer
-		var toolResults []anthropic.ContentBlockParamUnion
-
+		var calls []*api.ToolCall
 		for i, block := range completion.Content {
-			switch variant := block.AsAny().(type) {
+			switch block.AsAny().(type) {
 			case anthropic.TextBlock:
 				b.WriteString(block.Text)
-				continue
 			case anthropic.ToolUseBlock:
-				var name = block.Name
-				var props map[string]interface{}
-				if err := json.Unmarshal([]byte(variant.JSON.Input.Raw()), &props); err != nil {
+				var props map[string]any
+				if err := json.Unmarshal(block.Input, &props); err != nil {
 					return nil, err
 				}
-				log.GetLogger(ctx).Debugf("\n* tool call: %v %s props: %+v\n", i, name, props)
-
-				//
-				data, err := req.Runner.Run(ctx, name, props)
-				var isErr bool
-				var out *api.Result
-				if err != nil {
-					out = &api.Result{
-						Value: fmt.Sprintf("%s", err),
-					}
-					isErr = true
-				} else {
-					out = api.ToResult(data)
-				}
+				log.GetLogger(ctx).Debugf("\n* tool use: %v %s props: %+v\n", i, block.Name, props)
+				calls = append(calls, api.NewToolCall(block.ID, block.Name, props))
+			}
+		}
 
-				log.GetLogger(ctx).Debugf("* tool call: %s out: %s\n", name, out)
-				resp.Result = out
+		return &loop.Turn{
+			Role:         string(completion.Role),
+			Content:      b.String(),
+			ToolCalls:    calls,
+			Reason:       string(completion.StopReason),
+			Usage:        completion.Usage,
+			InputTokens:  completion.Usage.InputTokens,
+			OutputTokens: completion.Usage.OutputTokens,
+			//
+			TotalTokens: completion.Usage.InputTokens + completion.Usage.OutputTokens,
+		}, nil
+	}
 
-				// if out.State == api.StateExit {
-				// 	return resp, nil
-				// }
-				if out.State == api.StateTransfer {
-					resp.Result = out
-					return resp, nil
-				}
+	return loop.Run(ctx, req, loop.CompleterFunc(complete))
+}
 
-				if out.MimeType == "" || strings.HasPrefix(out.MimeType, "text/") {
-					if out.MimeType == "" {
-						out.MimeType = "text/plain"
-					}
-					toolResults = append(toolResults, newToolResultBlock(block.ID, out.Value, out.MimeType, isErr))
-				} else if strings.HasPrefix(out.MimeType, "image/") {
-					toolResults = append(toolResults, newToolResultBlock(block.ID, out.Value, out.MimeType, isErr))
-				} else {
-					toolResults = append(toolResults, newToolResultBlock(block.ID, fmt.Sprintf("mimetype not supported: %s", out.MimeType), "text/plain", true))
-				}
+// toMessages converts the messages to the system prompt and the conversation.
+// consecutive tool results are sent in one user message following the tool use.
+func toMessages(ctx context.Context, list []*api.Message) ([]anthropic.TextBlockParam, []anthropic.MessageParam) {
+	var system []anthropic.TextBlockParam
+	var messages []anthropic.MessageParam
+	var toolResults []anthropic.ContentBlockParamUnion
 
-			default:
-				continue
-			}
+	flush := func() {
+		if len(toolResults) > 0 {
+			messages = append(messages, anthropic.NewUserMessage(toolResults...))
+			toolResults = nil
 		}
+	}
 
-		if len(toolResults) == 0 {
-			resp.Result = &api.Result{
-				Role:         string(completion.Role),
-				MimeType:     "text/plain",
-				Value:        b.String(),
-				Usage:        completion.Usage,
-				InputTokens:  completion.Usage.InputTokens,
-				OutputTokens: completion.Usage.OutputTokens,
-				//
-				TotalTokens: completion.Usage.InputTokens + completion.Usage.OutputTokens,
+	for _, v := range list {
+		if v.Role != api.RoleTool {
+			flush()
+		}
+		switch v.Role {
+		case "system":
+			system = append(system, anthropic.TextBlockParam{Text: v.Content})
+		case "assistant":
+			var blocks []anthropic.ContentBlockParamUnion
+			if v.Content != "" || len(v.ToolCalls) == 0 {
+				blocks = append(blocks, anthropic.NewTextBlock(v.Content))
+			}
+			for _, tc := range v.ToolCalls {
+				var input = map[string]any(tc.Arguments)
+				if input == nil {
+					input = map[string]any{}
+				}
+				blocks = append(blocks, anthropic.NewToolUseBlock(tc.CallID, input, tc.Command))
+			}
+			messages = append(messages, anthropic.NewAssistantMessage(blocks...))
+		case "tool":
+			var mimeType = v.ContentType
+			switch {
+			case mimeType == "":
+				toolResults = append(toolResults, newToolResultBlock(v.ToolCallID, v.Content, "text/plain", false))
+			case strings.HasPrefix(mimeType, "text/"), strings.HasPrefix(mimeType, "image/"):
+				toolResults = append(toolResults, newToolResultBlock(v.ToolCallID, v.Content, mimeType, false))
+			default:
+				toolResults = append(toolResults, newToolResultBlock(v.ToolCallID, fmt.Sprintf("mimetype not supported: %s", mimeType), "text/plain", true))
 			}
-			break
+		case "user":
+			messages = append(messages, anthropic.NewUserMessage(anthropic.NewTextBlock(v.Content)))
+		default:
+			log.GetLogger(ctx).Errorf("Role not supported: %s", v.Role)
 		}
-
-		messages = append(messages, completion.ToParam())
-		messages = append(messages, anthropic.NewUserMessage(toolResults...))
 	}
+	flush()
 
-	// not finished due the max turns reached
-	if resp.Result == nil {
-		return nil, fmt.Errorf("Empty response. Max turns reached: %v. Try again with a higher value for the 'max_turns' parameter", maxTurns)
-	}
-	return resp, nil
+	return system, messages
 }
 
 func newToolResultBlock(toolUseID string, content, mimeType string, isError bool) anthropic.ContentBlockParamUnion {
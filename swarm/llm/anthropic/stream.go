@@ -0,0 +1,54 @@
+package anthropic
+
+import (
+	"context"
+
+	"github.com/anthropics/anthropic-sdk-go"
+
+	"github.com/qiangli/ai/swarm/api"
+)
+
+// streamMessage sends the message request in streaming mode.
+// deltas are forwarded to the handler and the events are accumulated
+// into a complete Message including usage.
+func streamMessage(ctx context.Context, client anthropic.Client, params anthropic.MessageNewParams, h api.StreamHandler) (*anthropic.Message, error) {
+	stream := client.Messages.NewStreaming(ctx, params)
+	defer stream.Close()
+
+	message := anthropic.Message{}
+	for stream.Next() {
+		event := stream.Current()
+		if err := message.Accumulate(event); err != nil {
+			return nil, err
+		}
+
+		switch ev := event.AsAny().(type) {
+		case anthropic.ContentBlockStartEvent:
+			if ev.ContentBlock.Type == "tool_use" {
+				h(&api.StreamEvent{
+					Type:   api.StreamEventToolCall,
+					CallID: ev.ContentBlock.ID,
+					Name:   ev.ContentBlock.Name,
+				})
+			}
+		case anthropic.ContentBlockDeltaEvent:
+			switch delta := ev.Delta.AsAny().(type) {
+			case anthropic.TextDelta:
+				h(&api.StreamEvent{
+					Type: api.StreamEventText,
+					Text: delta.Text,
+				})
+			case anthropic.InputJSONDelta:
+				h(&api.StreamEvent{
+					Type:      api.StreamEventToolCall,
+					Arguments: delta.PartialJSON,
+				})
+			}
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return nil, err
+	}
+
+	return &message, nil
+}
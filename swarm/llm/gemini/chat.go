@@ -3,11 +3,12 @@ package gemini
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 
+	"github.com/google/uuid"
 	"google.golang.org/genai"
 
 	"github.com/qiangli/ai/swarm/api"
+	"github.com/qiangli/ai/swarm/llm/loop"
 	"github.com/qiangli/ai/swarm/log"
 )
 
@@ -71,13 +72,24 @@ func NewClient(ctx context.Context, apiKey, _ string) (*genai.Client, error) {
 func Send(ctx context.Context, req *api.Request) (*api.Response, error) {
 	log.GetLogger(ctx).Debugf(">GEMINI:\n req: %+v\n", req)
 
-	resp, err := call(ctx, req)
+	resp, err := call(ctx, req, nil)
 
 	log.GetLogger(ctx).Debugf(">GEMINI:\n resp: %+v err: %v\n", resp, err)
 	return resp, err
 }
 
-func call(ctx context.Context, req *api.Request) (*api.Response, error) {
+// Stream is the streaming variant of Send.
+// text and tool call deltas are delivered to the handler as they arrive.
+func Stream(ctx context.Context, req *api.Request, h api.StreamHandler) (*api.Response, error) {
+	log.GetLogger(ctx).Debugf(">GEMINI:\n stream req: %+v\n", req)
+
+	resp, err := call(ctx, req, h)
+
+	log.GetLogger(ctx).Debugf(">GEMINI:\n stream resp: %+v err: %v\n", resp, err)
+	return resp, err
+}
+
+func call(ctx context.Context, req *api.Request, h api.StreamHandler) (*api.Response, error) {
 	client, err := NewClient(
 		ctx,
 		req.Token(),
@@ -87,20 +99,6 @@ func call(ctx context.Context, req *api.Request) (*api.Response, error) {
 		return nil, err
 	}
 
-	var messages []*genai.Content
-
-	for _, v := range req.Messages {
-		switch v.Role {
-		case "system", "assistant":
-			messages = append(messages, genai.NewContentFromText(v.Content, genai.RoleModel))
-		case "user":
-			messages = append(messages, genai.NewContentFromText(v.Content, genai.RoleUser))
-		default:
-			// just ignore and move on
-			log.GetLogger(ctx).Errorf("Role not supported: %s", v.Role)
-		}
-	}
-
 	var config *genai.GenerateContentConfig
 
 	if len(req.Tools) > 0 {
@@ -132,117 +130,117 @@ func call(ctx context.Context, req *api.Request) (*api.Response, error) {
 		}
 	}
 
-	maxTurns := req.MaxTurns()
-	if maxTurns <= 0 {
-		maxTurns = api.DefaultMaxTurns
+	// Structured output, function calling with a JSON response is not supported
+	if schema, _ := api.OutputSchema(req.Arguments); schema != nil && config == nil {
+		config = &genai.GenerateContentConfig{
+			ResponseMIMEType:   "application/json",
+			ResponseJsonSchema: schema,
+		}
 	}
-	resp := &api.Response{}
 
 	model := req.Model.Model
 
-	for tries := range maxTurns {
-		log.GetLogger(ctx).Infof(api.FormatRequestLine(req, "chat", maxTurns, tries))
-
-		log.GetLogger(ctx).Debugf("📡 sending request to %s: %v of %v\n%+v\n", req.Model.BaseUrl, tries, maxTurns, req)
+	complete := func(ctx context.Context, list []*api.Message) (*loop.Turn, error) {
+		messages := toMessages(ctx, list)
 
-		completion, err := client.Models.GenerateContent(ctx, model, messages, config)
+		var completion *genai.GenerateContentResponse
+		var err error
+		if h != nil {
+			completion, err = streamContent(ctx, client, model, messages, config, h)
+		} else {
+			completion, err = client.Models.GenerateContent(ctx, model, messages, config)
+		}
 		if err != nil {
-			log.GetLogger(ctx).Errorf("❌ %s\n", err)
 			return nil, err
 		}
-		log.GetLogger(ctx).Infof("(%v)\n", "done")
 
 		// https://ai.google.dev/gemini-api/docs/function-calling?example=meeting
-		toolCalls := completion.FunctionCalls()
-		if len(toolCalls) == 0 {
-			resp.Result = &api.Result{
-				Role:         api.RoleAssistant,
-				MimeType:     "text/plain",
-				Value:        completion.Text(),
-				Usage:        completion.UsageMetadata,
-				InputTokens:  int64(completion.UsageMetadata.PromptTokenCount),
-				OutputTokens: int64(completion.UsageMetadata.CandidatesTokenCount),
-				TotalTokens:  int64(completion.UsageMetadata.TotalTokenCount),
+		var calls []*api.ToolCall
+		for i, v := range completion.FunctionCalls() {
+			log.GetLogger(ctx).Debugf("\n* function call: %v %s args: %+v\n", i, v.Name, v.Args)
+			// the id is optional, required for pairing the results
+			id := v.ID
+			if id == "" {
+				id = uuid.NewString()
 			}
-			break
+			calls = append(calls, api.NewToolCall(id, v.Name, v.Args))
 		}
 
-		// call tools
-		for i, toolCall := range toolCalls {
-			// var id = toolCall.ID
-			var name = toolCall.Name
-			var args = toolCall.Args
+		var turn = &loop.Turn{
+			Role:      api.RoleAssistant,
+			ToolCalls: calls,
+			Reason:    "done",
+			Usage:     completion.UsageMetadata,
+		}
+		// text of the function calling response is not used
+		if len(calls) == 0 {
+			turn.Content = completion.Text()
+		}
+		if u := completion.UsageMetadata; u != nil {
+			turn.InputTokens = int64(u.PromptTokenCount)
+			turn.OutputTokens = int64(u.CandidatesTokenCount)
+			turn.TotalTokens = int64(u.TotalTokenCount)
+		}
+		return turn, nil
+	}
 
-			log.GetLogger(ctx).Debugf("\n* tool call: %v %s args: %+v\n", i, name, args)
+	return loop.Run(ctx, req, loop.CompleterFunc(complete))
+}
 
-			//
-			data, err := req.Runner.Run(ctx, name, args)
-			var out *api.Result
-			if err != nil {
-				out = &api.Result{
-					Value: fmt.Sprintf("%s", err),
-				}
-			} else {
-				out = api.ToResult(data)
+// toMessages converts the messages to the contents.
+// Gemini seems to require the exact pairing of the call and result messages,
+// each tool result is preceded by its function call.
+func toMessages(ctx context.Context, list []*api.Message) []*genai.Content {
+	var messages []*genai.Content
+	var calls = make(map[string]*api.ToolCall)
+
+	for _, v := range list {
+		switch v.Role {
+		case "system", "assistant":
+			for _, tc := range v.ToolCalls {
+				calls[tc.CallID] = tc
 			}
-			log.GetLogger(ctx).Debugf("* tool call: %s out: %s\n", name, out)
-			resp.Result = out
-
-			// if out.State == api.StateExit {
-			// 	return resp, nil
-			// }
-			if out.State == api.StateTransfer {
-				resp.Result = out
-				return resp, nil
+			if v.Content != "" || len(v.ToolCalls) == 0 {
+				messages = append(messages, genai.NewContentFromText(v.Content, genai.RoleModel))
+			}
+		case "tool":
+			tc, ok := calls[v.ToolCallID]
+			if !ok {
+				continue
 			}
-
-			// Gemini seems to require the exact pairing of the call and result messages
 			// call message
-			// messages = []*genai.Content{}
 			messages = append(messages, &genai.Content{
 				Parts: []*genai.Part{
 					{
-						FunctionCall: toolCall,
+						FunctionCall: &genai.FunctionCall{
+							ID:   tc.CallID,
+							Name: tc.Command,
+							Args: tc.Arguments,
+						},
 					},
 				},
 				Role: genai.RoleModel,
 			})
-
 			// result message
-			if out.MimeType != "" {
+			if v.ContentType != "" {
 				messages = append(messages, genai.NewContentFromParts(
 					[]*genai.Part{
 						{
 							InlineData: &genai.Blob{
-								Data:     []byte(out.Value),
-								MIMEType: out.MimeType,
+								Data:     []byte(v.Content),
+								MIMEType: v.ContentType,
 							},
 						},
 					}, genai.RoleUser))
 			} else {
-				messages = append(messages, genai.NewContentFromText(out.Value, genai.RoleUser))
-				// messages = append(messages, &genai.Content{
-				// 	Parts: []*genai.Part{
-				// 		{
-				// 			FunctionResponse: &genai.FunctionResponse{
-				// 				ID:   id,
-				// 				Name: name,
-				// 				Response: map[string]any{
-				// 					"output": out,
-				// 					"error":  err,
-				// 				},
-				// 			},
-				// 		},
-				// 	},
-				// 	Role: "function",
-				// })
+				messages = append(messages, genai.NewContentFromText(v.Content, genai.RoleUser))
 			}
+		case "user":
+			messages = append(messages, genai.NewContentFromText(v.Content, genai.RoleUser))
+		default:
+			// just ignore and move on
+			log.GetLogger(ctx).Errorf("Role not supported: %s", v.Role)
 		}
 	}
-
-	// not finished due the max turns reached
-	if resp.Result == nil {
-		return nil, fmt.Errorf("Empty response. Max turns reached: %v. Try again with a higher value for the 'max_turns' parameter", maxTurns)
-	}
-	return resp, nil
+	return messages
 }
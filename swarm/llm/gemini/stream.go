@@ -0,0 +1,69 @@
+package gemini
+
+import (
+	"context"
+	"encoding/json"
+
+	"google.golang.org/genai"
+
+	"github.com/qiangli/ai/swarm/api"
+)
+
+// streamContent generates content in streaming mode.
+// deltas are forwarded to the handler and the chunks are merged
+// into a single response with the usage of the last chunk.
+func streamContent(ctx context.Context, client *genai.Client, model string, contents []*genai.Content, config *genai.GenerateContentConfig, h api.StreamHandler) (*genai.GenerateContentResponse, error) {
+	var parts []*genai.Part
+	var last *genai.GenerateContentResponse
+
+	for chunk, err := range client.Models.GenerateContentStream(ctx, model, contents, config) {
+		if err != nil {
+			return nil, err
+		}
+		last = chunk
+
+		if len(chunk.Candidates) == 0 || chunk.Candidates[0].Content == nil {
+			continue
+		}
+		for _, part := range chunk.Candidates[0].Content.Parts {
+			parts = append(parts, part)
+			switch {
+			case part.FunctionCall != nil:
+				// function calls are not split across chunks
+				args, _ := json.Marshal(part.FunctionCall.Args)
+				h(&api.StreamEvent{
+					Type:      api.StreamEventToolCall,
+					CallID:    part.FunctionCall.ID,
+					Name:      part.FunctionCall.Name,
+					Arguments: string(args),
+				})
+			case part.Text != "" && !part.Thought:
+				h(&api.StreamEvent{
+					Type: api.StreamEventText,
+					Text: part.Text,
+				})
+			}
+		}
+	}
+
+	resp := &genai.GenerateContentResponse{
+		Candidates: []*genai.Candidate{
+			{
+				Content: &genai.Content{
+					Role:  genai.RoleModel,
+					Parts: parts,
+				},
+			},
+		},
+		UsageMetadata: &genai.GenerateContentResponseUsageMetadata{},
+	}
+	if last != nil {
+		if last.UsageMetadata != nil {
+			resp.UsageMetadata = last.UsageMetadata
+		}
+		if len(last.Candidates) > 0 {
+			resp.Candidates[0].FinishReason = last.Candidates[0].FinishReason
+		}
+	}
+	return resp, nil
+}
@@ -0,0 +1,217 @@
+// Package loop is the provider neutral agentic loop shared by the LLM adapters:
+// complete, run the tool calls requested by the model, append the results and
+// repeat until the model answers without tool calls or the max turns is reached.
+package loop
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/qiangli/ai/swarm/api"
+	"github.com/qiangli/ai/swarm/log"
+)
+
+const maxThreadLimit = 3
+
+// Turn is the reply of the model to one completion request.
+type Turn struct {
+	// assistant if empty
+	Role    string
+	Content string
+
+	// tool calls requested by the model
+	ToolCalls []*api.ToolCall
+
+	// finish/stop reason for display
+	Reason string
+
+	// provider specific usage
+	Usage        any
+	InputTokens  int64
+	OutputTokens int64
+	TotalTokens  int64
+}
+
+// Completer sends the conversation to the model and returns its next turn.
+// Providers convert the messages including the assistant tool call and tool result
+// messages to their native format.
+type Completer interface {
+	Complete(ctx context.Context, messages []*api.Message) (*Turn, error)
+}
+
+type CompleterFunc func(context.Context, []*api.Message) (*Turn, error)
+
+func (f CompleterFunc) Complete(ctx context.Context, messages []*api.Message) (*Turn, error) {
+	return f(ctx, messages)
+}
+
+// Run runs the agentic loop for the request.
+// The messages are fitted to the context window of the request, if any, before each call.
+// The tool call and tool result messages are returned in Response.Messages and
+// the token usage of the result is the sum of all turns.
+func Run(ctx context.Context, req *api.Request, c Completer) (*api.Response, error) {
+	if len(req.Messages) == 0 {
+		return nil, fmt.Errorf("no input message")
+	}
+
+	var maxTurns = req.MaxTurns()
+	if maxTurns <= 0 {
+		maxTurns = api.DefaultMaxTurns
+	}
+
+	var messages = PairToolMessages(req.Messages)
+	var resp = &api.Response{}
+	var usage []any
+	var inputTokens, outputTokens, totalTokens int64
+
+	for tries := range maxTurns {
+		log.GetLogger(ctx).Infof(api.FormatRequestLine(req, "chat", maxTurns, tries))
+
+		log.GetLogger(ctx).Debugf("📡 sending request to %s: %v of %v\n%+v\n", req.Model.BaseUrl, tries, maxTurns, req)
+
+		var input = messages
+		if req.Window != nil {
+			v, err := req.Window.Fit(ctx, messages)
+			if err != nil {
+				return nil, err
+			}
+			input = PairToolMessages(v)
+		}
+
+		turn, err := c.Complete(ctx, input)
+		if err != nil {
+			log.GetLogger(ctx).Errorf("❌ %s\n", err)
+			return nil, err
+		}
+		log.GetLogger(ctx).Infof("(%s)\n", turn.Reason)
+
+		usage = append(usage, turn.Usage)
+		inputTokens += turn.InputTokens
+		outputTokens += turn.OutputTokens
+		totalTokens += turn.TotalTokens
+
+		if len(turn.ToolCalls) == 0 {
+			resp.Result = &api.Result{
+				Role:         nvl(turn.Role, api.RoleAssistant),
+				MimeType:     "text/plain",
+				Value:        turn.Content,
+				Usage:        usage,
+				InputTokens:  inputTokens,
+				OutputTokens: outputTokens,
+				TotalTokens:  totalTokens,
+			}
+			// request completed
+			return resp, nil
+		}
+
+		call := &api.Message{
+			ID:        uuid.NewString(),
+			Created:   time.Now(),
+			Role:      api.RoleAssistant,
+			Content:   turn.Content,
+			ToolCalls: turn.ToolCalls,
+		}
+		messages = append(messages, call)
+		resp.Messages = append(resp.Messages, call)
+
+		results := RunTools(ctx, req.Runner, turn.ToolCalls, maxThreadLimit)
+		for i, out := range results {
+			if out == nil {
+				out = &api.Result{Value: "no result"}
+			}
+			if out.State == api.StateTransfer {
+				out.Usage = usage
+				out.InputTokens = inputTokens
+				out.OutputTokens = outputTokens
+				out.TotalTokens = totalTokens
+				resp.Result = out
+				return resp, nil
+			}
+			msg := &api.Message{
+				ID:          uuid.NewString(),
+				Created:     time.Now(),
+				Role:        api.RoleTool,
+				ContentType: out.MimeType,
+				Content:     out.Value,
+				Sender:      turn.ToolCalls[i].Command,
+				ToolCallID:  turn.ToolCalls[i].CallID,
+			}
+			messages = append(messages, msg)
+			resp.Messages = append(resp.Messages, msg)
+		}
+	}
+
+	// not finished due the max turns reached
+	return nil, fmt.Errorf("Empty response. Max turns reached: %v. Try again with a higher value for the 'max_turns' parameter", maxTurns)
+}
+
+// PairToolMessages drops the tool results without a preceding tool call and the tool calls
+// without results, e.g. of a history truncated by max_history.
+// Providers reject conversations with unpaired tool messages.
+func PairToolMessages(messages []*api.Message) []*api.Message {
+	var list []*api.Message
+	for i, v := range messages {
+		switch {
+		case v.Role == api.RoleTool:
+			if !hasToolCall(list, v.ToolCallID) {
+				continue
+			}
+		case len(v.ToolCalls) > 0:
+			var calls []*api.ToolCall
+			for _, tc := range v.ToolCalls {
+				if hasToolResult(messages[i+1:], tc.CallID) {
+					calls = append(calls, tc)
+				}
+			}
+			if len(calls) < len(v.ToolCalls) {
+				if len(calls) == 0 && v.Content == "" {
+					continue
+				}
+				m := *v
+				m.ToolCalls = calls
+				v = &m
+			}
+		}
+		list = append(list, v)
+	}
+	return list
+}
+
+// hasToolCall reports whether the last assistant message of the list requested the call.
+func hasToolCall(list []*api.Message, id string) bool {
+	for i := len(list) - 1; i >= 0; i-- {
+		if list[i].Role == api.RoleTool {
+			continue
+		}
+		for _, tc := range list[i].ToolCalls {
+			if tc.CallID == id {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// hasToolResult reports whether the tool messages following the tool calls have the result.
+func hasToolResult(list []*api.Message, id string) bool {
+	for _, v := range list {
+		if v.Role != api.RoleTool {
+			return false
+		}
+		if v.ToolCallID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func nvl(a, b string) string {
+	if a != "" {
+		return a
+	}
+	return b
+}
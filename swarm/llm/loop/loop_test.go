@@ -0,0 +1,107 @@
+package loop
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/qiangli/ai/swarm/api"
+)
+
+type echoRunner struct{}
+
+func (r *echoRunner) Run(ctx context.Context, name string, args map[string]any) (any, error) {
+	if name == "fail" {
+		return nil, fmt.Errorf("failed")
+	}
+	return fmt.Sprintf("%s %v", name, args["x"]), nil
+}
+
+func newRequest(maxTurns int) *api.Request {
+	req := &api.Request{
+		Agent:    &api.Agent{Name: "root", Display: "root"},
+		Model:    &api.Model{Provider: "openai"},
+		Runner:   &echoRunner{},
+		Messages: []*api.Message{{Role: api.RoleUser, Content: "hi"}},
+	}
+	req.SetMaxTurns(maxTurns)
+	return req
+}
+
+func TestRun(t *testing.T) {
+	var seen [][]*api.Message
+	c := CompleterFunc(func(ctx context.Context, messages []*api.Message) (*Turn, error) {
+		seen = append(seen, messages)
+		turn := &Turn{InputTokens: 10, OutputTokens: 2, TotalTokens: 12}
+		switch len(seen) {
+		case 1:
+			turn.ToolCalls = []*api.ToolCall{
+				api.NewToolCall("c1", "echo", map[string]any{"x": 1}),
+				api.NewToolCall("c2", "fail", nil),
+			}
+		default:
+			turn.Content = "done"
+		}
+		return turn, nil
+	})
+
+	resp, err := Run(context.TODO(), newRequest(5), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Result.Value != "done" || resp.Result.Role != api.RoleAssistant {
+		t.Errorf("result: %+v", resp.Result)
+	}
+	if resp.Result.InputTokens != 20 || resp.Result.OutputTokens != 4 || resp.Result.TotalTokens != 24 {
+		t.Errorf("usage: %+v", resp.Result)
+	}
+
+	msgs := resp.Messages
+	if len(msgs) != 3 {
+		t.Fatalf("transcript: %+v", msgs)
+	}
+	if msgs[0].Role != api.RoleAssistant || len(msgs[0].ToolCalls) != 2 {
+		t.Errorf("tool call message: %+v", msgs[0])
+	}
+	if msgs[1].Role != api.RoleTool || msgs[1].ToolCallID != "c1" || msgs[1].Content != "echo 1" {
+		t.Errorf("tool result message: %+v", msgs[1])
+	}
+	if msgs[2].ToolCallID != "c2" || msgs[2].Content != "failed" {
+		t.Errorf("tool error message: %+v", msgs[2])
+	}
+	// the second turn sees the input, the tool call and the results
+	if len(seen) != 2 || len(seen[1]) != 4 {
+		t.Errorf("second turn messages: %v", len(seen[1]))
+	}
+}
+
+func TestRunMaxTurns(t *testing.T) {
+	c := CompleterFunc(func(ctx context.Context, messages []*api.Message) (*Turn, error) {
+		return &Turn{ToolCalls: []*api.ToolCall{api.NewToolCall(fmt.Sprint(len(messages)), "echo", nil)}}, nil
+	})
+	if _, err := Run(context.TODO(), newRequest(2), c); err == nil {
+		t.Errorf("expected max turns error")
+	}
+}
+
+func TestPairToolMessages(t *testing.T) {
+	list := []*api.Message{
+		// result of a truncated call
+		{Role: api.RoleTool, ToolCallID: "c0", Content: "orphan"},
+		{Role: api.RoleUser, Content: "hi"},
+		{Role: api.RoleAssistant, ToolCalls: []*api.ToolCall{{CallID: "c1"}, {CallID: "c2"}}},
+		{Role: api.RoleTool, ToolCallID: "c1", Content: "one"},
+		{Role: api.RoleAssistant, ToolCalls: []*api.ToolCall{{CallID: "c3"}}},
+		{Role: api.RoleUser, Content: "again"},
+	}
+	got := PairToolMessages(list)
+	if len(got) != 4 {
+		t.Fatalf("messages: %+v", got)
+	}
+	if len(got[1].ToolCalls) != 1 || got[1].ToolCalls[0].CallID != "c1" {
+		t.Errorf("tool calls: %+v", got[1].ToolCalls)
+	}
+	if len(list[2].ToolCalls) != 2 {
+		t.Errorf("input modified")
+	}
+}
@@ -0,0 +1,84 @@
+package loop
+
+import (
+	"context"
+	"sync"
+
+	"github.com/qiangli/ai/swarm/api"
+	"github.com/qiangli/ai/swarm/log"
+)
+
+// RunTools runs the tool calls, up to max in parallel.
+// The results are in the order of the calls, errors are reported as the result value.
+func RunTools(
+	parent context.Context,
+	runner api.ActionRunner,
+	calls []*api.ToolCall,
+	max int,
+) []*api.Result {
+	switch len(calls) {
+	case 0:
+		return nil
+	case 1:
+		return []*api.Result{runTool(parent, runner, calls[0])}
+	}
+
+	var wg sync.WaitGroup
+
+	semaphore := make(chan struct{}, max)
+	results := make([]*api.Result, len(calls))
+
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	log.GetLogger(parent).Debugf("\n* tool call count: %v", len(calls))
+
+	for i, toolCall := range calls {
+		wg.Add(1)
+
+		go func(i int, toolCall *api.ToolCall) {
+			defer wg.Done()
+
+			select {
+			case semaphore <- struct{}{}:
+				defer func() { <-semaphore }()
+			case <-ctx.Done():
+				return
+			}
+
+			results[i] = runTool(ctx, runner, toolCall)
+		}(i, toolCall)
+	}
+
+	wg.Wait()
+
+	return results
+}
+
+func runTool(
+	ctx context.Context,
+	runner api.ActionRunner,
+	toolCall *api.ToolCall,
+) *api.Result {
+	var name = toolCall.Command
+	var props = make(map[string]any)
+	if toolCall.Arguments != nil {
+		toolCall.Arguments.Copy(props)
+	}
+
+	log.GetLogger(ctx).Debugf("\n* tool call: %s props: %+v\n", name, props)
+
+	var out *api.Result
+	data, err := runner.Run(ctx, name, props)
+	if err != nil {
+		out = &api.Result{
+			Value: err.Error(),
+		}
+	} else {
+		out = api.ToResult(data)
+	}
+
+	log.GetLogger(ctx).Debugf("\n* tool call: %s out: %s\n", name, out)
+	api.StreamToolResult(ctx, toolCall.CallID, name, out)
+	return out
+}
@@ -0,0 +1,85 @@
+package ollama
+
+import (
+	"context"
+	"strings"
+
+	"github.com/qiangli/ai/swarm/api"
+	"github.com/qiangli/ai/swarm/llm/openai"
+	"github.com/qiangli/ai/swarm/log"
+)
+
+// Self-hosted models served over the OpenAI compatible chat completions api.
+// https://github.com/ollama/ollama/blob/main/docs/openai.md
+//
+// Tool calling and JSON mode (response_format: json_object) are supported
+// by the OpenAI request/response format, see [openai.Send].
+
+const (
+	ProviderOllama           = "ollama"
+	ProviderOpenAICompatible = "openai-compatible"
+)
+
+// default ollama endpoint
+const DefaultBaseUrl = "http://localhost:11434/v1/"
+
+// IsProvider returns true for providers handled by this package.
+func IsProvider(provider string) bool {
+	switch provider {
+	case ProviderOllama, ProviderOpenAICompatible:
+		return true
+	}
+	return false
+}
+
+func Send(ctx context.Context, req *api.Request) (*api.Response, error) {
+	log.GetLogger(ctx).Debugf(">OLLAMA:\n req: %+v\n", req)
+
+	resp, err := openai.Send(ctx, withBaseUrl(req))
+
+	log.GetLogger(ctx).Debugf(">OLLAMA:\n resp: %+v err: %v\n", resp, err)
+	return resp, err
+}
+
+// Stream is the streaming variant of Send.
+func Stream(ctx context.Context, req *api.Request, h api.StreamHandler) (*api.Response, error) {
+	log.GetLogger(ctx).Debugf(">OLLAMA:\n stream req: %+v\n", req)
+
+	resp, err := openai.Stream(ctx, withBaseUrl(req), h)
+
+	log.GetLogger(ctx).Debugf(">OLLAMA:\n stream resp: %+v err: %v\n", resp, err)
+	return resp, err
+}
+
+// withBaseUrl returns a shallow copy of the request with the
+// normalized base url or the default ollama endpoint if it is not configured.
+func withBaseUrl(req *api.Request) *api.Request {
+	if req.Model == nil {
+		return req
+	}
+	baseUrl := BaseUrl(req.Model)
+	if baseUrl == req.Model.BaseUrl {
+		return req
+	}
+	model := *req.Model
+	model.BaseUrl = baseUrl
+
+	nreq := *req
+	nreq.Model = &model
+	return &nreq
+}
+
+// BaseUrl returns the configured base url with a trailing slash
+// or the default ollama endpoint.
+func BaseUrl(model *api.Model) string {
+	if model.BaseUrl == "" {
+		if model.Provider == ProviderOllama {
+			return DefaultBaseUrl
+		}
+		return ""
+	}
+	if !strings.HasSuffix(model.BaseUrl, "/") {
+		return model.BaseUrl + "/"
+	}
+	return model.BaseUrl
+}
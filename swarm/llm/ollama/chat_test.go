@@ -0,0 +1,194 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/qiangli/ai/swarm/api"
+)
+
+type testRunner struct {
+	calls []string
+}
+
+func (r *testRunner) Run(_ context.Context, name string, args map[string]any) (any, error) {
+	r.calls = append(r.calls, name)
+	return &api.Result{Value: "sunny"}, nil
+}
+
+// newTestServer stands in for ollama's OpenAI compatible endpoint.
+// requests with tools are answered with a tool call until the tool result is sent, others with the final text.
+func newTestServer(bodies *[]map[string]any) *httptest.Server {
+	const toolCall = `{"id":"c1","object":"chat.completion","created":1,"model":"llama3.2","choices":[{"index":0,"finish_reason":"tool_calls","message":{"role":"assistant","content":"","tool_calls":[{"id":"call_1","type":"function","function":{"name":"weather__get","arguments":"{\"city\":\"Paris\"}"}}]}}],"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}}`
+	const answer = `{"id":"c2","object":"chat.completion","created":1,"model":"llama3.2","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"{\"weather\":\"sunny\"}"}}],"usage":{"prompt_tokens":20,"completion_tokens":6,"total_tokens":26}}`
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var body map[string]any
+		json.Unmarshal(data, &body)
+		*bodies = append(*bodies, body)
+
+		w.Header().Set("Content-Type", "application/json")
+		if hasTools(body) && !hasToolResult(body) {
+			io.WriteString(w, toolCall)
+		} else {
+			io.WriteString(w, answer)
+		}
+	})
+	mux.HandleFunc("/v1/models", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"object":"list","data":[{"id":"qwen3","object":"model","created":1,"owned_by":"library"},{"id":"llama3.2","object":"model","created":1,"owned_by":"library"}]}`)
+	})
+	return httptest.NewServer(mux)
+}
+
+func hasTools(body map[string]any) bool {
+	tools, _ := body["tools"].([]any)
+	return len(tools) > 0
+}
+
+func hasToolResult(body map[string]any) bool {
+	messages, _ := body["messages"].([]any)
+	for _, v := range messages {
+		if m, _ := v.(map[string]any); m["role"] == "tool" {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSend(t *testing.T) {
+	var bodies []map[string]any
+	ts := newTestServer(&bodies)
+	defer ts.Close()
+
+	runner := &testRunner{}
+	req := &api.Request{
+		Agent: &api.Agent{Pack: "test", Name: "ollama"},
+		Model: &api.Model{
+			Provider: ProviderOllama,
+			Model:    "llama3.2",
+			BaseUrl:  ts.URL + "/v1",
+		},
+		Messages: []*api.Message{
+			{Role: api.RoleUser, Content: "weather in Paris as json"},
+		},
+		Tools: []*api.ToolFunc{
+			{
+				Kit:         "weather",
+				Name:        "get",
+				Description: "get weather",
+				Parameters: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"city": map[string]any{"type": "string"},
+					},
+				},
+			},
+		},
+		Arguments: api.ArgMap{"response_format": "json_object"},
+		Runner:    runner,
+		Token:     func() string { return "" },
+	}
+	req.SetMaxTurns(3)
+
+	resp, err := Send(context.Background(), req)
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if resp.Result.Value != `{"weather":"sunny"}` {
+		t.Errorf("unexpected result: %q", resp.Result.Value)
+	}
+	if len(runner.calls) != 1 || runner.calls[0] != "weather__get" {
+		t.Errorf("expected tool call weather__get, got %v", runner.calls)
+	}
+	if len(bodies) != 2 {
+		t.Fatalf("expected 2 requests, got %v", len(bodies))
+	}
+	rf, _ := bodies[0]["response_format"].(map[string]any)
+	if rf["type"] != "json_object" {
+		t.Errorf("expected json mode, got %v", bodies[0]["response_format"])
+	}
+	if _, ok := bodies[0]["tools"]; !ok {
+		t.Errorf("expected tools in request")
+	}
+}
+
+func TestListModels(t *testing.T) {
+	var bodies []map[string]any
+	ts := newTestServer(&bodies)
+	defer ts.Close()
+
+	ids, err := ListModels(context.Background(), &api.Model{
+		Provider: ProviderOpenAICompatible,
+		BaseUrl:  ts.URL + "/v1/",
+	}, "")
+	if err != nil {
+		t.Fatalf("list models: %v", err)
+	}
+	if len(ids) != 2 || ids[0] != "llama3.2" || ids[1] != "qwen3" {
+		t.Errorf("unexpected models: %v", ids)
+	}
+}
+
+func TestBaseUrl(t *testing.T) {
+	if v := BaseUrl(&api.Model{Provider: ProviderOllama}); v != DefaultBaseUrl {
+		t.Errorf("expected default base url, got %q", v)
+	}
+	if v := BaseUrl(&api.Model{Provider: ProviderOpenAICompatible, BaseUrl: "http://host:8000/v1"}); v != "http://host:8000/v1/" {
+		t.Errorf("expected trailing slash, got %q", v)
+	}
+}
+
+func TestSendOutputSchema(t *testing.T) {
+	var bodies []map[string]any
+	ts := newTestServer(&bodies)
+	defer ts.Close()
+
+	schema := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"weather": map[string]any{"type": "string"},
+		},
+		"required": []any{"weather"},
+	}
+	req := &api.Request{
+		Agent: &api.Agent{Pack: "test", Name: "ollama"},
+		Model: &api.Model{
+			Provider: ProviderOpenAICompatible,
+			Model:    "llama3.2",
+			BaseUrl:  ts.URL + "/v1",
+		},
+		Messages: []*api.Message{
+			{Role: api.RoleUser, Content: "weather in Paris"},
+		},
+		Arguments: api.ArgMap{"response_format": "json_object", "output_schema": schema},
+		Runner:    &testRunner{},
+		Token:     func() string { return "" },
+	}
+
+	resp, err := Send(context.Background(), req)
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if _, err := api.ParseOutput(schema, resp.Result.Value); err != nil {
+		t.Errorf("output: %v", err)
+	}
+
+	if len(bodies) != 1 {
+		t.Fatalf("expected 1 request, got %v", len(bodies))
+	}
+	rf, _ := bodies[0]["response_format"].(map[string]any)
+	if rf["type"] != "json_schema" {
+		t.Fatalf("expected json_schema format, got %v", bodies[0]["response_format"])
+	}
+	js, _ := rf["json_schema"].(map[string]any)
+	if js["name"] != "output" || js["schema"] == nil {
+		t.Errorf("unexpected json_schema: %v", js)
+	}
+}
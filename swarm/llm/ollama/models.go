@@ -0,0 +1,31 @@
+package ollama
+
+import (
+	"context"
+	"sort"
+
+	"github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/option"
+
+	"github.com/qiangli/ai/swarm/api"
+)
+
+// ListModels returns the ids of the models served at the model's base url.
+// https://platform.openai.com/docs/api-reference/models/list
+func ListModels(ctx context.Context, model *api.Model, token string) ([]string, error) {
+	client := openai.NewClient(
+		option.WithAPIKey(token),
+		option.WithBaseURL(BaseUrl(model)),
+	)
+
+	var ids []string
+	iter := client.Models.ListAutoPaging(ctx)
+	for iter.Next() {
+		ids = append(ids, iter.Current().ID)
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
@@ -0,0 +1,52 @@
+package openai
+
+import (
+	"context"
+
+	"github.com/openai/openai-go/v3"
+
+	"github.com/qiangli/ai/swarm/api"
+)
+
+// StreamCompletion sends the chat request in streaming mode, shared by the openai compatible providers.
+// deltas are forwarded to the handler and the chunks are accumulated
+// into a complete ChatCompletion including usage.
+func StreamCompletion(ctx context.Context, client *openai.Client, params openai.ChatCompletionNewParams, h api.StreamHandler) (*openai.ChatCompletion, error) {
+	// usage is only reported in the last chunk if requested
+	params.StreamOptions = openai.ChatCompletionStreamOptionsParam{
+		IncludeUsage: openai.Bool(true),
+	}
+
+	stream := client.Chat.Completions.NewStreaming(ctx, params)
+	defer stream.Close()
+
+	acc := openai.ChatCompletionAccumulator{}
+	for stream.Next() {
+		chunk := stream.Current()
+		acc.AddChunk(chunk)
+
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		delta := chunk.Choices[0].Delta
+		if delta.Content != "" {
+			h(&api.StreamEvent{
+				Type: api.StreamEventText,
+				Text: delta.Content,
+			})
+		}
+		for _, tc := range delta.ToolCalls {
+			h(&api.StreamEvent{
+				Type:      api.StreamEventToolCall,
+				CallID:    tc.ID,
+				Name:      tc.Function.Name,
+				Arguments: tc.Function.Arguments,
+			})
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return nil, err
+	}
+
+	return &acc.ChatCompletion, nil
+}
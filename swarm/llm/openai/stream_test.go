@@ -0,0 +1,65 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/qiangli/ai/swarm/api"
+)
+
+func TestStream(t *testing.T) {
+	chunks := []string{
+		`{"id":"c1","object":"chat.completion.chunk","created":1,"model":"m","choices":[{"index":0,"delta":{"role":"assistant","content":"Hello"}}]}`,
+		`{"id":"c1","object":"chat.completion.chunk","created":1,"model":"m","choices":[{"index":0,"delta":{"content":", world"}}]}`,
+		`{"id":"c1","object":"chat.completion.chunk","created":1,"model":"m","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+		`{"id":"c1","object":"chat.completion.chunk","created":1,"model":"m","choices":[],"usage":{"prompt_tokens":3,"completion_tokens":2,"total_tokens":5}}`,
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, c := range chunks {
+			fmt.Fprintf(w, "data: %s\n\n", c)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer ts.Close()
+
+	req := &api.Request{
+		Agent: &api.Agent{
+			Pack: "test",
+			Name: "stream",
+		},
+		Model: &api.Model{
+			Model:    "m",
+			Provider: "openai",
+			BaseUrl:  ts.URL + "/",
+		},
+		Messages: []*api.Message{
+			{Role: api.RoleUser, Content: "hi"},
+		},
+		Token: func() string { return "test" },
+	}
+	req.SetMaxTurns(1)
+
+	var deltas []string
+	resp, err := Stream(context.Background(), req, func(ev *api.StreamEvent) {
+		if ev.Type == api.StreamEventText {
+			deltas = append(deltas, ev.Text)
+		}
+	})
+	if err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	if len(deltas) != 2 {
+		t.Errorf("expected 2 deltas, got %v", deltas)
+	}
+	if resp.Result.Value != strings.Join(deltas, "") {
+		t.Errorf("expected %q, got %q", strings.Join(deltas, ""), resp.Result.Value)
+	}
+	if resp.Result.TotalTokens != 5 {
+		t.Errorf("expected 5 total tokens, got %v", resp.Result.TotalTokens)
+	}
+}
@@ -158,12 +158,15 @@ func runTool(
 
 	data, err := runner.Run(ctx, name, props)
 	if err != nil {
-		return &api.Result{
+		out := &api.Result{
 			Value: err.Error(),
 		}
+		api.StreamToolResult(ctx, toolCall.CallID, name, out)
+		return out
 	}
 	out := api.ToResult(data)
 	log.GetLogger(ctx).Debugf("\n* tool call: %s out: %s\n", name, out)
+	api.StreamToolResult(ctx, toolCall.CallID, name, out)
 	return out
 }
 
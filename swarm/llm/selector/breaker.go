@@ -0,0 +1,215 @@
+package selector
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/openai/openai-go/v3"
+	"google.golang.org/genai"
+
+	"github.com/qiangli/ai/swarm/api"
+)
+
+// Failure classifies a provider error.
+type Failure int
+
+const (
+	// not related to the provider health, e.g. bad request or canceled by the user
+	FailureNone Failure = iota
+	// 429
+	FailureRateLimit
+	// 5xx or provider unreachable
+	FailureUnavailable
+	// 401/403 invalid or missing credentials
+	FailureAuth
+)
+
+func (r Failure) String() string {
+	switch r {
+	case FailureRateLimit:
+		return "rate_limit"
+	case FailureUnavailable:
+		return "unavailable"
+	case FailureAuth:
+		return "auth"
+	}
+	return "none"
+}
+
+const (
+	DefaultThreshold   = 3
+	DefaultCooldown    = 30 * time.Second
+	DefaultMaxCooldown = 10 * time.Minute
+	// credentials are not expected to be fixed within a session
+	DefaultAuthCooldown = time.Hour
+)
+
+// Breaker is a per provider circuit breaker implementing api.ModelHealth.
+//
+// A rate limited provider is skipped immediately, an unavailable one after
+// Threshold consecutive failures. The circuit stays open for the cooldown which doubles
+// with every further failure up to MaxCooldown; once it expires the provider is tried again
+// and a single success closes the circuit.
+type Breaker struct {
+	Threshold    int
+	Cooldown     time.Duration
+	MaxCooldown  time.Duration
+	AuthCooldown time.Duration
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+
+	// for testing
+	now func() time.Time
+}
+
+type circuit struct {
+	failures  int
+	openUntil time.Time
+}
+
+func NewBreaker() *Breaker {
+	return &Breaker{
+		Threshold:    DefaultThreshold,
+		Cooldown:     DefaultCooldown,
+		MaxCooldown:  DefaultMaxCooldown,
+		AuthCooldown: DefaultAuthCooldown,
+		circuits:     make(map[string]*circuit),
+		now:          time.Now,
+	}
+}
+
+// providers serving different endpoints fail independently
+func breakerKey(m *api.Model) string {
+	return m.Provider + "|" + m.BaseUrl
+}
+
+func (r *Breaker) Allow(m *api.Model) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, ok := r.circuits[breakerKey(m)]
+	if !ok {
+		return true
+	}
+	return !r.now().Before(c.openUntil)
+}
+
+func (r *Breaker) Success(m *api.Model) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.circuits, breakerKey(m))
+}
+
+func (r *Breaker) Failure(m *api.Model, err error) {
+	kind := Classify(err)
+	if kind == FailureNone {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := breakerKey(m)
+	c, ok := r.circuits[key]
+	if !ok {
+		c = &circuit{}
+		r.circuits[key] = c
+	}
+	c.failures++
+
+	now := r.now()
+	switch kind {
+	case FailureAuth:
+		c.openUntil = now.Add(r.AuthCooldown)
+	case FailureRateLimit:
+		c.openUntil = now.Add(r.backoff(c.failures))
+	case FailureUnavailable:
+		if c.failures >= r.Threshold {
+			c.openUntil = now.Add(r.backoff(c.failures - r.Threshold + 1))
+		}
+	}
+}
+
+// backoff returns the cooldown for the nth failure.
+func (r *Breaker) backoff(n int) time.Duration {
+	d := r.Cooldown
+	for i := 1; i < n && d < r.MaxCooldown; i++ {
+		d *= 2
+	}
+	return min(d, r.MaxCooldown)
+}
+
+// http status in error text, e.g.
+// POST "https://api.openai.com/v1/chat/completions": 503 Service Unavailable
+// Error 429, Message: ...
+var statusPattern = regexp.MustCompile(`\b(?:(401|403|429|5\d\d) (?:unauthorized|forbidden|too many requests|internal server error|not implemented|bad gateway|service unavailable|gateway timeout)|(?:status|status code|error) ?[:=]? ?(401|403|429|5\d\d)\b)`)
+
+// Classify maps a provider error to the kind of failure.
+func Classify(err error) Failure {
+	if err == nil {
+		return FailureNone
+	}
+	if errors.Is(err, context.Canceled) {
+		return FailureNone
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return FailureUnavailable
+	}
+
+	status := 0
+	var oe *openai.Error
+	var ae *anthropic.Error
+	var ge genai.APIError
+	var gep *genai.APIError
+	switch {
+	case errors.As(err, &oe):
+		status = oe.StatusCode
+	case errors.As(err, &ae):
+		status = ae.StatusCode
+	case errors.As(err, &ge):
+		status = ge.Code
+	case errors.As(err, &gep):
+		status = gep.Code
+	}
+	if status != 0 {
+		return classifyStatus(status)
+	}
+
+	var ne net.Error
+	if errors.As(err, &ne) {
+		return FailureUnavailable
+	}
+
+	// errors flattened into text by adapters
+	msg := strings.ToLower(err.Error())
+	if strings.Contains(msg, "rate limit") || strings.Contains(msg, "too many requests") {
+		return FailureRateLimit
+	}
+	if m := statusPattern.FindStringSubmatch(msg); m != nil {
+		code, _ := strconv.Atoi(m[1] + m[2])
+		return classifyStatus(code)
+	}
+	return FailureNone
+}
+
+func classifyStatus(status int) Failure {
+	switch {
+	case status == http.StatusTooManyRequests:
+		return FailureRateLimit
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return FailureAuth
+	case status >= 500:
+		return FailureUnavailable
+	}
+	return FailureNone
+}
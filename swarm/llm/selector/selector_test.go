@@ -0,0 +1,149 @@
+package selector
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/qiangli/ai/swarm/api"
+)
+
+func names(models []*api.Model) []string {
+	var list []string
+	for _, m := range models {
+		list = append(list, m.Model)
+	}
+	return list
+}
+
+func TestOrder(t *testing.T) {
+	models := []*api.Model{
+		{Model: "a", Provider: "openai", InputCost: 2, OutputCost: 8},
+		{Model: "b", Provider: "anthropic"},
+		{Model: "c", Provider: "gemini", InputCost: 0.1, OutputCost: 0.4},
+	}
+
+	v, err := Order("", models, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(names(v)); got != "[a b c]" {
+		t.Errorf("ordered: got %s", got)
+	}
+
+	v, _ = Order(api.StrategyCheapest, models, nil)
+	if got := fmt.Sprint(names(v)); got != "[c a b]" {
+		t.Errorf("cheapest: got %s", got)
+	}
+	// input untouched
+	if got := fmt.Sprint(names(models)); got != "[a b c]" {
+		t.Errorf("models modified: %s", got)
+	}
+
+	if _, err := Order("fastest", models, nil); err == nil {
+		t.Errorf("expected error for unknown strategy")
+	}
+
+	// failing provider goes last
+	b := NewBreaker()
+	b.Failure(models[0], fmt.Errorf("Error 429, Message: quota exceeded"))
+	v, _ = Order(api.StrategyOrdered, models, b)
+	if got := fmt.Sprint(names(v)); got != "[b c a]" {
+		t.Errorf("health: got %s", got)
+	}
+}
+
+func TestOrderWeighted(t *testing.T) {
+	models := []*api.Model{
+		{Model: "light", Weight: 1},
+		{Model: "heavy", Weight: 1000},
+	}
+	first := 0
+	for range 100 {
+		v, _ := Order(api.StrategyWeighted, models, nil)
+		if len(v) != 2 {
+			t.Fatalf("expected 2 models, got %d", len(v))
+		}
+		if v[0].Model == "heavy" {
+			first++
+		}
+	}
+	if first < 90 {
+		t.Errorf("heavy model first %d/100 times", first)
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	now := time.Now()
+	b := NewBreaker()
+	b.now = func() time.Time { return now }
+
+	m := &api.Model{Provider: "openai"}
+	unavailable := fmt.Errorf(`POST "https://api.openai.com/v1/chat/completions": 503 Service Unavailable`)
+
+	for i := 1; i < b.Threshold; i++ {
+		b.Failure(m, unavailable)
+		if !b.Allow(m) {
+			t.Fatalf("open after %d failures", i)
+		}
+	}
+	b.Failure(m, unavailable)
+	if b.Allow(m) {
+		t.Fatalf("expected open circuit")
+	}
+
+	// other endpoint of the same provider is not affected
+	if !b.Allow(&api.Model{Provider: "openai", BaseUrl: "http://localhost:8080/v1/"}) {
+		t.Errorf("expected other endpoint allowed")
+	}
+
+	// retry after cooldown
+	now = now.Add(b.Cooldown)
+	if !b.Allow(m) {
+		t.Fatalf("expected half open circuit after cooldown")
+	}
+	b.Failure(m, unavailable)
+	if b.Allow(m) {
+		t.Fatalf("expected open circuit after failed retry")
+	}
+	now = now.Add(b.Cooldown)
+	if b.Allow(m) {
+		t.Fatalf("expected doubled cooldown")
+	}
+	now = now.Add(b.Cooldown)
+	b.Success(m)
+	if !b.Allow(m) {
+		t.Fatalf("expected closed circuit after success")
+	}
+
+	// errors unrelated to the provider health
+	for range 10 {
+		b.Failure(m, context.Canceled)
+		b.Failure(m, fmt.Errorf("400 Bad Request: max_tokens 512 exceeded"))
+	}
+	if !b.Allow(m) {
+		t.Errorf("expected closed circuit")
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		err  error
+		want Failure
+	}{
+		{nil, FailureNone},
+		{context.Canceled, FailureNone},
+		{context.DeadlineExceeded, FailureUnavailable},
+		{fmt.Errorf("POST \"https://x\": 401 Unauthorized"), FailureAuth},
+		{fmt.Errorf("Error 403, Message: denied"), FailureAuth},
+		{fmt.Errorf("rate limit reached"), FailureRateLimit},
+		{fmt.Errorf("status code: 502"), FailureUnavailable},
+		{fmt.Errorf("input of 500 tokens"), FailureNone},
+	}
+	for _, tt := range tests {
+		if got := Classify(tt.err); got != tt.want {
+			t.Errorf("Classify(%v): got %v want %v", tt.err, got, tt.want)
+		}
+	}
+}
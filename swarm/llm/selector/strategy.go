@@ -0,0 +1,90 @@
+// Package selector decides the order in which a list of models is tried
+// and tracks the health of model providers across calls.
+package selector
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/qiangli/ai/swarm/api"
+)
+
+// Strategies lists the supported model selection strategies.
+var Strategies = []string{
+	api.StrategyOrdered,
+	api.StrategyWeighted,
+	api.StrategyCheapest,
+	api.StrategyRandom,
+}
+
+// Order returns a copy of the models in the order they should be tried.
+// The models are first ordered by the strategy, defaults to ordered;
+// models of providers the health tracker reports as unavailable are then moved to the end.
+func Order(strategy string, models []*api.Model, health api.ModelHealth) ([]*api.Model, error) {
+	list := make([]*api.Model, len(models))
+	copy(list, models)
+
+	rd := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	switch strategy {
+	case "", api.StrategyOrdered:
+	case api.StrategyRandom:
+		rd.Shuffle(len(list), func(i, j int) {
+			list[i], list[j] = list[j], list[i]
+		})
+	case api.StrategyWeighted:
+		weighted(list, rd)
+	case api.StrategyCheapest:
+		cheapest(list)
+	default:
+		return nil, fmt.Errorf("unknown model strategy: %q. supported: %v", strategy, Strategies)
+	}
+
+	if health == nil {
+		return list, nil
+	}
+
+	// keep the unavailable models as the last resort
+	var allowed, blocked []*api.Model
+	for _, m := range list {
+		if health.Allow(m) {
+			allowed = append(allowed, m)
+		} else {
+			blocked = append(blocked, m)
+		}
+	}
+	return append(allowed, blocked...), nil
+}
+
+// weighted shuffles the models so that a model with a higher weight is more likely to come first.
+// weighted random sampling without replacement (Efraimidis-Spirakis)
+// models without a weight count as weight 1.
+func weighted(list []*api.Model, rd *rand.Rand) {
+	keys := make(map[*api.Model]float64, len(list))
+	for _, m := range list {
+		w := float64(max(m.Weight, 1))
+		// 1-u in (0,1]
+		u := 1 - rd.Float64()
+		keys[m] = math.Log(u) / w
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return keys[list[i]] > keys[list[j]]
+	})
+}
+
+// cheapest sorts the models by price, models without pricing go last in their original order.
+func cheapest(list []*api.Model) {
+	cost := func(m *api.Model) float64 {
+		return m.InputCost + m.OutputCost
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		ci, cj := cost(list[i]), cost(list[j])
+		if ci == 0 || cj == 0 {
+			return ci != 0 && cj == 0
+		}
+		return ci < cj
+	})
+}
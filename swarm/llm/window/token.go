@@ -0,0 +1,68 @@
+package window
+
+import (
+	"encoding/json"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/qiangli/ai/swarm/api"
+)
+
+// per message overhead of the chat format: role, separators...
+const messageOverhead = 4
+
+// EstimateTokens approximates the number of tokens of the text without a tokenizer.
+// ASCII words count about one token per 4 characters, punctuation one token each
+// and other letters such as CJK one token per character.
+// The estimate errs on the high side for non English text.
+func EstimateTokens(s string) int {
+	var tokens, word int
+	flush := func() {
+		tokens += (word + 3) / 4
+		word = 0
+	}
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		switch {
+		case r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			word++
+		case unicode.IsSpace(r):
+			flush()
+		default:
+			flush()
+			tokens++
+		}
+	}
+	flush()
+	return tokens
+}
+
+// EstimateMessage approximates the number of tokens of the message including the tool calls.
+func EstimateMessage(m *api.Message) int {
+	n := messageOverhead + EstimateTokens(m.Content)
+	for _, tc := range m.ToolCalls {
+		args, _ := json.Marshal(tc.Arguments)
+		n += messageOverhead + EstimateTokens(tc.Command) + EstimateTokens(string(args))
+	}
+	return n
+}
+
+// Estimate approximates the number of tokens of the messages.
+func Estimate(messages []*api.Message) int {
+	var n int
+	for _, m := range messages {
+		n += EstimateMessage(m)
+	}
+	return n
+}
+
+// EstimateTools approximates the number of tokens of the tool definitions sent with each request.
+func EstimateTools(tools []*api.ToolFunc) int {
+	var n int
+	for _, f := range tools {
+		params, _ := json.Marshal(f.Parameters)
+		n += messageOverhead + EstimateTokens(f.ID()) + EstimateTokens(f.Description) + EstimateTokens(string(params))
+	}
+	return n
+}
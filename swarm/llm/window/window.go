@@ -0,0 +1,279 @@
+// Package window fits the conversation to the context window of the model
+// before each call using the strategy selected by the agent.
+package window
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/qiangli/ai/swarm/api"
+	"github.com/qiangli/ai/swarm/log"
+)
+
+// Context strategies
+const (
+	// drop the oldest messages
+	StrategyDrop = "drop"
+	// summarize the older history, keeping the system and latest user messages and the recent history
+	StrategySummarize = "summarize"
+	// drop the oldest messages except the system and latest user messages
+	StrategyPin = "pin"
+	// send as is
+	StrategyNone = "none"
+)
+
+// context window of models not declaring context_window
+const DefaultContextWindow = 128000
+
+const summaryPrefix = "Summary of the earlier conversation:\n\n"
+
+// Summarizer returns the summary of the messages, typically using a cheaper model.
+type Summarizer func(ctx context.Context, messages []*api.Message) (string, error)
+
+// Window fits the messages to the token limit.
+// It is used for all the calls of one request and remembers the last summary
+// so the history is not summarized again as the tool calling loop goes on.
+type Window struct {
+	Strategy string
+	// max input tokens
+	Limit int
+
+	Summarize Summarizer
+
+	summary    *api.Message
+	summarized []*api.Message
+}
+
+func IsStrategy(s string) bool {
+	switch s {
+	case StrategyDrop, StrategySummarize, StrategyPin, StrategyNone:
+		return true
+	}
+	return false
+}
+
+// New returns the window for the strategy, pin if empty.
+func New(strategy string, limit int, summarize Summarizer) (*Window, error) {
+	if strategy == "" {
+		strategy = StrategyPin
+	}
+	if !IsStrategy(strategy) {
+		return nil, fmt.Errorf("invalid context strategy: %q. supported: drop, summarize, pin, none", strategy)
+	}
+	if strategy == StrategySummarize && summarize == nil {
+		return nil, fmt.Errorf("summarizer is required for the summarize strategy")
+	}
+	if limit <= 0 {
+		limit = DefaultContextWindow
+	}
+	return &Window{
+		Strategy:  strategy,
+		Limit:     limit,
+		Summarize: summarize,
+	}, nil
+}
+
+// Fit returns the messages fitting the limit, the messages as is if they fit already.
+// The messages are not modified.
+func (w *Window) Fit(ctx context.Context, messages []*api.Message) ([]*api.Message, error) {
+	if w.Strategy == StrategyNone {
+		return messages, nil
+	}
+	total := Estimate(messages)
+	if total <= w.Limit {
+		return messages, nil
+	}
+
+	var list []*api.Message
+	switch w.Strategy {
+	case StrategyDrop:
+		list = drop(messages, w.Limit, func(i int) bool {
+			return i == len(messages)-1
+		})
+	case StrategyPin:
+		list = drop(messages, w.Limit, pinned(messages))
+	case StrategySummarize:
+		v, err := w.summarize(ctx, messages)
+		if err != nil {
+			log.GetLogger(ctx).Errorf("❌ failed to summarize the history, dropping the oldest messages instead: %v\n", err)
+			v = drop(messages, w.Limit, pinned(messages))
+		}
+		list = v
+	}
+
+	list, err := shrink(list, w.Limit)
+	if err != nil {
+		return nil, err
+	}
+	log.GetLogger(ctx).Infof("⣿ context %s: %v messages ~%v tokens → %v messages ~%v tokens (limit %v)\n", w.Strategy, len(messages), total, len(list), Estimate(list), w.Limit)
+	return list, nil
+}
+
+// pinned returns whether the message at i is a system message or the latest user message.
+func pinned(messages []*api.Message) func(int) bool {
+	var last = -1
+	for i, v := range messages {
+		if v.Role == api.RoleUser {
+			last = i
+		}
+	}
+	return func(i int) bool {
+		return i == last || messages[i].Role == api.RoleSystem
+	}
+}
+
+// drop removes the oldest messages not pinned until the rest fits the limit.
+func drop(messages []*api.Message, limit int, pin func(int) bool) []*api.Message {
+	total := Estimate(messages)
+	var skip = make(map[int]bool)
+	for i, v := range messages {
+		if total <= limit {
+			break
+		}
+		if pin(i) {
+			continue
+		}
+		skip[i] = true
+		total -= EstimateMessage(v)
+	}
+	var list []*api.Message
+	for i, v := range messages {
+		if !skip[i] {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// summarize replaces the older messages with their summary.
+// The recent messages are kept within half of the budget left by the pinned messages.
+func (w *Window) summarize(ctx context.Context, messages []*api.Message) ([]*api.Message, error) {
+	pin := pinned(messages)
+	var budget = w.Limit
+	for i, v := range messages {
+		if pin(i) {
+			budget -= EstimateMessage(v)
+		}
+	}
+	// keep the recent history
+	var keep = make(map[int]bool)
+	var recent = budget / 2
+	for i := len(messages) - 1; i >= 0; i-- {
+		if pin(i) {
+			continue
+		}
+		n := EstimateMessage(messages[i])
+		if n > recent {
+			break
+		}
+		recent -= n
+		keep[i] = true
+	}
+	var old []*api.Message
+	for i, v := range messages {
+		if !pin(i) && !keep[i] {
+			old = append(old, v)
+		}
+	}
+	if len(old) == 0 {
+		return messages, nil
+	}
+
+	summary, err := w.summarizeOld(ctx, old)
+	if err != nil {
+		return nil, err
+	}
+
+	// system messages, the summary and the rest in order
+	var list []*api.Message
+	var added bool
+	for i, v := range messages {
+		if !pin(i) && !keep[i] {
+			continue
+		}
+		if !added && v.Role != api.RoleSystem {
+			list = append(list, summary)
+			added = true
+		}
+		list = append(list, v)
+	}
+	if !added {
+		list = append(list, summary)
+	}
+	return list, nil
+}
+
+// summarizeOld returns the summary of the messages, updating the last summary
+// with the messages added since.
+func (w *Window) summarizeOld(ctx context.Context, old []*api.Message) (*api.Message, error) {
+	var input = old
+	if w.summary != nil && len(old) >= len(w.summarized) && slices.Equal(old[:len(w.summarized)], w.summarized) {
+		if len(old) == len(w.summarized) {
+			return w.summary, nil
+		}
+		input = append([]*api.Message{w.summary}, old[len(w.summarized):]...)
+	}
+
+	log.GetLogger(ctx).Infof("⣿ summarizing %v messages\n", len(input))
+	s, err := w.Summarize(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	w.summary = &api.Message{
+		ID:      uuid.NewString(),
+		Created: time.Now(),
+		Role:    api.RoleSystem,
+		Content: summaryPrefix + strings.TrimSpace(s),
+		Context: true,
+	}
+	w.summarized = slices.Clone(old)
+	return w.summary, nil
+}
+
+// shrink truncates the content of the largest messages in the middle until all fit the limit.
+func shrink(messages []*api.Message, limit int) ([]*api.Message, error) {
+	total := Estimate(messages)
+	if total <= limit {
+		return messages, nil
+	}
+	list := slices.Clone(messages)
+	for total > limit {
+		var largest = -1
+		for i, v := range list {
+			if largest < 0 || EstimateMessage(v) > EstimateMessage(list[largest]) {
+				largest = i
+			}
+		}
+		m := *list[largest]
+		before := EstimateMessage(&m)
+		// about 4 characters per token, at least half of the excess
+		size := len(m.Content) - max((total-limit)*4, len(m.Content)/2)
+		if size <= 0 || len(m.Content) == 0 {
+			return nil, fmt.Errorf("context window exceeded: ~%v tokens > %v", total, limit)
+		}
+		m.Content = Truncate(m.Content, size)
+		after := EstimateMessage(&m)
+		if after >= before {
+			return nil, fmt.Errorf("context window exceeded: ~%v tokens > %v", total, limit)
+		}
+		list[largest] = &m
+		total -= before - after
+	}
+	return list, nil
+}
+
+// Truncate returns the head and tail of s within about size bytes, noting the cut in the middle.
+func Truncate(s string, size int) string {
+	if len(s) <= size {
+		return s
+	}
+	half := size / 2
+	head := strings.ToValidUTF8(s[:half], "")
+	tail := strings.ToValidUTF8(s[len(s)-half:], "")
+	return fmt.Sprintf("%s\n...[%v characters truncated]...\n%s", head, len(s)-len(head)-len(tail), tail)
+}
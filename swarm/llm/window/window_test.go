@@ -0,0 +1,140 @@
+package window
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/qiangli/ai/swarm/api"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int
+	}{
+		{"", 0},
+		{"hello world", 4},
+		{"hi, there!", 5},
+		{"你好", 2},
+	}
+	for _, tc := range tests {
+		if got := EstimateTokens(tc.s); got != tc.want {
+			t.Errorf("EstimateTokens(%q) = %v, want %v", tc.s, got, tc.want)
+		}
+	}
+}
+
+// conversation of n exchanges of about 100 tokens each message
+func conversation(n int) []*api.Message {
+	text := strings.Repeat("word ", 96)
+	list := []*api.Message{{Role: api.RoleSystem, Content: "be helpful"}}
+	for i := range n {
+		list = append(list,
+			&api.Message{ID: fmt.Sprint("u", i), Role: api.RoleUser, Content: text},
+			&api.Message{ID: fmt.Sprint("a", i), Role: api.RoleAssistant, Content: text},
+		)
+	}
+	return list
+}
+
+func TestFit(t *testing.T) {
+	ctx := context.TODO()
+	messages := append(conversation(10), &api.Message{Role: api.RoleTool, Content: "result"})
+
+	w, _ := New(StrategyDrop, 500, nil)
+	list, err := w.Fit(ctx, messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Estimate(list) > 500 || list[len(list)-1].Role != api.RoleTool {
+		t.Errorf("drop: %v messages %v tokens", len(list), Estimate(list))
+	}
+	if list[0].Role == api.RoleSystem {
+		t.Errorf("drop: system message kept")
+	}
+
+	w, _ = New(StrategyPin, 500, nil)
+	list, err = w.Fit(ctx, messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Estimate(list) > 500 || list[0].Role != api.RoleSystem {
+		t.Errorf("pin: %v messages %v tokens", len(list), Estimate(list))
+	}
+	var latest bool
+	for _, v := range list {
+		latest = latest || v.ID == "u9"
+	}
+	if !latest {
+		t.Errorf("pin: latest user message dropped")
+	}
+
+	// fits as is
+	w, _ = New(StrategyPin, 100000, nil)
+	if list, _ = w.Fit(ctx, messages); len(list) != len(messages) {
+		t.Errorf("messages dropped: %v", len(list))
+	}
+}
+
+func TestFitSummarize(t *testing.T) {
+	ctx := context.TODO()
+	var calls []int
+	summarize := func(ctx context.Context, messages []*api.Message) (string, error) {
+		calls = append(calls, len(messages))
+		return "earlier", nil
+	}
+	w, err := New(StrategySummarize, 1000, summarize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messages := conversation(10)
+	list, err := w.Fit(ctx, messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 1 || Estimate(list) > 1000 {
+		t.Fatalf("summarize calls: %v tokens: %v", calls, Estimate(list))
+	}
+	if list[0].Role != api.RoleSystem || !strings.HasSuffix(list[1].Content, "earlier") {
+		t.Errorf("summary: %+v", list[1])
+	}
+	if last := list[len(list)-1]; last.ID != "a9" {
+		t.Errorf("recent messages not kept: %+v", last)
+	}
+
+	// same history, summary reused
+	if _, err := w.Fit(ctx, messages); err != nil || len(calls) != 1 {
+		t.Errorf("summary not reused: %v %v", calls, err)
+	}
+
+	// more history, the last summary is updated with the older messages since
+	messages = append(messages, conversation(4)[1:]...)
+	if _, err := w.Fit(ctx, messages); err != nil || len(calls) != 2 || calls[1] >= len(messages)/2 {
+		t.Errorf("summary not updated: %v %v", calls, err)
+	}
+}
+
+func TestFitTruncate(t *testing.T) {
+	w, _ := New(StrategyPin, 200, nil)
+	messages := []*api.Message{
+		{Role: api.RoleSystem, Content: "be helpful"},
+		{Role: api.RoleUser, Content: strings.Repeat("word ", 1000)},
+	}
+	list, err := w.Fit(context.TODO(), messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Estimate(list) > 200 || !strings.Contains(list[1].Content, "truncated") {
+		t.Errorf("not truncated: %v tokens", Estimate(list))
+	}
+	if len(messages[1].Content) != 5000 {
+		t.Errorf("input modified")
+	}
+
+	if _, err := New("oldest", 100, nil); err == nil {
+		t.Errorf("invalid strategy accepted")
+	}
+}
@@ -11,6 +11,8 @@ import (
 	"github.com/openai/openai-go/v3/packages/param"
 
 	"github.com/qiangli/ai/swarm/api"
+	"github.com/qiangli/ai/swarm/llm/loop"
+	oai "github.com/qiangli/ai/swarm/llm/openai"
 	"github.com/qiangli/ai/swarm/log"
 )
 
@@ -35,13 +37,24 @@ func Send(ctx context.Context, req *api.Request) (*api.Response, error) {
 	var err error
 	var resp *api.Response
 
-	resp, err = call(ctx, req)
+	resp, err = call(ctx, req, nil)
 
 	log.GetLogger(ctx).Debugf(">XAI:\n resp: %+v err: %v\n", resp, err)
 	return resp, err
 }
 
-func call(ctx context.Context, req *api.Request) (*api.Response, error) {
+// Stream is the streaming variant of Send.
+// text and tool call deltas are delivered to the handler as they arrive.
+func Stream(ctx context.Context, req *api.Request, h api.StreamHandler) (*api.Response, error) {
+	log.GetLogger(ctx).Debugf(">XAI:\n stream req: %+v\n", req)
+
+	resp, err := call(ctx, req, h)
+
+	log.GetLogger(ctx).Debugf(">XAI:\n stream resp: %+v err: %v\n", resp, err)
+	return resp, err
+}
+
+func call(ctx context.Context, req *api.Request, h api.StreamHandler) (*api.Response, error) {
 	client, err := NewClient(req.Model, req.Token())
 	if err != nil {
 		return nil, err
@@ -53,36 +66,6 @@ func call(ctx context.Context, req *api.Request) (*api.Response, error) {
 	if req.Arguments != nil {
 		setChatCompletionNewParams(&params, req.Arguments)
 	}
-	if len(req.Messages) > 0 {
-		var messages []openai.ChatCompletionMessageParamUnion
-		for _, v := range req.Messages {
-			// https://platform.openai.com/docs/guides/text-generation#developer-messages
-			// https://model-spec.openai.com/2025-02-12.html
-			switch v.Role {
-			case "system":
-				messages = append(messages, openai.SystemMessage(v.Content))
-			// case "developer":
-			// 	messages = append(messages, openai.DeveloperMessage(v.Content))
-			case "assistant":
-				messages = append(messages, openai.AssistantMessage(v.Content))
-			case "user":
-				if v.ContentType != "" {
-					messages = append(messages, openai.UserMessage(toContentPart(v.ContentType, []byte(v.Content))))
-				} else {
-					messages = append(messages, openai.UserMessage(v.Content))
-				}
-			// case "tool":
-			// 	return openai.ToolMessage(content, id), nil
-			default:
-				// log.GetLogger(ctx).Errorf("Role not supported: %s", v.Role)
-			}
-		}
-		params.Messages = messages
-	}
-
-	if len(req.Messages) == 0 {
-		return nil, fmt.Errorf("no input message")
-	}
 
 	if len(req.Tools) > 0 {
 		var tools []openai.ChatCompletionToolUnionParam
@@ -92,88 +75,98 @@ func call(ctx context.Context, req *api.Request) (*api.Response, error) {
 		params.Tools = tools
 	}
 
-	var maxTurns = req.MaxTurns()
-	if maxTurns <= 0 {
-		maxTurns = api.DefaultMaxTurns
-	}
-
-	var resp = &api.Response{}
-
-	log.GetLogger(ctx).Debugf("[XAI] params messages: %v tools: %v\n", len(params.Messages), len(params.Tools))
+	log.GetLogger(ctx).Debugf("[XAI] params messages: %v tools: %v\n", len(req.Messages), len(params.Tools))
 
-	for tries := range maxTurns {
-		log.GetLogger(ctx).Infof(api.FormatRequestLine(req, "chat", maxTurns, tries))
+	complete := func(ctx context.Context, messages []*api.Message) (*loop.Turn, error) {
+		params.Messages = toMessages(messages)
 
-		log.GetLogger(ctx).Debugf("📡 sending chat request to %s: %v of %v\n%+v\n", req.Model.BaseUrl, tries, maxTurns, req)
-
-		completion, err := client.Chat.Completions.New(ctx, params)
+		var completion *openai.ChatCompletion
+		var err error
+		if h != nil {
+			completion, err = oai.StreamCompletion(ctx, client, params, h)
+		} else {
+			completion, err = client.Chat.Completions.New(ctx, params)
+		}
 		if err != nil {
-			log.GetLogger(ctx).Errorf("❌ %s\n", err)
 			return nil, err
 		}
-		log.GetLogger(ctx).Infof("(%s)\n", formatReason(completion.Choices[0].FinishReason))
-
-		toolCalls := completion.Choices[0].Message.ToolCalls
-		if len(toolCalls) == 0 {
-			resp.Result = &api.Result{
-				Role:         string(completion.Choices[0].Message.Role),
-				MimeType:     "text/plain",
-				Value:        completion.Choices[0].Message.Content,
-				Usage:        completion.Usage,
-				InputTokens:  completion.Usage.PromptTokens,
-				OutputTokens: completion.Usage.CompletionTokens,
-				TotalTokens:  completion.Usage.TotalTokens,
-			}
-
-			// request completed
-			return resp, nil
+		if len(completion.Choices) == 0 {
+			return nil, fmt.Errorf("No choices in the completion")
 		}
+		choice := completion.Choices[0]
 
-		params.Messages = append(params.Messages, completion.Choices[0].Message.ToParam())
-		// results := runTools(ctx, req.RunTool, toolCalls, maxThreadLimit)
-		calls := make([]*api.ToolCall, len(toolCalls))
-		for i, v := range toolCalls {
+		calls := make([]*api.ToolCall, len(choice.Message.ToolCalls))
+		for i, v := range choice.Message.ToolCalls {
 			var props map[string]any
 			if err := json.Unmarshal([]byte(v.Function.Arguments), &props); err != nil {
 				return nil, err
 			}
-			// // copy request args if not found in props
-			// for k, v := range req.Arguments {
-			// 	if _, ok := props[k]; !ok {
-			// 		props[k] = v
-			// 	}
-			// }
-
 			calls[i] = api.NewToolCall(
 				v.ID,
 				v.Function.Name,
 				props,
 			)
 		}
-		results := runToolsV3(ctx, req.Runner, calls, maxThreadLimit)
-		for i, out := range results {
-			if out == nil {
-				params.Messages = append(params.Messages, openai.ToolMessage("no result", calls[i].CallID))
+		return &loop.Turn{
+			Role:         string(choice.Message.Role),
+			Content:      choice.Message.Content,
+			ToolCalls:    calls,
+			Reason:       formatReason(choice.FinishReason),
+			Usage:        completion.Usage,
+			InputTokens:  completion.Usage.PromptTokens,
+			OutputTokens: completion.Usage.CompletionTokens,
+			TotalTokens:  completion.Usage.TotalTokens,
+		}, nil
+	}
+
+	return loop.Run(ctx, req, loop.CompleterFunc(complete))
+}
+
+func toMessages(list []*api.Message) []openai.ChatCompletionMessageParamUnion {
+	var messages []openai.ChatCompletionMessageParamUnion
+	for _, v := range list {
+		// https://platform.openai.com/docs/guides/text-generation#developer-messages
+		// https://model-spec.openai.com/2025-02-12.html
+		switch v.Role {
+		case "system":
+			messages = append(messages, openai.SystemMessage(v.Content))
+		// case "developer":
+		// 	messages = append(messages, openai.DeveloperMessage(v.Content))
+		case "assistant":
+			if len(v.ToolCalls) == 0 {
+				messages = append(messages, openai.AssistantMessage(v.Content))
 				continue
 			}
-			// if out.State == api.StateExit {
-			// 	resp.Result = out
-			// 	return resp, nil
-			// }
-			if out.State == api.StateTransfer {
-				resp.Result = out
-				return resp, nil
+			var m openai.ChatCompletionAssistantMessageParam
+			if v.Content != "" {
+				m.Content.OfString = openai.String(v.Content)
 			}
-
-			params.Messages = append(params.Messages, openai.ToolMessage(out.Value, calls[i].CallID))
+			for _, tc := range v.ToolCalls {
+				args, _ := json.Marshal(tc.Arguments)
+				m.ToolCalls = append(m.ToolCalls, openai.ChatCompletionMessageToolCallUnionParam{
+					OfFunction: &openai.ChatCompletionMessageFunctionToolCallParam{
+						ID: tc.CallID,
+						Function: openai.ChatCompletionMessageFunctionToolCallFunctionParam{
+							Name:      tc.Command,
+							Arguments: string(args),
+						},
+					},
+				})
+			}
+			messages = append(messages, openai.ChatCompletionMessageParamUnion{OfAssistant: &m})
+		case "tool":
+			messages = append(messages, openai.ToolMessage(v.Content, v.ToolCallID))
+		case "user":
+			if v.ContentType != "" {
+				messages = append(messages, openai.UserMessage(toContentPart(v.ContentType, []byte(v.Content))))
+			} else {
+				messages = append(messages, openai.UserMessage(v.Content))
+			}
+		default:
+			// log.GetLogger(ctx).Errorf("Role not supported: %s", v.Role)
 		}
 	}
-
-	// not finished due the max turns reached
-	if resp.Result == nil {
-		return nil, fmt.Errorf("Empty response. Max turns reached: %v. Try again with a higher value for the 'max_turns' parameter", maxTurns)
-	}
-	return resp, nil
+	return messages
 }
 
 // https://developer.mozilla.org/en-US/docs/Web/URI/Reference/Schemes/data
@@ -282,4 +275,15 @@ func setChatCompletionNewParams(params *openai.ChatCompletionNewParams, args api
 	if v, ok := args.Get2("parallel_tool_calls"); ok {
 		params.ParallelToolCalls = openai.Bool(toBool(v, false))
 	}
+	// Structured outputs
+	if schema, _ := api.OutputSchema(args); schema != nil {
+		params.ResponseFormat = openai.ChatCompletionNewParamsResponseFormatUnion{
+			OfJSONSchema: &openai.ResponseFormatJSONSchemaParam{
+				JSONSchema: openai.ResponseFormatJSONSchemaJSONSchemaParam{
+					Name:   "output",
+					Schema: schema,
+				},
+			},
+		}
+	}
 }
@@ -1,7 +1,6 @@
 package xai
 
 import (
-	"context"
 	// "encoding/base64"
 	// "errors"
 	"fmt"
@@ -11,17 +10,13 @@ import (
 	// "path/filepath"
 	"strconv"
 	// "strings"
-	"sync"
 
 	"github.com/openai/openai-go/v3"
 	"github.com/openai/openai-go/v3/option"
 
 	"github.com/qiangli/ai/swarm/api"
-	"github.com/qiangli/ai/swarm/log"
 )
 
-const maxThreadLimit = 3
-
 func NewClient(model *api.Model, token string) (*openai.Client, error) {
 	client := openai.NewClient(
 		option.WithAPIKey(token),
@@ -43,111 +38,6 @@ func NewClientV3(model *api.Model, token string) (*openai.Client, error) {
 	return &client, nil
 }
 
-func runToolsV3(
-	parent context.Context,
-	runner api.ActionRunner,
-	calls []*api.ToolCall,
-	max int,
-) []*api.Result {
-	switch len(calls) {
-	case 0:
-		return nil
-	case 1:
-		return []*api.Result{runTool(parent, runner, calls[0])}
-	default:
-		return runToolsInParallel(parent, runner, calls, max)
-	}
-}
-
-func runToolsInParallel(
-	parent context.Context,
-	runner api.ActionRunner,
-	toolCalls []*api.ToolCall,
-	max int,
-) []*api.Result {
-	var wg sync.WaitGroup
-
-	semaphore := make(chan struct{}, max)
-	results := make([]*api.Result, len(toolCalls))
-
-	ctx, cancel := context.WithCancel(parent)
-	defer cancel()
-
-	log.GetLogger(parent).Debugf("\n* tool call count: %v", len(toolCalls))
-
-	for i, toolCall := range toolCalls {
-		wg.Add(1)
-
-		go func(i int, toolCall *api.ToolCall) {
-			defer wg.Done()
-
-			select {
-			case semaphore <- struct{}{}:
-				defer func() { <-semaphore }()
-			case <-ctx.Done():
-				return
-			}
-
-			var name = toolCall.Command
-			var args = toolCall.Arguments
-			var props = make(map[string]any)
-			if args != nil {
-				args.Copy(props)
-			}
-			log.GetLogger(parent).Debugf("\n* tool call: %v %s props: %+v\n", i, name, props)
-
-			data, err := runner.Run(ctx, name, props)
-			if err != nil {
-				results[i] = &api.Result{
-					Value: err.Error(),
-				}
-				return
-			}
-
-			out := api.ToResult(data)
-
-			log.GetLogger(parent).Debugf("\n* tool call: %s out: %s\n", name, out)
-
-			results[i] = out
-
-			// if out.State == api.StateExit {
-			// 	cancel()
-			// }
-		}(i, toolCall)
-	}
-
-	wg.Wait()
-
-	close(semaphore)
-
-	return results
-}
-
-func runTool(
-	ctx context.Context,
-	runner api.ActionRunner,
-	toolCall *api.ToolCall,
-) *api.Result {
-	var name = toolCall.Command
-	var args = toolCall.Arguments
-	var props = make(map[string]any)
-	if args != nil {
-		args.Copy(props)
-	}
-
-	log.GetLogger(ctx).Debugf("\n* tool call: %s props: %+v\n", name, props)
-
-	data, err := runner.Run(ctx, name, props)
-	if err != nil {
-		return &api.Result{
-			Value: err.Error(),
-		}
-	}
-	out := api.ToResult(data)
-	log.GetLogger(ctx).Debugf("\n* tool call: %s out: %s\n", name, out)
-	return out
-}
-
 func GetStrArg(key string, args map[string]any, val string) string {
 	if args != nil {
 		if arg, found := args[key]; found {
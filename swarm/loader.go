@@ -13,6 +13,7 @@ import (
 	"github.com/qiangli/ai/swarm/api"
 	"github.com/qiangli/ai/swarm/atm"
 	"github.com/qiangli/ai/swarm/atm/conf"
+	"github.com/qiangli/ai/swarm/llm/adapter"
 )
 
 type AgentCacheKey struct {
@@ -326,6 +327,11 @@ func (r *ConfigLoader) NewAgent(c *api.AgentConfig, pn api.Packname) (*api.Agent
 		args["log_level"] = logLevel
 	}
 
+	// structured output
+	if len(c.OutputSchema) > 0 {
+		args["output_schema"] = c.OutputSchema
+	}
+
 	//
 	maps.Copy(args, ac.Arguments)
 	maps.Copy(args, c.Arguments)
@@ -354,6 +360,12 @@ func (r *ConfigLoader) NewAgent(c *api.AgentConfig, pn api.Packname) (*api.Agent
 						Provider: nvl(v.Provider, ac.Provider),
 						BaseUrl:  nvl(v.BaseUrl, ac.BaseUrl),
 						ApiKey:   nvl(v.ApiKey, ac.ApiKey),
+						//
+						Weight:     v.Weight,
+						InputCost:  v.InputCost,
+						OutputCost: v.OutputCost,
+						//
+						ContextWindow: v.ContextWindow,
 					}
 					break
 				}
@@ -371,6 +383,11 @@ func (r *ConfigLoader) NewAgent(c *api.AgentConfig, pn api.Packname) (*api.Agent
 		// }
 	}
 
+	// external adapters declared in the app config
+	if err := r.registerAdapters(ac); err != nil {
+		return nil, err
+	}
+
 	// tools
 	// dedup
 	funcMap := make(map[string]*api.ToolFunc)
@@ -424,6 +441,39 @@ func (r *ConfigLoader) NewAgent(c *api.AgentConfig, pn api.Packname) (*api.Agent
 	return &agent, nil
 }
 
+// registerAdapters makes the external adapters declared in the config
+// available by name to the agents. Names must be unique across packs.
+func (r *ConfigLoader) registerAdapters(ac *api.AppConfig) error {
+	if len(ac.Adapters) == 0 || r.vars.Adapters == nil {
+		return nil
+	}
+	for _, c := range ac.Adapters {
+		var token func() string
+		if c.ApiKey != "" {
+			owner, key := r.vars.User.Email, c.ApiKey
+			token = func() string {
+				ak, _ := r.vars.Secrets.Get(owner, key)
+				return ak
+			}
+		}
+		v, err := adapter.NewExternalAdapter(c, token)
+		if err != nil {
+			return err
+		}
+		v.Pack = ac.Pack
+		// adapters are shared by all packs, reloading the pack replaces its own only
+		if old, err := r.vars.Adapters.Get(c.Name); err == nil {
+			if ea, ok := old.(*adapter.ExternalAdapter); !ok || ea.Pack != ac.Pack {
+				return fmt.Errorf("adapter %q of %s is already registered by another pack", c.Name, ac.Pack)
+			}
+		}
+		if err := r.vars.Adapters.Register(c.Name, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // create agent (class) from config
 func (r *ConfigLoader) Create(ctx context.Context, packname api.Packname) (*api.Agent, error) {
 	findConfig := func(ac *api.AppConfig, pack, sub string) (*api.AgentConfig, error) {
@@ -0,0 +1,47 @@
+package swarm
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/qiangli/ai/swarm/api"
+)
+
+type mapAdapters map[string]api.LLMAdapter
+
+func (r mapAdapters) Get(key string) (api.LLMAdapter, error) {
+	if v, ok := r[key]; ok {
+		return v, nil
+	}
+	return nil, fmt.Errorf("LLM adapter %q not found", key)
+}
+
+func (r mapAdapters) Register(key string, adapter api.LLMAdapter) error {
+	r[key] = adapter
+	return nil
+}
+
+func TestRegisterAdapters(t *testing.T) {
+	vars := &api.Vars{
+		User:     &api.User{},
+		Adapters: mapAdapters{},
+	}
+	loader := NewConfigLoader(vars)
+	config := func(pack string) *api.AppConfig {
+		return &api.AppConfig{
+			Pack:     pack,
+			Adapters: []*api.AdapterConfig{{Name: "local", Command: "llm-" + pack}},
+		}
+	}
+
+	if err := loader.registerAdapters(config("alpha")); err != nil {
+		t.Fatal(err)
+	}
+	// reloaded
+	if err := loader.registerAdapters(config("alpha")); err != nil {
+		t.Errorf("expected the pack to replace its own adapter: %v", err)
+	}
+	if err := loader.registerAdapters(config("beta")); err == nil {
+		t.Errorf("expected the adapter of another pack to be rejected")
+	}
+}
@@ -0,0 +1,134 @@
+package mcp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// DefaultPool keeps the sessions for the lifetime of the process.
+var DefaultPool = NewPool()
+
+// Pool keeps MCP sessions alive so that local servers are started
+// and remote servers are connected once instead of for every tool call.
+type Pool struct {
+	mu       sync.Mutex
+	sessions map[string]*mcp.ClientSession
+
+	// one connection attempt per server at a time
+	connecting map[string]*sync.Mutex
+
+	// resources and prompts listed by session
+	cache map[*mcp.ClientSession]*sessionCache
+
+	connect func(ctx context.Context, cfg *ConnectorConfig, token string) (*mcp.ClientSession, error)
+}
+
+func NewPool() *Pool {
+	return &Pool{
+		sessions:   make(map[string]*mcp.ClientSession),
+		connecting: make(map[string]*sync.Mutex),
+		cache:      make(map[*mcp.ClientSession]*sessionCache),
+		connect: func(ctx context.Context, cfg *ConnectorConfig, token string) (*mcp.ClientSession, error) {
+			return NewMcpClient(cfg).Connect(ctx, token)
+		},
+	}
+}
+
+// poolKey identifies the server and the credential of a session.
+func poolKey(cfg *ConnectorConfig, token string) string {
+	data, _ := json.Marshal(cfg)
+	h := sha256.New()
+	h.Write(data)
+	h.Write([]byte(token))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Session returns the open session of the server or connects to it.
+func (r *Pool) Session(ctx context.Context, cfg *ConnectorConfig, token string) (*mcp.ClientSession, error) {
+	key := poolKey(cfg, token)
+
+	r.mu.Lock()
+	lock, ok := r.connecting[key]
+	if !ok {
+		lock = &sync.Mutex{}
+		r.connecting[key] = lock
+	}
+	r.mu.Unlock()
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	r.mu.Lock()
+	session, ok := r.sessions[key]
+	r.mu.Unlock()
+	if ok {
+		return session, nil
+	}
+
+	// the session outlives the call that opened it
+	session, err := r.connect(context.WithoutCancel(ctx), cfg, token)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	r.sessions[key] = session
+	r.mu.Unlock()
+
+	// forget the session when the server exits or the connection is lost
+	go func() {
+		session.Wait()
+		r.remove(key, session)
+	}()
+	return session, nil
+}
+
+func (r *Pool) remove(key string, session *mcp.ClientSession) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.sessions[key] == session {
+		delete(r.sessions, key)
+	}
+	delete(r.cache, session)
+}
+
+// Do calls fn with the session of the server.
+// fn is retried once with a new session if the connection was closed.
+func (r *Pool) Do(ctx context.Context, cfg *ConnectorConfig, token string, fn func(*mcp.ClientSession) error) error {
+	session, err := r.Session(ctx, cfg, token)
+	if err != nil {
+		return err
+	}
+	err = fn(session)
+	if !errors.Is(err, mcp.ErrConnectionClosed) {
+		return err
+	}
+	r.remove(poolKey(cfg, token), session)
+	session.Close()
+	if session, err = r.Session(ctx, cfg, token); err != nil {
+		return err
+	}
+	return fn(session)
+}
+
+// Close closes all sessions and stops the local servers.
+func (r *Pool) Close() error {
+	r.mu.Lock()
+	sessions := r.sessions
+	r.sessions = make(map[string]*mcp.ClientSession)
+	r.cache = make(map[*mcp.ClientSession]*sessionCache)
+	r.mu.Unlock()
+
+	var errs []error
+	for _, v := range sessions {
+		if err := v.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
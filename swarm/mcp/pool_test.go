@@ -0,0 +1,145 @@
+package mcp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/qiangli/ai/swarm/api"
+)
+
+type greetInput struct {
+	Name string `json:"name"`
+}
+
+func newTestPool(t *testing.T) (*Pool, *int) {
+	server := mcp.NewServer(&mcp.Implementation{Name: "test", Version: "v0.0.1"}, nil)
+	mcp.AddTool(server, &mcp.Tool{Name: "greet"}, func(ctx context.Context, req *mcp.CallToolRequest, in greetInput) (*mcp.CallToolResult, any, error) {
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{&mcp.TextContent{Text: "hello " + in.Name}},
+		}, nil, nil
+	})
+
+	var count int
+	pool := NewPool()
+	pool.connect = func(ctx context.Context, cfg *ConnectorConfig, token string) (*mcp.ClientSession, error) {
+		count++
+		ct, st := mcp.NewInMemoryTransports()
+		if _, err := server.Connect(ctx, st, nil); err != nil {
+			return nil, err
+		}
+		client := mcp.NewClient(&mcp.Implementation{Name: "client", Version: "v0.0.1"}, nil)
+		return client.Connect(ctx, ct, nil)
+	}
+	t.Cleanup(func() { pool.Close() })
+	return pool, &count
+}
+
+func TestPoolSession(t *testing.T) {
+	ctx := context.Background()
+	pool, count := newTestPool(t)
+	cfg := &ConnectorConfig{Command: "test"}
+
+	call := func() string {
+		var out any
+		err := pool.Do(ctx, cfg, "", func(session *mcp.ClientSession) error {
+			result, err := session.CallTool(ctx, &mcp.CallToolParams{Name: "greet", Arguments: map[string]any{"name": "ai"}})
+			if err != nil {
+				return err
+			}
+			out, err = ToolResult(result)
+			return err
+		})
+		if err != nil {
+			t.Fatalf("call: %v", err)
+		}
+		return api.ToString(out)
+	}
+
+	for range 3 {
+		if got := call(); got != "hello ai" {
+			t.Errorf("got %q", got)
+		}
+	}
+	if *count != 1 {
+		t.Errorf("connected %v times, want 1", *count)
+	}
+
+	// reconnect after the session is closed
+	session, err := pool.Session(ctx, cfg, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if v, _ := pool.Session(ctx, cfg, ""); v != session {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("closed session not removed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := call(); got != "hello ai" {
+		t.Errorf("got %q after reconnect", got)
+	}
+	if *count != 2 {
+		t.Errorf("connected %v times, want 2", *count)
+	}
+
+	// different credential
+	if _, err := pool.Session(ctx, cfg, "token"); err != nil {
+		t.Fatal(err)
+	}
+	if *count != 3 {
+		t.Errorf("connected %v times, want 3", *count)
+	}
+}
+
+func TestToolResult(t *testing.T) {
+	png := []byte{0x89, 'P', 'N', 'G'}
+
+	out, err := ToolResult(&mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.ImageContent{MIMEType: "image/png", Data: png}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blob, ok := out.(*api.Blob); !ok || blob.MimeType != "image/png" || string(blob.Content) != string(png) {
+		t.Errorf("image: got %#v", out)
+	}
+
+	out, err = ToolResult(&mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{Text: "chart"},
+			&mcp.ImageContent{MIMEType: "image/png", Data: png},
+			&mcp.EmbeddedResource{Resource: &mcp.ResourceContents{URI: "file:///a.txt", Text: "a"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "chart\n" + api.DataURL("image/png", png) + "\nresource file:///a.txt\na"
+	if out != want {
+		t.Errorf("mixed: got %q, want %q", out, want)
+	}
+
+	out, err = ToolResult(&mcp.CallToolResult{StructuredContent: map[string]any{"n": 1}})
+	if err != nil || out != `{"n":1}` {
+		t.Errorf("structured: got %v %v", out, err)
+	}
+
+	if _, err := ToolResult(&mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{Text: "bad input"}},
+		IsError: true,
+	}); err == nil || err.Error() != "bad input" {
+		t.Errorf("error: got %v", err)
+	}
+
+	if _, err := ToolResult(&mcp.CallToolResult{}); err == nil {
+		t.Errorf("expected no response error")
+	}
+}
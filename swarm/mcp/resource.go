@@ -0,0 +1,150 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/qiangli/ai/swarm/api"
+)
+
+// sessionCache holds what the server published for the lifetime of the session.
+type sessionCache struct {
+	resources []*mcp.Resource
+	prompts   []*mcp.Prompt
+}
+
+func (r *Pool) sessionCache(session *mcp.ClientSession) *sessionCache {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.cache[session]
+	if !ok {
+		c = &sessionCache{}
+		r.cache[session] = c
+	}
+	return c
+}
+
+// Resources lists the resources of the server, empty if the server has none.
+func (r *Pool) Resources(ctx context.Context, cfg *ConnectorConfig, token string) ([]*mcp.Resource, error) {
+	var list []*mcp.Resource
+	err := r.Do(ctx, cfg, token, func(session *mcp.ClientSession) error {
+		c := r.sessionCache(session)
+		r.mu.Lock()
+		list = c.resources
+		r.mu.Unlock()
+		if list != nil {
+			return nil
+		}
+		if caps := session.InitializeResult().Capabilities; caps == nil || caps.Resources == nil {
+			list = []*mcp.Resource{}
+			return nil
+		}
+		list = []*mcp.Resource{}
+		for v, err := range session.Resources(ctx, nil) {
+			if err != nil {
+				return err
+			}
+			list = append(list, v)
+		}
+		r.mu.Lock()
+		c.resources = list
+		r.mu.Unlock()
+		return nil
+	})
+	return list, err
+}
+
+// ReadResource reads the resource at the uri.
+// The contents are not cached as they may change during the session.
+func (r *Pool) ReadResource(ctx context.Context, cfg *ConnectorConfig, token, uri string) (*mcp.ReadResourceResult, error) {
+	var result *mcp.ReadResourceResult
+	err := r.Do(ctx, cfg, token, func(session *mcp.ClientSession) error {
+		v, err := session.ReadResource(ctx, &mcp.ReadResourceParams{URI: uri})
+		result = v
+		return err
+	})
+	return result, err
+}
+
+// Prompts lists the prompt templates of the server, empty if the server has none.
+func (r *Pool) Prompts(ctx context.Context, cfg *ConnectorConfig, token string) ([]*mcp.Prompt, error) {
+	var list []*mcp.Prompt
+	err := r.Do(ctx, cfg, token, func(session *mcp.ClientSession) error {
+		c := r.sessionCache(session)
+		r.mu.Lock()
+		list = c.prompts
+		r.mu.Unlock()
+		if list != nil {
+			return nil
+		}
+		if caps := session.InitializeResult().Capabilities; caps == nil || caps.Prompts == nil {
+			list = []*mcp.Prompt{}
+			return nil
+		}
+		list = []*mcp.Prompt{}
+		for v, err := range session.Prompts(ctx, nil) {
+			if err != nil {
+				return err
+			}
+			list = append(list, v)
+		}
+		r.mu.Lock()
+		c.prompts = list
+		r.mu.Unlock()
+		return nil
+	})
+	return list, err
+}
+
+// GetPrompt renders the prompt template with the arguments.
+func (r *Pool) GetPrompt(ctx context.Context, cfg *ConnectorConfig, token, name string, args map[string]string) (*mcp.GetPromptResult, error) {
+	var result *mcp.GetPromptResult
+	err := r.Do(ctx, cfg, token, func(session *mcp.ClientSession) error {
+		v, err := session.GetPrompt(ctx, &mcp.GetPromptParams{Name: name, Arguments: args})
+		result = v
+		return err
+	})
+	return result, err
+}
+
+// ResourceText returns the text of the contents, binary contents are encoded as data urls.
+func ResourceText(result *mcp.ReadResourceResult) string {
+	var parts []string
+	for _, v := range result.Contents {
+		if v == nil {
+			continue
+		}
+		if v.Blob != nil {
+			parts = append(parts, api.DataURL(v.MIMEType, v.Blob))
+		} else {
+			parts = append(parts, v.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// PromptText returns the messages of the prompt, one per role.
+func PromptText(result *mcp.GetPromptResult) string {
+	var sb strings.Builder
+	for _, m := range result.Messages {
+		if m == nil {
+			continue
+		}
+		var text string
+		switch c := m.Content.(type) {
+		case *mcp.TextContent:
+			text = c.Text
+		case *mcp.EmbeddedResource:
+			if c.Resource != nil {
+				text = c.Resource.Text
+			}
+		case *mcp.ImageContent:
+			text = api.DataURL(c.MIMEType, c.Data)
+		}
+		fmt.Fprintf(&sb, "%s: %s\n", m.Role, text)
+	}
+	return sb.String()
+}
@@ -0,0 +1,100 @@
+package mcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestPoolResources(t *testing.T) {
+	ctx := context.Background()
+	pool, _ := newTestPool(t)
+	cfg := &ConnectorConfig{Command: "test"}
+
+	var reads int
+	server := mcp.NewServer(&mcp.Implementation{Name: "docs", Version: "v0.0.1"}, nil)
+	server.AddResource(&mcp.Resource{URI: "file:///README.md", Name: "readme", MIMEType: "text/markdown"},
+		func(ctx context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+			reads++
+			return &mcp.ReadResourceResult{
+				Contents: []*mcp.ResourceContents{{URI: req.Params.URI, MIMEType: "text/markdown", Text: "# readme"}},
+			}, nil
+		})
+	server.AddPrompt(&mcp.Prompt{Name: "review", Arguments: []*mcp.PromptArgument{{Name: "lang", Required: true}}},
+		func(ctx context.Context, req *mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+			return &mcp.GetPromptResult{
+				Messages: []*mcp.PromptMessage{
+					{Role: "user", Content: &mcp.TextContent{Text: "review the " + req.Params.Arguments["lang"] + " code"}},
+				},
+			}, nil
+		})
+	pool.connect = func(ctx context.Context, cfg *ConnectorConfig, token string) (*mcp.ClientSession, error) {
+		ct, st := mcp.NewInMemoryTransports()
+		if _, err := server.Connect(ctx, st, nil); err != nil {
+			return nil, err
+		}
+		client := mcp.NewClient(&mcp.Implementation{Name: "client", Version: "v0.0.1"}, nil)
+		return client.Connect(ctx, ct, nil)
+	}
+
+	resources, err := pool.Resources(ctx, cfg, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resources) != 1 || resources[0].URI != "file:///README.md" {
+		t.Fatalf("resources: %+v", resources)
+	}
+
+	for range 2 {
+		result, err := pool.ReadResource(ctx, cfg, "", "file:///README.md")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ResourceText(result); got != "# readme" {
+			t.Errorf("got %q", got)
+		}
+	}
+	// contents may change, read every time
+	if reads != 2 {
+		t.Errorf("read %v times, want 2", reads)
+	}
+
+	prompts, err := pool.Prompts(ctx, cfg, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prompts) != 1 || prompts[0].Name != "review" {
+		t.Fatalf("prompts: %+v", prompts)
+	}
+	result, err := pool.GetPrompt(ctx, cfg, "", "review", map[string]string{"lang": "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := PromptText(result); !strings.Contains(got, "user: review the go code") {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestPoolNoResources(t *testing.T) {
+	ctx := context.Background()
+	pool, _ := newTestPool(t)
+	cfg := &ConnectorConfig{Command: "test"}
+
+	// the test server only has tools
+	resources, err := pool.Resources(ctx, cfg, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resources) != 0 {
+		t.Errorf("resources: %+v", resources)
+	}
+	prompts, err := pool.Prompts(ctx, cfg, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prompts) != 0 {
+		t.Errorf("prompts: %+v", prompts)
+	}
+}
@@ -0,0 +1,76 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/qiangli/ai/swarm/api"
+)
+
+// ToolResult converts the content of the tool result.
+// A single image, audio or binary resource is returned as a blob;
+// otherwise text is returned with media content encoded as data urls.
+func ToolResult(result *mcp.CallToolResult) (any, error) {
+	var parts []string
+	var blobs []*api.Blob
+	for _, content := range result.Content {
+		switch v := content.(type) {
+		case *mcp.TextContent:
+			parts = append(parts, v.Text)
+		case *mcp.ImageContent:
+			blob := &api.Blob{MimeType: v.MIMEType, Content: v.Data}
+			blobs = append(blobs, blob)
+			parts = append(parts, api.DataURL(v.MIMEType, v.Data))
+		case *mcp.AudioContent:
+			blob := &api.Blob{MimeType: v.MIMEType, Content: v.Data}
+			blobs = append(blobs, blob)
+			parts = append(parts, api.DataURL(v.MIMEType, v.Data))
+		case *mcp.EmbeddedResource:
+			res := v.Resource
+			if res == nil {
+				continue
+			}
+			if res.Blob != nil {
+				blob := &api.Blob{ID: res.URI, MimeType: res.MIMEType, Content: res.Blob}
+				blobs = append(blobs, blob)
+				parts = append(parts, fmt.Sprintf("resource %s\n%s", res.URI, api.DataURL(res.MIMEType, res.Blob)))
+			} else {
+				parts = append(parts, fmt.Sprintf("resource %s\n%s", res.URI, res.Text))
+			}
+		case *mcp.ResourceLink:
+			parts = append(parts, fmt.Sprintf("resource link %s %s %s", v.URI, v.Name, v.Description))
+		}
+	}
+
+	if len(parts) == 0 && result.StructuredContent != nil {
+		data, err := json.Marshal(result.StructuredContent)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, string(data))
+	}
+
+	text := strings.Join(parts, "\n")
+	if result.IsError {
+		return nil, fmt.Errorf("%s", nvl(text, "tool error"))
+	}
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("No response")
+	}
+	if len(parts) == 1 && len(blobs) == 1 {
+		return blobs[0], nil
+	}
+	return text, nil
+}
+
+func nvl(a ...string) string {
+	for _, v := range a {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,197 @@
+package swarm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/qiangli/ai/swarm/api"
+	"github.com/qiangli/ai/swarm/atm/conf"
+	"github.com/qiangli/ai/swarm/log"
+)
+
+// McpServerOptions selects what is published by the MCP server.
+type McpServerOptions struct {
+	// agent packs: pack, pack/sub or pack/*
+	Agents []string
+	// tool kits, all tools of the kit are published
+	Kits []string
+
+	Version string
+}
+
+// McpServer publishes agents and tool kits as MCP tools,
+// the instructions of the agents as prompts and the skills of the workspace as resources.
+func (sw *Swarm) McpServer(ctx context.Context, opts *McpServerOptions) (*mcp.Server, error) {
+	if len(opts.Agents) == 0 && len(opts.Kits) == 0 {
+		return nil, fmt.Errorf("nothing to serve. specify agents and/or tool kits")
+	}
+	vars := sw.vars
+	owner := vars.User.Email
+
+	server := mcp.NewServer(&mcp.Implementation{
+		Name:    "ai",
+		Version: opts.Version,
+	}, nil)
+
+	for _, name := range opts.Agents {
+		tools, err := conf.LoadToolFunc(owner, "agent:"+name, vars.Secrets, vars.Assets)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load agent %s: %w", name, err)
+		}
+		for _, tf := range tools {
+			sw.addMcpTool(server, tf)
+			if err := sw.addMcpPrompt(server, tf); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, kit := range opts.Kits {
+		tools, err := conf.LoadToolFunc(owner, kit+":*", vars.Secrets, vars.Assets)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load tool kit %s: %w", kit, err)
+		}
+		for _, tf := range tools {
+			sw.addMcpTool(server, tf)
+		}
+	}
+
+	// skills are optional
+	skills, err := findSkills(vars.Roots.Workspace.Path)
+	if err != nil {
+		log.GetLogger(ctx).Debugf("no skills: %v\n", err)
+	}
+	for _, v := range skills {
+		addMcpSkill(server, v)
+	}
+	return server, nil
+}
+
+func (sw *Swarm) addMcpTool(server *mcp.Server, tf *api.ToolFunc) {
+	params := map[string]any{"type": "object"}
+	for k, v := range tf.Parameters {
+		params[k] = v
+	}
+	tool := &mcp.Tool{
+		Name:        tf.ID(),
+		Title:       tf.Display,
+		Description: tf.Description,
+		InputSchema: params,
+	}
+	server.AddTool(tool, func(ctx context.Context, req *mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var args = make(map[string]any)
+		if len(req.Params.Arguments) > 0 {
+			if err := json.Unmarshal(req.Params.Arguments, &args); err != nil {
+				return nil, err
+			}
+		}
+		result, err := sw.callMcpTool(ctx, tf, args)
+		if err != nil {
+			return &mcp.CallToolResult{
+				Content: []mcp.Content{&mcp.TextContent{Text: err.Error()}},
+				IsError: true,
+			}, nil
+		}
+		return toMcpResult(result), nil
+	})
+}
+
+// callMcpTool spawns the agent or runs the tool on behalf of the root agent.
+func (sw *Swarm) callMcpTool(ctx context.Context, tf *api.ToolFunc, args map[string]any) (*api.Result, error) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	vars := sw.vars
+	if tf.Type == api.ToolTypeAgent {
+		args["agent"] = tf.Name
+		return NewAIKit(vars).SpawnAgent(ctx, vars, vars.RootAgent, nil, args)
+	}
+	out, err := vars.RootAgent.Runner.Run(ctx, api.NewKitname(tf.Kit, tf.Name).String(), args)
+	if err != nil {
+		return nil, err
+	}
+	return api.ToResult(out), nil
+}
+
+// toMcpResult returns images as image content, anything else as text.
+func toMcpResult(result *api.Result) *mcp.CallToolResult {
+	if result == nil {
+		return &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{}}}
+	}
+	if strings.HasPrefix(result.MimeType, "image/") && strings.HasPrefix(result.Value, "data:") {
+		if data, err := api.DecodeDataURL(result.Value); err == nil {
+			return &mcp.CallToolResult{
+				Content: []mcp.Content{&mcp.ImageContent{MIMEType: result.MimeType, Data: []byte(data)}},
+			}
+		}
+	}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{Text: result.Value}},
+	}
+}
+
+// addMcpPrompt publishes the instruction of the agent as a prompt of the same name as the tool.
+func (sw *Swarm) addMcpPrompt(server *mcp.Server, tf *api.ToolFunc) error {
+	ac, err := NewConfigLoader(sw.vars).LoadAgentConfig(api.Packname(tf.Name))
+	if err != nil {
+		return err
+	}
+	_, sub := api.Packname(tf.Name).Decode()
+	var agent *api.AgentConfig
+	for _, v := range ac.Agents {
+		if v.Name == sub {
+			agent = v
+		}
+	}
+	if agent == nil || agent.Instruction == "" {
+		return nil
+	}
+	instruction := agent.Instruction
+
+	prompt := &mcp.Prompt{
+		Name:        tf.ID(),
+		Title:       tf.Display,
+		Description: tf.Description,
+		Arguments: []*mcp.PromptArgument{
+			{Name: "message", Description: "The user input appended to the instruction"},
+		},
+	}
+	server.AddPrompt(prompt, func(ctx context.Context, req *mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		text := api.Cat(instruction, req.Params.Arguments["message"], "\n\n")
+		return &mcp.GetPromptResult{
+			Description: agent.Description,
+			Messages: []*mcp.PromptMessage{
+				{Role: "user", Content: &mcp.TextContent{Text: text}},
+			},
+		}, nil
+	})
+	return nil
+}
+
+// addMcpSkill publishes the SKILL.md of the skill as a resource.
+func addMcpSkill(server *mcp.Server, skill SkillEntry) {
+	file := filepath.Join(skill.Path, "SKILL.md")
+	resource := &mcp.Resource{
+		URI:         "skill://" + url.PathEscape(skill.Name),
+		Name:        skill.Name,
+		Description: skill.Description,
+		MIMEType:    "text/markdown",
+	}
+	server.AddResource(resource, func(ctx context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, mcp.ResourceNotFoundError(req.Params.URI)
+		}
+		return &mcp.ReadResourceResult{
+			Contents: []*mcp.ResourceContents{
+				{URI: req.Params.URI, MIMEType: "text/markdown", Text: string(data)},
+			},
+		}, nil
+	})
+}
@@ -0,0 +1,157 @@
+package swarm
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/qiangli/ai/swarm/api"
+)
+
+type echoRunner struct {
+	tid string
+}
+
+func (r *echoRunner) Run(ctx context.Context, tid string, args map[string]any) (any, error) {
+	r.tid = tid
+	if args["fail"] == true {
+		return nil, fmt.Errorf("failed")
+	}
+	return fmt.Sprintf("%v", args["text"]), nil
+}
+
+func TestMcpServer(t *testing.T) {
+	ctx := context.Background()
+	runner := &echoRunner{}
+	sw := &Swarm{
+		vars: &api.Vars{
+			RootAgent: &api.Agent{Runner: runner},
+		},
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "SKILL.md"), []byte("# review"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	server := mcp.NewServer(&mcp.Implementation{Name: "ai", Version: "test"}, nil)
+	sw.addMcpTool(server, &api.ToolFunc{
+		Type:        api.ToolTypeSystem,
+		Kit:         "text",
+		Name:        "echo",
+		Description: "Echo the text",
+		Parameters: map[string]any{
+			"properties": map[string]any{"text": map[string]any{"type": "string"}},
+		},
+	})
+	addMcpSkill(server, SkillEntry{Name: "review", Description: "Code review", Path: dir})
+
+	ct, st := mcp.NewInMemoryTransports()
+	if _, err := server.Connect(ctx, st, nil); err != nil {
+		t.Fatal(err)
+	}
+	client := mcp.NewClient(&mcp.Implementation{Name: "client", Version: "test"}, nil)
+	session, err := client.Connect(ctx, ct, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	tools, err := session.ListTools(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tools.Tools) != 1 || tools.Tools[0].Name != "text__echo" {
+		t.Fatalf("tools: %+v", tools.Tools)
+	}
+
+	result, err := session.CallTool(ctx, &mcp.CallToolParams{Name: "text__echo", Arguments: map[string]any{"text": "hi"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text, ok := result.Content[0].(*mcp.TextContent); !ok || text.Text != "hi" || result.IsError {
+		t.Errorf("result: %+v", result.Content[0])
+	}
+	if runner.tid != "text:echo" {
+		t.Errorf("runner called with %q", runner.tid)
+	}
+
+	result, err = session.CallTool(ctx, &mcp.CallToolParams{Name: "text__echo", Arguments: map[string]any{"fail": true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.IsError {
+		t.Errorf("expected tool error")
+	}
+
+	res, err := session.ReadResource(ctx, &mcp.ReadResourceParams{URI: "skill://review"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Contents[0].Text != "# review" {
+		t.Errorf("skill: %+v", res.Contents[0])
+	}
+}
+
+func TestToMcpResult(t *testing.T) {
+	png := []byte{0x89, 'P', 'N', 'G'}
+	result := toMcpResult(&api.Result{MimeType: "image/png", Value: api.DataURL("image/png", png)})
+	if v, ok := result.Content[0].(*mcp.ImageContent); !ok || string(v.Data) != string(png) {
+		t.Errorf("image: %+v", result.Content[0])
+	}
+	result = toMcpResult(&api.Result{Value: "text"})
+	if v, ok := result.Content[0].(*mcp.TextContent); !ok || v.Text != "text" {
+		t.Errorf("text: %+v", result.Content[0])
+	}
+}
+
+// slowRunner records the peak number of concurrent calls.
+type slowRunner struct {
+	running atomic.Int32
+	peak    atomic.Int32
+}
+
+func (r *slowRunner) Run(ctx context.Context, tid string, args map[string]any) (any, error) {
+	n := r.running.Add(1)
+	defer r.running.Add(-1)
+	for {
+		p := r.peak.Load()
+		if n <= p || r.peak.CompareAndSwap(p, n) {
+			break
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+	return "ok", nil
+}
+
+func TestMcpServerConcurrent(t *testing.T) {
+	runner := &slowRunner{}
+	sw := &Swarm{
+		vars: &api.Vars{
+			RootAgent: &api.Agent{Runner: runner},
+		},
+	}
+	tf := &api.ToolFunc{Type: api.ToolTypeSystem, Kit: "text", Name: "echo"}
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := sw.callMcpTool(context.TODO(), tf, map[string]any{}); err != nil {
+				t.Errorf("call: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if p := runner.peak.Load(); p != 1 {
+		t.Errorf("expected calls one at a time, got %v", p)
+	}
+}
@@ -0,0 +1,455 @@
+package swarm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/qiangli/ai/swarm/api"
+	"github.com/qiangli/ai/swarm/log"
+)
+
+// OpenAIServerOptions selects the agents served as models.
+type OpenAIServerOptions struct {
+	// agent packs: pack, pack/sub or pack/*, all agents if empty
+	Agents []string
+
+	// bearer token required from the clients if not empty
+	Token string
+}
+
+// OpenAI Chat Completions API
+// https://platform.openai.com/docs/api-reference/chat
+
+type chatMessage struct {
+	Role string `json:"role"`
+	// string or array of content parts
+	Content any `json:"content"`
+}
+
+type chatRequest struct {
+	Model    string         `json:"model"`
+	Messages []*chatMessage `json:"messages"`
+	Stream   bool           `json:"stream"`
+}
+
+type chatDelta struct {
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+type chatChoice struct {
+	Index        int        `json:"index"`
+	Message      *chatDelta `json:"message,omitempty"`
+	Delta        *chatDelta `json:"delta,omitempty"`
+	FinishReason *string    `json:"finish_reason"`
+}
+
+type chatUsage struct {
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+	TotalTokens      int64 `json:"total_tokens"`
+}
+
+type chatResponse struct {
+	ID      string        `json:"id"`
+	Object  string        `json:"object"`
+	Created int64         `json:"created"`
+	Model   string        `json:"model"`
+	Choices []*chatChoice `json:"choices"`
+	Usage   *chatUsage    `json:"usage,omitempty"`
+}
+
+type modelEntry struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+// OpenAIHandler serves the agents over the OpenAI Chat Completions API:
+// GET /v1/models lists the agents, POST /v1/chat/completions runs the agent named by the model.
+func (sw *Swarm) OpenAIHandler(opts *OpenAIServerOptions) http.Handler {
+	s := &openaiServer{sw: sw, opts: opts}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/models", s.auth(s.models))
+	mux.HandleFunc("POST /v1/chat/completions", s.auth(s.completions))
+	return mux
+}
+
+type openaiServer struct {
+	sw   *Swarm
+	opts *OpenAIServerOptions
+
+	// the swarm state is shared, requests are run one at a time
+	mu sync.Mutex
+}
+
+func (r *openaiServer) auth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if r.opts.Token != "" && req.Header.Get("Authorization") != "Bearer "+r.opts.Token {
+			writeOpenAIError(w, http.StatusUnauthorized, "invalid_api_key", "Invalid API key")
+			return
+		}
+		next(w, req)
+	}
+}
+
+// agents returns the pack/sub names of the agents served, sorted.
+func (r *openaiServer) agents() ([]string, error) {
+	vars := r.sw.vars
+	packs, err := vars.Assets.ListAgent(vars.User.Email)
+	if err != nil {
+		return nil, err
+	}
+	var list []string
+	for pack, ac := range packs {
+		for _, v := range ac.Agents {
+			name := pack + "/" + v.Name
+			if r.allowed(name) {
+				list = append(list, name)
+			}
+		}
+	}
+	sort.Strings(list)
+	return list, nil
+}
+
+// allowed reports whether the agent pack/sub is selected by the options.
+func (r *openaiServer) allowed(name string) bool {
+	if len(r.opts.Agents) == 0 {
+		return true
+	}
+	pack, sub := api.Packname(name).Decode()
+	for _, v := range r.opts.Agents {
+		p, s := api.Packname(v).Decode()
+		if p != pack {
+			continue
+		}
+		if ok, _ := path.Match(s, sub); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *openaiServer) models(w http.ResponseWriter, req *http.Request) {
+	list, err := r.agents()
+	if err != nil {
+		writeOpenAIError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+	var data = []*modelEntry{}
+	for _, v := range list {
+		data = append(data, &modelEntry{
+			ID:      modelName(v),
+			Object:  "model",
+			OwnedBy: "ai",
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"object": "list",
+		"data":   data,
+	})
+}
+
+// modelName returns the short form pack for the pack/pack agent.
+func modelName(name string) string {
+	pack, sub := api.Packname(name).Decode()
+	if pack == sub {
+		return pack
+	}
+	return pack + "/" + sub
+}
+
+func (r *openaiServer) completions(w http.ResponseWriter, req *http.Request) {
+	var in chatRequest
+	if err := json.NewDecoder(req.Body).Decode(&in); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+	if in.Model == "" {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "model is required: the name of the agent")
+		return
+	}
+	pack, sub := api.Packname(in.Model).Decode()
+	if !r.allowed(pack + "/" + sub) {
+		writeOpenAIError(w, http.StatusNotFound, "model_not_found", fmt.Sprintf("The model %q does not exist", in.Model))
+		return
+	}
+	message, history, err := chatHistory(in.Messages, r.sw.vars.SessionID)
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	cs := &chatStream{
+		w:       w,
+		id:      "chatcmpl-" + uuid.NewString(),
+		model:   in.Model,
+		created: time.Now().Unix(),
+		stream:  in.Stream,
+	}
+	if in.Stream {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		cs.send(&chatDelta{Role: api.RoleAssistant}, nil)
+	}
+
+	ctx := api.WithStreamHandler(req.Context(), cs.Handle)
+
+	r.mu.Lock()
+	result, err := r.run(ctx, pack, sub, message, history)
+	r.mu.Unlock()
+
+	if err != nil {
+		log.GetLogger(ctx).Errorf("✗ %s: %v\n", in.Model, err)
+		if !in.Stream {
+			writeOpenAIError(w, http.StatusInternalServerError, "server_error", err.Error())
+			return
+		}
+		cs.send(&chatDelta{Content: fmt.Sprintf("\n\n❌ %v", err)}, nil)
+		result = &api.Result{}
+	}
+
+	usage := &chatUsage{
+		PromptTokens:     result.InputTokens,
+		CompletionTokens: result.OutputTokens,
+		TotalTokens:      result.TotalTokens,
+	}
+	stop := "stop"
+	if in.Stream {
+		if !cs.Streamed(result.Value) {
+			cs.send(&chatDelta{Content: result.Value}, nil)
+		}
+		cs.send(&chatDelta{}, &stop)
+		cs.done()
+		return
+	}
+	writeJSON(w, http.StatusOK, &chatResponse{
+		ID:      cs.id,
+		Object:  "chat.completion",
+		Created: cs.created,
+		Model:   cs.model,
+		Choices: []*chatChoice{
+			{
+				Message:      &chatDelta{Role: api.RoleAssistant, Content: cs.Tools() + result.Value},
+				FinishReason: &stop,
+			},
+		},
+		Usage: usage,
+	})
+}
+
+// run executes the agent with the conversation.
+func (r *openaiServer) run(ctx context.Context, pack, sub, message string, history []*api.Message) (*api.Result, error) {
+	argm, err := r.sw.Parse(ctx, map[string]any{
+		"kit":     "agent",
+		"pack":    pack,
+		"name":    sub,
+		"message": message,
+	})
+	if err != nil {
+		return nil, err
+	}
+	// kept apart from the history so that the context of the agent is still added
+	if len(history) > 0 {
+		argm.SetConversation(history)
+	}
+	result, err := r.sw.Exec(ctx, argm)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		result = &api.Result{}
+	}
+	return result, nil
+}
+
+// chatHistory returns the last user message as the input and the messages before it as the history.
+// The system messages of the client are kept as context, the instruction of the agent applies.
+func chatHistory(messages []*chatMessage, session api.SessionID) (string, []*api.Message, error) {
+	last := -1
+	for i, v := range messages {
+		if v.Role == api.RoleUser {
+			last = i
+		}
+	}
+	if last < 0 {
+		return "", nil, fmt.Errorf("messages must contain a user message")
+	}
+	var history []*api.Message
+	for _, v := range messages[:last] {
+		content, err := chatContent(v.Content)
+		if err != nil {
+			return "", nil, err
+		}
+		role := v.Role
+		switch role {
+		case api.RoleUser, api.RoleAssistant:
+		default:
+			// system, developer and tool messages
+			role = api.RoleUser
+		}
+		history = append(history, &api.Message{
+			ID:      uuid.NewString(),
+			Session: session,
+			Created: time.Now(),
+			Role:    role,
+			Content: content,
+		})
+	}
+	message, err := chatContent(messages[last].Content)
+	if err != nil {
+		return "", nil, err
+	}
+	// instructions after the last user message
+	for _, v := range messages[last+1:] {
+		content, err := chatContent(v.Content)
+		if err != nil {
+			return "", nil, err
+		}
+		message = api.Cat(message, content, "\n\n")
+	}
+	return message, history, nil
+}
+
+// chatContent returns the text of the string or the text parts of the content.
+func chatContent(content any) (string, error) {
+	switch v := content.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case []any:
+		var parts []string
+		for _, p := range v {
+			m, ok := p.(map[string]any)
+			if !ok {
+				return "", fmt.Errorf("invalid content part: %v", p)
+			}
+			switch m["type"] {
+			case "text":
+				parts = append(parts, api.ToString(m["text"]))
+			case "image_url":
+				if u, ok := m["image_url"].(map[string]any); ok {
+					parts = append(parts, api.ToString(u["url"]))
+				}
+			default:
+				return "", fmt.Errorf("unsupported content part: %v", m["type"])
+			}
+		}
+		return strings.Join(parts, "\n"), nil
+	}
+	return "", fmt.Errorf("invalid content: %v", content)
+}
+
+// chatStream sends the LLM output and the tool results as chat completion chunks.
+// tool results are folded into the assistant content.
+type chatStream struct {
+	w       http.ResponseWriter
+	id      string
+	model   string
+	created int64
+	stream  bool
+
+	mu    sync.Mutex
+	buf   strings.Builder
+	tools strings.Builder
+}
+
+func (r *chatStream) Handle(ev *api.StreamEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch ev.Type {
+	case api.StreamEventText:
+		r.buf.WriteString(ev.Text)
+		if r.stream {
+			r.write(&chatDelta{Content: ev.Text}, nil)
+		}
+	case api.StreamEventToolResult:
+		text := fmt.Sprintf("\n\n> ✔ %s\n```\n%s\n```\n\n", ev.Name, Head(ev.Text, 2000))
+		r.tools.WriteString(text)
+		if r.stream {
+			r.write(&chatDelta{Content: text}, nil)
+		}
+	}
+}
+
+// Streamed returns true if the content has already been sent as the tail of the streamed text.
+func (r *chatStream) Streamed(content string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return true
+	}
+	return strings.HasSuffix(strings.TrimSpace(r.buf.String()), content)
+}
+
+// Tools returns the folded tool results.
+func (r *chatStream) Tools() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.tools.String()
+}
+
+func (r *chatStream) send(delta *chatDelta, finish *string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.write(delta, finish)
+}
+
+func (r *chatStream) write(delta *chatDelta, finish *string) {
+	chunk := &chatResponse{
+		ID:      r.id,
+		Object:  "chat.completion.chunk",
+		Created: r.created,
+		Model:   r.model,
+		Choices: []*chatChoice{
+			{Delta: delta, FinishReason: finish},
+		},
+	}
+	data, _ := json.Marshal(chunk)
+	fmt.Fprintf(r.w, "data: %s\n\n", data)
+	if f, ok := r.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (r *chatStream) done() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fmt.Fprintf(r.w, "data: [DONE]\n\n")
+	if f, ok := r.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeOpenAIError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, map[string]any{
+		"error": map[string]any{
+			"message": message,
+			"type":    code,
+			"code":    code,
+		},
+	})
+}
@@ -0,0 +1,230 @@
+package swarm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/qiangli/ai/swarm/api"
+	"github.com/qiangli/ai/swarm/policy"
+)
+
+// chatRunner streams a tool result and replies with the message and the size of the history.
+type chatRunner struct {
+	tid  string
+	args api.ArgMap
+}
+
+func (r *chatRunner) Run(ctx context.Context, tid string, args map[string]any) (any, error) {
+	r.tid = tid
+	r.args = args
+	reply := fmt.Sprintf("%v (%v)", args["message"], len(api.ArgMap(args).Conversation()))
+	if h := api.GetStreamHandler(ctx); h != nil {
+		api.StreamToolResult(ctx, "call_1", "fs__list", &api.Result{Value: "a.txt"})
+		h(&api.StreamEvent{Type: api.StreamEventText, Text: reply})
+	}
+	return reply, nil
+}
+
+func newChatServer(t *testing.T, runner api.ActionRunner) *httptest.Server {
+	sw := &Swarm{
+		vars: &api.Vars{
+			SessionID: "test",
+			User:      &api.User{Settings: map[string]any{}},
+			Global:    api.NewEnvironment(),
+			RootAgent: &api.Agent{Runner: runner},
+		},
+	}
+	server := httptest.NewServer(sw.OpenAIHandler(&OpenAIServerOptions{Agents: []string{"swe/*"}, Token: "secret"}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func postChat(t *testing.T, url string, body string) *http.Response {
+	req, _ := http.NewRequest("POST", url+"/v1/chat/completions", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestChatCompletions(t *testing.T) {
+	runner := &chatRunner{}
+	server := newChatServer(t, runner)
+
+	body := `{"model": "swe/coder", "messages": [
+		{"role": "system", "content": "be brief"},
+		{"role": "user", "content": "hi"},
+		{"role": "assistant", "content": "hello"},
+		{"role": "user", "content": [{"type": "text", "text": "list files"}]}
+	]}`
+	resp := postChat(t, server.URL, body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status: %v", resp.StatusCode)
+	}
+	var out chatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if runner.tid != "agent__swe__coder" {
+		t.Errorf("runner called with %q", runner.tid)
+	}
+	content := out.Choices[0].Message.Content
+	if !strings.HasSuffix(content, "list files (3)") {
+		t.Errorf("content: %q", content)
+	}
+	if !strings.Contains(content, "fs__list") || !strings.Contains(content, "a.txt") {
+		t.Errorf("tool result not folded in: %q", content)
+	}
+
+	// not served
+	resp = postChat(t, server.URL, `{"model": "ask", "messages": [{"role": "user", "content": "hi"}]}`)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status: %v", resp.StatusCode)
+	}
+
+	// unauthorized
+	r, err := http.Get(server.URL + "/v1/models")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Body.Close()
+	if r.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status: %v", r.StatusCode)
+	}
+}
+
+func TestChatCompletionsStream(t *testing.T) {
+	server := newChatServer(t, &chatRunner{})
+
+	resp := postChat(t, server.URL, `{"model": "swe/coder", "stream": true, "messages": [{"role": "user", "content": "hi"}]}`)
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("content type: %q", ct)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var content strings.Builder
+	var done bool
+	for _, line := range strings.Split(string(data), "\n") {
+		v, ok := strings.CutPrefix(line, "data: ")
+		if !ok {
+			continue
+		}
+		if v == "[DONE]" {
+			done = true
+			continue
+		}
+		var chunk chatResponse
+		if err := json.Unmarshal([]byte(v), &chunk); err != nil {
+			t.Fatalf("chunk %q: %v", v, err)
+		}
+		if d := chunk.Choices[0].Delta; d != nil {
+			content.WriteString(d.Content)
+		}
+	}
+	if !done {
+		t.Errorf("missing [DONE]")
+	}
+	// the final result was streamed already
+	if got := content.String(); !strings.Contains(got, "a.txt") || strings.Count(got, "hi (0)") != 1 {
+		t.Errorf("content: %q", got)
+	}
+}
+
+// contextRunner builds the context of an agent with a context as ai:build_context does.
+type contextRunner struct {
+	vars    *api.Vars
+	history []*api.Message
+}
+
+func (r *contextRunner) Run(ctx context.Context, tid string, args map[string]any) (any, error) {
+	agent := &api.Agent{Pack: "swe", Name: "coder", Context: "project notes"}
+	if _, err := NewAIKit(r.vars).BuildContext(ctx, r.vars, agent, nil, args); err != nil {
+		return nil, err
+	}
+	r.history = api.ArgMap(args).History()
+	return "ok", nil
+}
+
+func TestChatCompletionsContext(t *testing.T) {
+	runner := &contextRunner{
+		vars: &api.Vars{
+			User:   &api.User{Settings: map[string]any{}},
+			Global: api.NewEnvironment(),
+			Roots:  &api.Roots{Workspace: &api.Root{Path: t.TempDir()}},
+		},
+	}
+	server := newChatServer(t, runner)
+
+	body := `{"model": "swe/coder", "messages": [
+		{"role": "user", "content": "hi"},
+		{"role": "assistant", "content": "hello"},
+		{"role": "user", "content": "list files"}
+	]}`
+	if resp := postChat(t, server.URL, body); resp.StatusCode != http.StatusOK {
+		t.Fatalf("status: %v", resp.StatusCode)
+	}
+	var got []string
+	for _, v := range runner.history {
+		got = append(got, v.Role+": "+v.Content)
+	}
+	want := []string{"user: project notes", "user: hi", "assistant: hello"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("history: %q want %q", got, want)
+	}
+}
+
+// policyRunner runs rm held by an ask rule.
+type policyRunner struct {
+	vars *api.Vars
+}
+
+func (r *policyRunner) Run(ctx context.Context, tid string, args map[string]any) (any, error) {
+	if _, err := authorize(ctx, r.vars, commandPolicyRequest("coder", []string{"rm", "build"})); err != nil {
+		return nil, err
+	}
+	return "removed", nil
+}
+
+func TestChatCompletionsPolicyAsk(t *testing.T) {
+	engine, err := policy.New(&api.PolicyConfig{
+		Rules: []*api.PolicyRule{
+			{Name: "confirm-rm", Action: api.PolicyAsk, Command: "rm"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub := &stubApprover{decision: &api.ApprovalDecision{Approved: true}}
+	runner := &policyRunner{
+		vars: &api.Vars{Policy: engine, Approver: stub},
+	}
+	server := newChatServer(t, runner)
+
+	body := `{"model": "swe/coder", "messages": [{"role": "user", "content": "clean up"}]}`
+	resp := postChat(t, server.URL, body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status: %v", resp.StatusCode)
+	}
+	if stub.req == nil || !strings.Contains(stub.req.Prompt, "rm build") {
+		t.Errorf("not confirmed through the approver: %+v", stub.req)
+	}
+
+	stub.decision = &api.ApprovalDecision{}
+	resp = postChat(t, server.URL, body)
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusInternalServerError || !strings.Contains(string(data), "declined by user") {
+		t.Errorf("expected declined: %v %s", resp.StatusCode, data)
+	}
+}
@@ -0,0 +1,112 @@
+package swarm
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/qiangli/ai/swarm/api"
+	"github.com/qiangli/ai/swarm/atm/conf"
+	"github.com/qiangli/ai/swarm/log"
+)
+
+// confirmPolicy asks the user to approve a call held by an "ask" rule through the approver,
+// on the console or, e.g. in serve modes where stdin and stdout are not the user's, through files.
+var confirmPolicy = func(ctx context.Context, vars *api.Vars, prompt string) (bool, error) {
+	if vars.Approver == nil {
+		return false, fmt.Errorf("no approver is available")
+	}
+	d, err := vars.Approver.Approve(ctx, &api.ApprovalRequest{
+		ID:      uuid.NewString(),
+		Kit:     "policy",
+		Name:    api.PolicyAsk,
+		Prompt:  prompt,
+		Created: time.Now(),
+	})
+	if err != nil {
+		return false, err
+	}
+	return d.Approved, nil
+}
+
+// toolPolicyRequest describes the tool call for the policy.
+// Commands run by bin tools and sh:exec are matched as shell commands.
+func toolPolicyRequest(agent string, tf *api.ToolFunc, args map[string]any) *api.PolicyRequest {
+	req := &api.PolicyRequest{
+		Agent: agent,
+		Kit:   tf.Kit,
+		Name:  tf.Name,
+		Args:  args,
+	}
+	switch {
+	case tf.Type == api.ToolTypeBin:
+		req.Argv = conf.Argv(tf.Name)
+		req.Kit = api.PolicyKitBin
+		if len(req.Argv) > 0 {
+			req.Name = path.Base(req.Argv[0])
+		}
+	case tf.Kit == "sh" && tf.Name == "exec":
+		if cmd, ok := args["command"].(string); ok {
+			req.Argv = conf.Argv(cmd)
+		}
+	}
+	return req
+}
+
+// commandPolicyRequest describes the shell command for the policy.
+func commandPolicyRequest(agent string, args []string) *api.PolicyRequest {
+	return &api.PolicyRequest{
+		Agent: agent,
+		Kit:   api.PolicyKitBin,
+		Name:  path.Base(args[0]),
+		Argv:  args,
+	}
+}
+
+// authorize enforces the policy and returns the decision; an error if the request is denied.
+func authorize(ctx context.Context, vars *api.Vars, req *api.PolicyRequest) (string, error) {
+	if vars.Policy == nil {
+		return "", nil
+	}
+	d := vars.Policy.Evaluate(req)
+
+	target := req.Kit + ":" + req.Name
+	if len(req.Argv) > 0 {
+		target = strings.Join(req.Argv, " ")
+	}
+	rule := d.Rule
+	if rule == "" {
+		rule = "default"
+	}
+	var reason string
+	if d.Reason != "" {
+		reason = ": " + d.Reason
+	}
+	logger := log.GetLogger(ctx)
+
+	switch d.Action {
+	case api.PolicyAllow:
+		logger.Debugf("🛡 allow %s @%s (%s)\n", target, req.Agent, rule)
+		return api.PolicyAllow, nil
+	case api.PolicyAsk:
+		prompt := fmt.Sprintf("Allow @%s to run %s%s?", req.Agent, target, reason)
+		ok, err := confirmPolicy(ctx, vars, prompt)
+		if err != nil {
+			logger.Infof("🛡 deny %s @%s (%s): confirmation failed: %v\n", target, req.Agent, rule, err)
+			return api.PolicyDeny, fmt.Errorf("%s requires confirmation (policy %s): %v", target, rule, err)
+		}
+		if !ok {
+			logger.Infof("🛡 deny %s @%s (%s): declined by user\n", target, req.Agent, rule)
+			return api.PolicyDeny, fmt.Errorf("%s declined by user (policy %s)", target, rule)
+		}
+		logger.Infof("🛡 allow %s @%s (%s): approved by user\n", target, req.Agent, rule)
+		return api.PolicyAsk, nil
+	default:
+		logger.Infof("🛡 deny %s @%s (%s)%s\n", target, req.Agent, rule, reason)
+		return api.PolicyDeny, fmt.Errorf("%s denied by policy %s%s", target, rule, reason)
+	}
+}
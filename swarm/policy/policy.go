@@ -0,0 +1,222 @@
+// Package policy authorizes tool calls and shell commands
+// with declarative allow/deny/ask rules loaded from yaml, e.g.
+//
+//	default: allow
+//	rules:
+//	  - name: no-force-remove
+//	    action: deny
+//	    command: rm
+//	    argv: '\s-[a-zA-Z]*(rf|fr)'
+//	    reason: recursive delete
+//	  - name: confirm-write
+//	    action: ask
+//	    kit: fs
+//	    tool: write_*
+//	  - name: confirm-push
+//	    action: ask
+//	    kit: sh
+//	    tool: exec
+//	    args:
+//	      command: '^git\s+push'
+//	shell_guard:
+//	  mode: ask
+//	  allow: [make]
+//	approval:
+//	  mode: file
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/qiangli/ai/swarm/api"
+)
+
+// Engine evaluates the rules of one or more policy files.
+type Engine struct {
+	defaultAction string
+	rules         []*rule
+	shellGuard    *api.ShellGuardConfig
+	approval      *api.ApprovalConfig
+}
+
+type rule struct {
+	*api.PolicyRule
+
+	args map[string]*regexp.Regexp
+	argv *regexp.Regexp
+}
+
+// New compiles the policies. The rules are evaluated in the given order;
+// the default action of the first policy that declares one applies.
+func New(configs ...*api.PolicyConfig) (*Engine, error) {
+	var engine = &Engine{}
+	for _, cfg := range configs {
+		if cfg == nil {
+			continue
+		}
+		if cfg.Default != "" {
+			if !validAction(cfg.Default) {
+				return nil, fmt.Errorf("invalid default policy action: %q", cfg.Default)
+			}
+			if engine.defaultAction == "" {
+				engine.defaultAction = cfg.Default
+			}
+		}
+		if v := cfg.ShellGuard; v != nil && engine.shellGuard == nil {
+			switch v.Mode {
+			case api.ShellGuardOff, api.ShellGuardAsk, api.ShellGuardDeny:
+			default:
+				return nil, fmt.Errorf("invalid shell_guard mode: %q. supported: off, ask, deny", v.Mode)
+			}
+			engine.shellGuard = v
+		}
+		if v := cfg.Approval; v != nil && engine.approval == nil {
+			switch v.Mode {
+			case "", api.ApprovalAuto, api.ApprovalConsole, api.ApprovalFile, api.ApprovalHttp:
+			default:
+				return nil, fmt.Errorf("invalid approval mode: %q. supported: auto, console, file, http", v.Mode)
+			}
+			engine.approval = v
+		}
+		for i, v := range cfg.Rules {
+			r, err := compile(v)
+			if err != nil {
+				return nil, fmt.Errorf("policy rule %v %q: %w", i+1, v.Name, err)
+			}
+			engine.rules = append(engine.rules, r)
+		}
+	}
+	if engine.defaultAction == "" {
+		engine.defaultAction = api.PolicyAllow
+	}
+	return engine, nil
+}
+
+// Load reads the policy files, missing files are skipped.
+func Load(files ...string) (*Engine, error) {
+	var configs []*api.PolicyConfig
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		var cfg api.PolicyConfig
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("invalid policy %s: %w", file, err)
+		}
+		configs = append(configs, &cfg)
+	}
+	return New(configs...)
+}
+
+func validAction(s string) bool {
+	switch s {
+	case api.PolicyAllow, api.PolicyDeny, api.PolicyAsk:
+		return true
+	}
+	return false
+}
+
+func compile(v *api.PolicyRule) (*rule, error) {
+	if !validAction(v.Action) {
+		return nil, fmt.Errorf("invalid action: %q. supported: allow, deny, ask", v.Action)
+	}
+	// fail early on malformed glob patterns
+	for _, p := range []string{v.Kit, v.Tool, v.Command} {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", p, err)
+		}
+	}
+	r := &rule{
+		PolicyRule: v,
+		args:       make(map[string]*regexp.Regexp),
+	}
+	for k, p := range v.Args {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid args pattern %q: %w", k, err)
+		}
+		r.args[k] = re
+	}
+	if v.Argv != "" {
+		re, err := regexp.Compile(v.Argv)
+		if err != nil {
+			return nil, fmt.Errorf("invalid argv pattern: %w", err)
+		}
+		r.argv = re
+	}
+	return r, nil
+}
+
+func (r *rule) match(req *api.PolicyRequest) bool {
+	if !glob(r.Kit, req.Kit) || !glob(r.Tool, req.Name) {
+		return false
+	}
+	for k, re := range r.args {
+		v, ok := req.Args[k]
+		if !ok || !re.MatchString(api.ToString(v)) {
+			return false
+		}
+	}
+	if r.Command != "" || r.argv != nil {
+		if len(req.Argv) == 0 {
+			return false
+		}
+		if !glob(r.Command, path.Base(req.Argv[0])) {
+			return false
+		}
+		if r.argv != nil && !r.argv.MatchString(strings.Join(req.Argv, " ")) {
+			return false
+		}
+	}
+	return true
+}
+
+// empty pattern matches all
+func glob(pattern, s string) bool {
+	if pattern == "" {
+		return true
+	}
+	ok, _ := path.Match(pattern, s)
+	return ok
+}
+
+// ShellGuard returns the shell guard configuration of the first policy that declares one; nil if none.
+func (r *Engine) ShellGuard() *api.ShellGuardConfig {
+	return r.shellGuard
+}
+
+// Approval returns the approval configuration of the first policy that declares one; nil if none.
+func (r *Engine) Approval() *api.ApprovalConfig {
+	return r.approval
+}
+
+// Evaluate returns the action of the first matching rule or the default action.
+func (r *Engine) Evaluate(req *api.PolicyRequest) *api.PolicyDecision {
+	for i, v := range r.rules {
+		if v.match(req) {
+			name := v.Name
+			if name == "" {
+				name = fmt.Sprintf("#%v", i+1)
+			}
+			return &api.PolicyDecision{
+				Action: v.Action,
+				Rule:   name,
+				Reason: v.Reason,
+			}
+		}
+	}
+	return &api.PolicyDecision{
+		Action: r.defaultAction,
+	}
+}
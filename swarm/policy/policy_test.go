@@ -0,0 +1,96 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/qiangli/ai/swarm/api"
+)
+
+func TestEvaluate(t *testing.T) {
+	workspace := &api.PolicyConfig{
+		Rules: []*api.PolicyRule{
+			{Name: "no-force-remove", Action: api.PolicyDeny, Command: "rm", Argv: `\s-[a-zA-Z]*(rf|fr)`},
+			{Name: "confirm-write", Action: api.PolicyAsk, Kit: "fs", Tool: "write_*"},
+			{Name: "confirm-push", Action: api.PolicyAsk, Kit: "sh", Tool: "exec", Args: map[string]string{"command": `^git\s+push`}},
+		},
+	}
+	user := &api.PolicyConfig{
+		Default: api.PolicyDeny,
+		Rules: []*api.PolicyRule{
+			{Action: api.PolicyAllow, Kit: "fs"},
+			{Action: api.PolicyAllow, Kit: "sh"},
+			{Action: api.PolicyAllow, Kit: api.PolicyKitBin, Tool: "rm"},
+		},
+	}
+	engine, err := New(workspace, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		req    *api.PolicyRequest
+		action string
+		rule   string
+	}{
+		{&api.PolicyRequest{Kit: "bin", Name: "rm", Argv: []string{"/bin/rm", "-rf", "/tmp/x"}}, api.PolicyDeny, "no-force-remove"},
+		{&api.PolicyRequest{Kit: "bin", Name: "rm", Argv: []string{"rm", "/tmp/x"}}, api.PolicyAllow, "#6"},
+		{&api.PolicyRequest{Kit: "fs", Name: "write_file"}, api.PolicyAsk, "confirm-write"},
+		{&api.PolicyRequest{Kit: "fs", Name: "read_file"}, api.PolicyAllow, "#4"},
+		{&api.PolicyRequest{Kit: "sh", Name: "exec", Args: map[string]any{"command": "git push origin"}}, api.PolicyAsk, "confirm-push"},
+		{&api.PolicyRequest{Kit: "sh", Name: "exec", Args: map[string]any{"command": "git status"}}, api.PolicyAllow, "#5"},
+		{&api.PolicyRequest{Kit: "web", Name: "fetch"}, api.PolicyDeny, ""},
+	}
+	for _, tt := range tests {
+		d := engine.Evaluate(tt.req)
+		if d.Action != tt.action || d.Rule != tt.rule {
+			t.Errorf("%s:%s %v: got %s (%s) want %s (%s)", tt.req.Kit, tt.req.Name, tt.req.Argv, d.Action, d.Rule, tt.action, tt.rule)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "policy.yaml")
+	data := `
+rules:
+  - name: ask-bin
+    action: ask
+    kit: bin
+`
+	if err := os.WriteFile(file, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// missing files are skipped
+	engine, err := Load(filepath.Join(dir, "missing.yaml"), file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := engine.Evaluate(&api.PolicyRequest{Kit: "bin", Name: "ls", Argv: []string{"ls"}}); d.Action != api.PolicyAsk {
+		t.Errorf("expected ask, got %s", d.Action)
+	}
+	if d := engine.Evaluate(&api.PolicyRequest{Kit: "fs", Name: "read_file"}); d.Action != api.PolicyAllow {
+		t.Errorf("expected default allow, got %s", d.Action)
+	}
+	if engine.ShellGuard() != nil {
+		t.Errorf("expected no shell guard")
+	}
+
+	invalid := []string{
+		"rules:\n  - action: block\n",
+		"default: maybe\n",
+		"rules:\n  - action: deny\n    argv: '('\n",
+		"rules:\n  - action: deny\n    tool: '['\n",
+		"shell_guard:\n  mode: maybe\n",
+	}
+	for _, v := range invalid {
+		if err := os.WriteFile(file, []byte(v), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(file); err == nil {
+			t.Errorf("expected error for %q", v)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package swarm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/qiangli/ai/swarm/api"
+	"github.com/qiangli/ai/swarm/policy"
+)
+
+func TestAuthorize(t *testing.T) {
+	engine, err := policy.New(&api.PolicyConfig{
+		Rules: []*api.PolicyRule{
+			{Name: "no-rm", Action: api.PolicyDeny, Command: "rm", Reason: "destructive"},
+			{Name: "confirm-exec", Action: api.PolicyAsk, Kit: "sh", Tool: "exec"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vars := &api.Vars{Policy: engine}
+
+	var approve bool
+	var asked int
+	saved := confirmPolicy
+	defer func() { confirmPolicy = saved }()
+	confirmPolicy = func(ctx context.Context, vars *api.Vars, prompt string) (bool, error) {
+		asked++
+		return approve, nil
+	}
+
+	ctx := context.TODO()
+	bin := &api.ToolFunc{Type: api.ToolTypeBin, Kit: "bin", Name: "rm -f /tmp/x"}
+	if d, err := authorize(ctx, vars, toolPolicyRequest("ask", bin, nil)); err == nil || d != api.PolicyDeny {
+		t.Errorf("expected rm to be denied: %s %v", d, err)
+	}
+	if _, err := authorize(ctx, vars, commandPolicyRequest("ask", []string{"/usr/bin/rm", "x"})); err == nil {
+		t.Errorf("expected shell rm to be denied")
+	}
+	if d, err := authorize(ctx, vars, commandPolicyRequest("ask", []string{"ls"})); err != nil || d != api.PolicyAllow {
+		t.Errorf("expected ls to be allowed: %s %v", d, err)
+	}
+
+	exec := &api.ToolFunc{Type: api.ToolTypeSystem, Kit: "sh", Name: "exec"}
+	args := map[string]any{"command": "ls -al"}
+	if _, err := authorize(ctx, vars, toolPolicyRequest("ask", exec, args)); err == nil {
+		t.Errorf("expected declined exec to fail")
+	}
+	approve = true
+	if d, err := authorize(ctx, vars, toolPolicyRequest("ask", exec, args)); err != nil || d != api.PolicyAsk {
+		t.Errorf("expected approved exec: %s %v", d, err)
+	}
+	if asked != 2 {
+		t.Errorf("expected 2 confirmations, got %v", asked)
+	}
+
+	// no policy
+	if _, err := authorize(ctx, &api.Vars{}, toolPolicyRequest("ask", bin, nil)); err != nil {
+		t.Errorf("expected no policy to allow: %v", err)
+	}
+}
+
+func TestConfirmPolicyApprover(t *testing.T) {
+	ctx := context.TODO()
+	stub := &stubApprover{decision: &api.ApprovalDecision{Approved: true}}
+	vars := &api.Vars{Approver: stub}
+	if ok, err := confirmPolicy(ctx, vars, "Allow @ask to run ls?"); err != nil || !ok {
+		t.Errorf("expected approved: %v %v", ok, err)
+	}
+	if stub.req.Prompt != "Allow @ask to run ls?" || stub.req.ID == "" {
+		t.Errorf("unexpected request: %+v", stub.req)
+	}
+	stub.decision = &api.ApprovalDecision{}
+	if ok, err := confirmPolicy(ctx, vars, "Allow @ask to run ls?"); err != nil || ok {
+		t.Errorf("expected declined: %v %v", ok, err)
+	}
+
+	// no terminal to ask on
+	if _, err := confirmPolicy(ctx, &api.Vars{}, "Allow @ask to run ls?"); err == nil {
+		t.Errorf("expected error without approver")
+	}
+}
@@ -38,3 +38,9 @@ func FormatFile(format string) string {
 		return ""
 	}
 }
+
+//go:embed shell_security_system.md
+var ShellSecuritySystem string
+
+//go:embed shell_security_user.md
+var ShellSecurityUser string
@@ -0,0 +1,363 @@
+package swarm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+
+	"mvdan.cc/sh/v3/interp"
+	"mvdan.cc/sh/v3/syntax"
+
+	"github.com/qiangli/ai/swarm/api"
+	"github.com/qiangli/ai/swarm/atm/conf"
+	"github.com/qiangli/ai/swarm/log"
+	"github.com/qiangli/ai/swarm/resource"
+)
+
+// commands that only read or print information.
+// nil: any arguments; otherwise the first argument must be one of the subcommands.
+var safeCommands = map[string][]string{
+	"basename": nil, "cat": nil, "cut": nil, "date": nil, "df": nil, "diff": nil, "dirname": nil,
+	"du": nil, "echo": nil, "false": nil, "file": nil, "grep": nil, "head": nil,
+	"hostname": nil, "id": nil, "less": nil, "ls": nil, "printenv": nil, "printf": nil, "pwd": nil,
+	"readlink": nil, "realpath": nil, "sort": nil, "stat": nil, "tail": nil, "test": nil, "[": nil, "tr": nil,
+	"true": nil, "uname": nil, "uniq": nil, "wc": nil, "which": nil, "whoami": nil,
+	"git": {"status", "log", "diff", "show", "blame", "rev-parse", "ls-files"},
+	"go":  {"version", "env", "list", "doc", "vet"},
+}
+
+// flags of the safe commands that write files, change the system or run other programs.
+// short flags also match when combined, e.g. -uo for -o.
+var unsafeFlags = map[string][]string{
+	"sort":     {"-o", "--output", "--compress-program"},
+	"git":      {"--output", "--ext-diff"},
+	"go":       {"-w", "-u", "-vettool", "-toolexec", "-exec"},
+	"date":     {"-s", "--set"},
+	"file":     {"-C", "--compile"},
+	"hostname": {"-F", "--file"},
+}
+
+// max number of operands of the safe commands, more write a file or change the system.
+// arguments starting with + such as the format of date are not operands.
+var maxOperands = map[string]int{
+	"uniq":     1,
+	"hostname": 0,
+	"date":     0,
+}
+
+// builtins that run arbitrary code, including their arguments as commands.
+// env and xargs also run their arguments but are not builtins and not in safeCommands.
+var unsafeBuiltins = []string{"eval", "exec", "source", ".", "command", "builtin", "trap", "alias"}
+
+// shellCommand is a simple command of a script.
+type shellCommand struct {
+	Argv []string
+	// output redirected to a file
+	Writes bool
+}
+
+// parseShellCommands returns the simple commands of the script including those in command substitutions.
+// Words that are not literals, e.g. with variables, are kept in their source form.
+func parseShellCommands(script string) ([]*shellCommand, error) {
+	file, err := syntax.NewParser().Parse(strings.NewReader(script), "")
+	if err != nil {
+		return nil, err
+	}
+	printer := syntax.NewPrinter()
+	word := func(w *syntax.Word) string {
+		if s := w.Lit(); s != "" {
+			return s
+		}
+		var b bytes.Buffer
+		printer.Print(&b, w)
+		return b.String()
+	}
+
+	var list []*shellCommand
+	syntax.Walk(file, func(node syntax.Node) bool {
+		stmt, ok := node.(*syntax.Stmt)
+		if !ok {
+			return true
+		}
+		call, ok := stmt.Cmd.(*syntax.CallExpr)
+		if !ok || len(call.Args) == 0 {
+			return true
+		}
+		cmd := &shellCommand{}
+		for _, w := range call.Args {
+			cmd.Argv = append(cmd.Argv, word(w))
+		}
+		for _, r := range stmt.Redirs {
+			switch r.Op {
+			case syntax.RdrOut, syntax.AppOut, syntax.RdrAll, syntax.AppAll, syntax.ClbOut:
+				if r.Word != nil && word(r.Word) != "/dev/null" {
+					cmd.Writes = true
+				}
+			}
+		}
+		list = append(list, cmd)
+		return true
+	})
+	return list, nil
+}
+
+// ShellGuard classifies the commands run by sh:bash, sh:exec and /bin/* before they are run.
+type ShellGuard struct {
+	Config *api.ShellGuardConfig
+
+	// LLM classification of the resolved command line
+	Classify func(ctx context.Context, vars *api.Vars, agent *api.Agent, command string, args []string) (bool, error)
+
+	mu sync.Mutex
+	// verdicts by resolved command line
+	verdicts map[string]bool
+}
+
+func NewShellGuard(cfg *api.ShellGuardConfig) *ShellGuard {
+	guard := &ShellGuard{
+		Config:   cfg,
+		verdicts: make(map[string]bool),
+	}
+	guard.Classify = guard.classify
+	return guard
+}
+
+// staticSafe reports whether the command is known to be safe without asking the LLM.
+func (r *ShellGuard) staticSafe(cmd *shellCommand) bool {
+	if cmd.Writes {
+		return false
+	}
+	name := path.Base(cmd.Argv[0])
+	// actions are authorized when the tool is called
+	if conf.IsAction(cmd.Argv[0]) {
+		return true
+	}
+	if interp.IsBuiltin(name) {
+		return !slices.Contains(unsafeBuiltins, name)
+	}
+	if slices.Contains(r.Config.Allow, name) {
+		return true
+	}
+	subs, ok := safeCommands[name]
+	if !ok {
+		return false
+	}
+	if subs != nil && (len(cmd.Argv) < 2 || !slices.Contains(subs, cmd.Argv[1])) {
+		return false
+	}
+	return safeArgs(name, cmd.Argv[1:])
+}
+
+// safeArgs reports whether the arguments of a safe command contain no unsafe flags
+// and no more operands than allowed.
+func safeArgs(name string, args []string) bool {
+	var operands int
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "+") {
+			continue
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			operands++
+			continue
+		}
+		for _, flag := range unsafeFlags[name] {
+			if arg == flag || strings.HasPrefix(arg, flag+"=") {
+				return false
+			}
+			// -o, -ofile, -uo
+			if len(flag) == 2 && !strings.HasPrefix(arg, "--") && strings.ContainsRune(arg[1:], rune(flag[1])) {
+				return false
+			}
+		}
+	}
+	if n, ok := maxOperands[name]; ok && operands > n {
+		return false
+	}
+	return true
+}
+
+// Unsafe returns the commands of the script that are not safe to run.
+func (r *ShellGuard) Unsafe(ctx context.Context, vars *api.Vars, agent *api.Agent, script string) ([]string, error) {
+	cmds, err := parseShellCommands(script)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse shell script: %v", err)
+	}
+	var unsafe []string
+	for _, cmd := range cmds {
+		if r.staticSafe(cmd) {
+			continue
+		}
+		// writes are never safe, no need to classify
+		if cmd.Writes {
+			unsafe = append(unsafe, strings.Join(cmd.Argv, " "))
+			continue
+		}
+		// resolve the command so that verdicts are not shared by different binaries of the same name
+		resolved := cmd.Argv[0]
+		if v, err := exec.LookPath(resolved); err == nil {
+			resolved = v
+		}
+		key := strings.Join(append([]string{resolved}, cmd.Argv[1:]...), " ")
+
+		r.mu.Lock()
+		safe, found := r.verdicts[key]
+		r.mu.Unlock()
+
+		if !found {
+			if v, err := r.Classify(ctx, vars, agent, resolved, cmd.Argv[1:]); err != nil {
+				// unknown is unsafe, not cached so that it is classified again
+				log.GetLogger(ctx).Errorf("failed to classify %q: %v\n", key, err)
+				safe = false
+			} else {
+				safe = v
+				r.mu.Lock()
+				r.verdicts[key] = safe
+				r.mu.Unlock()
+			}
+		}
+		log.GetLogger(ctx).Debugf("shell guard: %q safe: %v\n", key, safe)
+		if !safe {
+			unsafe = append(unsafe, strings.Join(cmd.Argv, " "))
+		}
+	}
+	return unsafe, nil
+}
+
+// Check requires confirmation for or blocks scripts with unsafe commands depending on the mode.
+func (r *ShellGuard) Check(ctx context.Context, vars *api.Vars, agent *api.Agent, script string) error {
+	if r.Config == nil || r.Config.Mode == "" || r.Config.Mode == api.ShellGuardOff {
+		return nil
+	}
+	unsafe, err := r.Unsafe(ctx, vars, agent, script)
+	if err != nil {
+		return err
+	}
+	if len(unsafe) == 0 {
+		return nil
+	}
+	list := strings.Join(unsafe, "\n")
+	if r.Config.Mode == api.ShellGuardAsk {
+		ok, err := confirmPolicy(ctx, vars, fmt.Sprintf("Unsafe command(s):\n%s\n\nRun anyway?", list))
+		if err == nil && ok {
+			log.GetLogger(ctx).Infof("🛡 unsafe command(s) approved by user: %s\n", list)
+			return nil
+		}
+		log.GetLogger(ctx).Infof("🛡 unsafe command(s) not approved: %s\n", list)
+		return fmt.Errorf("unsafe command(s) not approved:\n%s", list)
+	}
+	log.GetLogger(ctx).Infof("🛡 unsafe command(s) blocked: %s\n", list)
+	return fmt.Errorf("unsafe command(s) blocked by shell guard:\n%s", list)
+}
+
+// shellScript returns the script to be run by sh:bash, sh:exec and /bin/* tools; empty for other tools.
+func (r *AgentToolRunner) shellScript(tf *api.ToolFunc, args map[string]any) string {
+	if tf.Type == api.ToolTypeBin {
+		return tf.Name
+	}
+	if tf.Kit != "sh" || (tf.Name != "bash" && tf.Name != "exec") {
+		return ""
+	}
+	if v, ok := args["command"]; ok {
+		return api.ToString(v)
+	}
+	if tf.Name == "bash" {
+		if v, ok := args["script"]; ok {
+			// failure is reported by the tool
+			s, _ := api.LoadURIContent(r.vars.Workspace, api.ToString(v))
+			return s
+		}
+	}
+	return ""
+}
+
+var shellVerdictSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"command": map[string]any{"type": "string"},
+		"safe":    map[string]any{"type": "boolean"},
+	},
+	"required": []any{"command", "safe"},
+}
+
+// classify asks the LLM whether the command is safe using the shell security prompts.
+func (r *ShellGuard) classify(ctx context.Context, vars *api.Vars, agent *api.Agent, command string, args []string) (bool, error) {
+	render := func(text string, data any) (string, error) {
+		tpl, err := template.New("shell_security").Parse(text)
+		if err != nil {
+			return "", err
+		}
+		var b bytes.Buffer
+		if err := tpl.Execute(&b, data); err != nil {
+			return "", err
+		}
+		return b.String(), nil
+	}
+	shell := "bash"
+	if runtime.GOOS == "windows" {
+		shell = "powershell"
+	}
+	prompt, err := render(resource.ShellSecuritySystem, map[string]any{"OS": runtime.GOOS, "Shell": shell})
+	if err != nil {
+		return false, err
+	}
+	query, err := render(resource.ShellSecurityUser, map[string]any{
+		"Extra": map[string]any{"Command": command, "Args": strings.Join(args, " ")},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	// classify with a copy of the agent without tools
+	var a api.Agent
+	if agent != nil {
+		a = *agent
+	}
+	if a.Model == nil && vars.RootAgent != nil {
+		a.Model = vars.RootAgent.Model
+	}
+	if alias := r.Config.Model; alias != "" {
+		set, level := api.Setlevel(alias).Decode()
+		model := findModel(agent, set, level)
+		if model == nil {
+			if model, err = conf.LoadModel(vars.User.Email, set, level, vars.Assets); err != nil {
+				return false, err
+			}
+		}
+		a.Model = model
+	}
+	if a.Model == nil {
+		return false, fmt.Errorf("no model for shell command classification")
+	}
+	a.Prompt = prompt
+	a.Query = query
+	a.Tools = nil
+	a.History = []*api.Message{
+		{Role: api.RoleSystem, Content: prompt},
+		{Role: api.RoleUser, Content: query},
+	}
+
+	argm := api.ArgMap{
+		"output_schema": shellVerdictSchema,
+		// the verdict is not part of the reply on the console or to serve clients
+		"stream": false,
+		// verdicts persist across sessions if the response cache is available
+		"cache": true,
+	}
+	result, err := NewAIKit(vars).LlmAdapter(ctx, vars, &a, nil, argm)
+	if err != nil {
+		return false, err
+	}
+	data, ok := result.Data.(map[string]any)
+	if !ok {
+		return false, fmt.Errorf("invalid classification: %s", result.Value)
+	}
+	safe, _ := data["safe"].(bool)
+	return safe, nil
+}
@@ -0,0 +1,145 @@
+package swarm
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/qiangli/ai/swarm/api"
+)
+
+func TestParseShellCommands(t *testing.T) {
+	cmds, err := parseShellCommands(`ls -al | grep "$HOME" && echo $(rm -rf /tmp/x) > out.txt; cat a 2>/dev/null`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, v := range cmds {
+		s := strings.Join(v.Argv, " ")
+		if v.Writes {
+			s += " >"
+		}
+		got = append(got, s)
+	}
+	want := []string{`ls -al`, `grep "$HOME"`, `echo $(rm -rf /tmp/x) >`, `rm -rf /tmp/x`, `cat a`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q want %q", got, want)
+	}
+
+	if _, err := parseShellCommands(`echo "unterminated`); err == nil {
+		t.Errorf("expected parse error")
+	}
+}
+
+func TestShellGuard(t *testing.T) {
+	var classified []string
+	guard := NewShellGuard(&api.ShellGuardConfig{Mode: api.ShellGuardDeny, Allow: []string{"make"}})
+	guard.Classify = func(ctx context.Context, vars *api.Vars, agent *api.Agent, command string, args []string) (bool, error) {
+		classified = append(classified, strings.Join(append([]string{command}, args...), " "))
+		return !strings.Contains(command, "rm"), nil
+	}
+	ctx := context.TODO()
+	vars := &api.Vars{}
+
+	// static allowlist
+	if err := guard.Check(ctx, vars, nil, "ls -al; git status; make build; cd /tmp && pwd"); err != nil {
+		t.Errorf("expected safe: %v", err)
+	}
+	if len(classified) != 0 {
+		t.Errorf("static safe commands should not be classified: %v", classified)
+	}
+
+	// classified and cached
+	for range 2 {
+		if err := guard.Check(ctx, vars, nil, "rm -rf build"); err == nil {
+			t.Errorf("expected rm to be blocked")
+		}
+	}
+	if len(classified) != 1 {
+		t.Errorf("expected verdict to be cached: %v", classified)
+	}
+	if err := guard.Check(ctx, vars, nil, "git push"); err != nil {
+		t.Errorf("expected classified safe: %v", err)
+	}
+	// writes are never safe, not classified
+	n := len(classified)
+	if err := guard.Check(ctx, vars, nil, "echo hi > file.txt"); err == nil {
+		t.Errorf("expected redirect to be blocked")
+	}
+	if err := guard.Check(ctx, vars, nil, "git log > log.txt"); err == nil {
+		t.Errorf("expected redirect to be blocked")
+	}
+	if len(classified) != n {
+		t.Errorf("writes should not be classified: %v", classified[n:])
+	}
+
+	// ask
+	saved := confirmPolicy
+	defer func() { confirmPolicy = saved }()
+	var approve bool
+	confirmPolicy = func(ctx context.Context, vars *api.Vars, prompt string) (bool, error) {
+		if !strings.Contains(prompt, "rm -rf build") {
+			t.Errorf("unexpected prompt: %s", prompt)
+		}
+		return approve, nil
+	}
+	guard.Config.Mode = api.ShellGuardAsk
+	if err := guard.Check(ctx, vars, nil, "rm -rf build"); err == nil {
+		t.Errorf("expected declined command to fail")
+	}
+	approve = true
+	if err := guard.Check(ctx, vars, nil, "rm -rf build"); err != nil {
+		t.Errorf("expected approved command: %v", err)
+	}
+
+	guard.Config.Mode = api.ShellGuardOff
+	if err := guard.Check(ctx, vars, nil, "rm -rf /"); err != nil {
+		t.Errorf("expected guard off: %v", err)
+	}
+}
+
+func TestShellGuardBypass(t *testing.T) {
+	guard := NewShellGuard(&api.ShellGuardConfig{Mode: api.ShellGuardDeny})
+	// everything not statically safe is unsafe
+	guard.Classify = func(ctx context.Context, vars *api.Vars, agent *api.Agent, command string, args []string) (bool, error) {
+		return false, nil
+	}
+	tests := []struct {
+		script string
+		safe   bool
+	}{
+		{"rm -rf /tmp/x", false},
+		{"env rm -rf /tmp/x", false},
+		{"command rm -rf /tmp/x", false},
+		{"builtin command rm -rf x", false},
+		{"xargs rm < files.txt", false},
+		{"trap 'rm -rf x' EXIT", false},
+		{"sort -o /etc/passwd x", false},
+		{"sort -uo /etc/passwd x", false},
+		{"sort --output=/etc/passwd x", false},
+		{"git diff --output=/tmp/y", false},
+		{"git show --output /tmp/y HEAD", false},
+		{"go env -w GOFLAGS=-mod=mod", false},
+		{"go vet -vettool=/tmp/x ./...", false},
+		{"uniq a b", false},
+		{"date -s 2020-01-01", false},
+		{"date 0101000020", false},
+		{"hostname evil", false},
+		// read only
+		{"sort -u -k2 x", true},
+		{"git diff --stat HEAD", true},
+		{"git log --oneline", true},
+		{"go env GOPATH", true},
+		{"uniq -c a", true},
+		{"date +%s", true},
+		{"hostname -f", true},
+		{"cd /tmp && pwd && echo hi", true},
+	}
+	for _, tt := range tests {
+		err := guard.Check(context.TODO(), &api.Vars{}, nil, tt.script)
+		if safe := err == nil; safe != tt.safe {
+			t.Errorf("%q: safe %v want %v (%v)", tt.script, safe, tt.safe, err)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// FileExporter appends the spans of each batch as a line of OTLP JSON,
+// the format of the OTLP/HTTP JSON protocol and the collector file exporter.
+// https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+type FileExporter struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func NewFileExporter(path string) (*FileExporter, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open trace file: %w", err)
+	}
+	return &FileExporter{file: f}, nil
+}
+
+func (r *FileExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(toTraceData(spans))
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return fmt.Errorf("trace file exporter is shut down")
+	}
+	_, err = r.file.Write(append(data, '\n'))
+	return err
+}
+
+func (r *FileExporter) Shutdown(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// OTLP JSON
+type traceData struct {
+	ResourceSpans []*resourceSpans `json:"resourceSpans"`
+}
+
+type resourceSpans struct {
+	Resource   *otlpResource `json:"resource"`
+	ScopeSpans []*scopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []*keyValue `json:"attributes"`
+}
+
+type scopeSpans struct {
+	Scope *scope      `json:"scope"`
+	Spans []*otlpSpan `json:"spans"`
+}
+
+type scope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string      `json:"traceId"`
+	SpanID            string      `json:"spanId"`
+	ParentSpanID      string      `json:"parentSpanId,omitempty"`
+	Name              string      `json:"name"`
+	Kind              int         `json:"kind"`
+	StartTimeUnixNano string      `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string      `json:"endTimeUnixNano"`
+	Attributes        []*keyValue `json:"attributes,omitempty"`
+	Events            []*event    `json:"events,omitempty"`
+	Status            *spanStatus `json:"status,omitempty"`
+}
+
+type event struct {
+	TimeUnixNano string      `json:"timeUnixNano"`
+	Name         string      `json:"name"`
+	Attributes   []*keyValue `json:"attributes,omitempty"`
+}
+
+type spanStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type keyValue struct {
+	Key   string    `json:"key"`
+	Value *anyValue `json:"value"`
+}
+
+type anyValue struct {
+	StringValue *string     `json:"stringValue,omitempty"`
+	BoolValue   *bool       `json:"boolValue,omitempty"`
+	IntValue    *string     `json:"intValue,omitempty"`
+	DoubleValue *float64    `json:"doubleValue,omitempty"`
+	ArrayValue  *arrayValue `json:"arrayValue,omitempty"`
+}
+
+type arrayValue struct {
+	Values []*anyValue `json:"values"`
+}
+
+// spans of the same provider share the resource, grouped by instrumentation scope.
+func toTraceData(spans []sdktrace.ReadOnlySpan) *traceData {
+	rs := &resourceSpans{
+		Resource: &otlpResource{},
+	}
+	if res := spans[0].Resource(); res != nil {
+		rs.Resource.Attributes = toKeyValues(res.Attributes())
+	}
+	scopes := make(map[string]*scopeSpans)
+	for _, s := range spans {
+		is := s.InstrumentationScope()
+		ss, ok := scopes[is.Name]
+		if !ok {
+			ss = &scopeSpans{Scope: &scope{Name: is.Name, Version: is.Version}}
+			scopes[is.Name] = ss
+			rs.ScopeSpans = append(rs.ScopeSpans, ss)
+		}
+		ss.Spans = append(ss.Spans, toSpan(s))
+	}
+	return &traceData{ResourceSpans: []*resourceSpans{rs}}
+}
+
+func toSpan(s sdktrace.ReadOnlySpan) *otlpSpan {
+	sc := s.SpanContext()
+	tid := sc.TraceID()
+	sid := sc.SpanID()
+	span := &otlpSpan{
+		TraceID:           hex.EncodeToString(tid[:]),
+		SpanID:            hex.EncodeToString(sid[:]),
+		Name:              s.Name(),
+		Kind:              int(s.SpanKind()),
+		StartTimeUnixNano: strconv.FormatInt(s.StartTime().UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.EndTime().UnixNano(), 10),
+		Attributes:        toKeyValues(s.Attributes()),
+	}
+	if p := s.Parent(); p.IsValid() {
+		pid := p.SpanID()
+		span.ParentSpanID = hex.EncodeToString(pid[:])
+	}
+	for _, e := range s.Events() {
+		span.Events = append(span.Events, &event{
+			TimeUnixNano: strconv.FormatInt(e.Time.UnixNano(), 10),
+			Name:         e.Name,
+			Attributes:   toKeyValues(e.Attributes),
+		})
+	}
+	// the codes of OTLP differ from the api: ok 1, error 2
+	switch st := s.Status(); st.Code {
+	case codes.Ok:
+		span.Status = &spanStatus{Code: 1}
+	case codes.Error:
+		span.Status = &spanStatus{Code: 2, Message: st.Description}
+	}
+	return span
+}
+
+func toKeyValues(attrs []attribute.KeyValue) []*keyValue {
+	var list []*keyValue
+	for _, v := range attrs {
+		list = append(list, &keyValue{Key: string(v.Key), Value: toAnyValue(v.Value)})
+	}
+	return list
+}
+
+func toAnyValue(v attribute.Value) *anyValue {
+	str := func(s string) *anyValue {
+		return &anyValue{StringValue: &s}
+	}
+	integer := func(i int64) *anyValue {
+		s := strconv.FormatInt(i, 10)
+		return &anyValue{IntValue: &s}
+	}
+	switch v.Type() {
+	case attribute.BOOL:
+		b := v.AsBool()
+		return &anyValue{BoolValue: &b}
+	case attribute.INT64:
+		return integer(v.AsInt64())
+	case attribute.FLOAT64:
+		f := v.AsFloat64()
+		return &anyValue{DoubleValue: &f}
+	case attribute.BOOLSLICE:
+		var arr arrayValue
+		for _, b := range v.AsBoolSlice() {
+			arr.Values = append(arr.Values, &anyValue{BoolValue: &b})
+		}
+		return &anyValue{ArrayValue: &arr}
+	case attribute.INT64SLICE:
+		var arr arrayValue
+		for _, i := range v.AsInt64Slice() {
+			arr.Values = append(arr.Values, integer(i))
+		}
+		return &anyValue{ArrayValue: &arr}
+	case attribute.FLOAT64SLICE:
+		var arr arrayValue
+		for _, f := range v.AsFloat64Slice() {
+			arr.Values = append(arr.Values, &anyValue{DoubleValue: &f})
+		}
+		return &anyValue{ArrayValue: &arr}
+	case attribute.STRINGSLICE:
+		var arr arrayValue
+		for _, s := range v.AsStringSlice() {
+			arr.Values = append(arr.Values, str(s))
+		}
+		return &anyValue{ArrayValue: &arr}
+	}
+	return str(v.Emit())
+}
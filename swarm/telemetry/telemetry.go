@@ -0,0 +1,141 @@
+// Package telemetry traces agents, tools, LLM calls and flow steps with OpenTelemetry.
+//
+// Tracing is configured in dhnt.json and disabled by default:
+//
+//	"trace": {"exporter": "otlp", "endpoint": "http://localhost:4318"}
+//	"trace": {"exporter": "file"}
+//
+// The file exporter writes OTLP JSON that can be loaded into Jaeger without a collector.
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/qiangli/ai/swarm/api"
+)
+
+const (
+	ServiceName = "ai"
+
+	scopeName = "github.com/qiangli/ai/swarm"
+)
+
+// span attributes
+const (
+	AttrAgent       = attribute.Key("ai.agent")
+	AttrParentAgent = attribute.Key("ai.agent.parent")
+	AttrSession     = attribute.Key("ai.session")
+	AttrAction      = attribute.Key("ai.action")
+
+	AttrToolKit  = attribute.Key("ai.tool.kit")
+	AttrToolName = attribute.Key("ai.tool.name")
+	AttrPolicy   = attribute.Key("ai.tool.policy")
+	AttrApproval = attribute.Key("ai.tool.approval")
+
+	AttrFlowStep    = attribute.Key("ai.flow.step")
+	AttrFlowSkipped = attribute.Key("ai.flow.skipped")
+
+	// https://opentelemetry.io/docs/specs/semconv/gen-ai/
+	AttrLlmSystem       = attribute.Key("gen_ai.system")
+	AttrLlmModel        = attribute.Key("gen_ai.request.model")
+	AttrLlmInputTokens  = attribute.Key("gen_ai.usage.input_tokens")
+	AttrLlmOutputTokens = attribute.Key("gen_ai.usage.output_tokens")
+)
+
+var (
+	mu       sync.Mutex
+	provider *sdktrace.TracerProvider
+)
+
+// Setup installs the tracer provider of the exporter.
+// The spans are not recorded if cfg is nil or the exporter is not set.
+// File paths are relative to the workspace.
+func Setup(ctx context.Context, cfg *api.TraceConfig, workspace string, session api.SessionID) error {
+	if cfg == nil || cfg.Exporter == "" {
+		return nil
+	}
+
+	var exporter sdktrace.SpanExporter
+	switch cfg.Exporter {
+	case api.TraceExporterOtlp:
+		var opts []otlptracehttp.Option
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+		}
+		v, err := otlptracehttp.New(ctx, opts...)
+		if err != nil {
+			return fmt.Errorf("failed to create otlp trace exporter: %w", err)
+		}
+		exporter = v
+	case api.TraceExporterFile:
+		file := cfg.File
+		if file == "" {
+			file = filepath.Join("var", "log", "trace", fmt.Sprintf("session_%s.jsonl", session))
+		}
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(workspace, file)
+		}
+		v, err := NewFileExporter(file)
+		if err != nil {
+			return err
+		}
+		exporter = v
+	default:
+		return fmt.Errorf("invalid trace exporter: %q. supported: otlp, file", cfg.Exporter)
+	}
+
+	res := resource.NewSchemaless(
+		attribute.String("service.name", ServiceName),
+		attribute.Int("process.pid", os.Getpid()),
+		AttrSession.String(string(session)),
+	)
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tp)
+
+	mu.Lock()
+	provider = tp
+	mu.Unlock()
+	return nil
+}
+
+// Shutdown flushes the spans to the exporter.
+func Shutdown(ctx context.Context) error {
+	mu.Lock()
+	tp := provider
+	provider = nil
+	mu.Unlock()
+
+	if tp == nil {
+		return nil
+	}
+	return tp.Shutdown(ctx)
+}
+
+// Start starts a span as the child of the span in ctx if any.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(scopeName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records the error if any and ends the span.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,97 @@
+package telemetry
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/qiangli/ai/swarm/api"
+)
+
+func TestFileExporter(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.TODO()
+	if err := Setup(ctx, &api.TraceConfig{Exporter: api.TraceExporterFile}, dir, "s1"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, root := Start(ctx, "swarm.exec")
+	cctx, agent := Start(ctx, "agent ops/deploy", AttrAgent.String("ops/deploy"))
+	_, llm := Start(cctx, "llm ops/deploy", AttrLlmInputTokens.Int64(42))
+	End(llm, nil)
+	End(agent, fmt.Errorf("boom"))
+	End(root, nil)
+
+	if err := Shutdown(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(filepath.Join(dir, "var", "log", "trace", "session_s1.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	spans := make(map[string]map[string]any)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var data struct {
+			ResourceSpans []struct {
+				Resource struct {
+					Attributes []map[string]any `json:"attributes"`
+				} `json:"resource"`
+				ScopeSpans []struct {
+					Spans []map[string]any `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &data); err != nil {
+			t.Fatal(err)
+		}
+		for _, rs := range data.ResourceSpans {
+			if len(rs.Resource.Attributes) == 0 {
+				t.Errorf("expected resource attributes")
+			}
+			for _, ss := range rs.ScopeSpans {
+				for _, s := range ss.Spans {
+					spans[s["name"].(string)] = s
+				}
+			}
+		}
+	}
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans, got %v", len(spans))
+	}
+
+	root2, agent2, llm2 := spans["swarm.exec"], spans["agent ops/deploy"], spans["llm ops/deploy"]
+	if len(root2["traceId"].(string)) != 32 || len(root2["spanId"].(string)) != 16 {
+		t.Errorf("expected hex ids: %v", root2)
+	}
+	if _, ok := root2["parentSpanId"]; ok {
+		t.Errorf("expected root span without parent")
+	}
+	if agent2["parentSpanId"] != root2["spanId"] || llm2["parentSpanId"] != agent2["spanId"] {
+		t.Errorf("unexpected lineage: %v %v %v", root2["spanId"], agent2["parentSpanId"], llm2["parentSpanId"])
+	}
+	status, _ := agent2["status"].(map[string]any)
+	if status["code"] != float64(2) || status["message"] != "boom" {
+		t.Errorf("expected error status: %v", agent2["status"])
+	}
+	attrs, _ := json.Marshal(llm2["attributes"])
+	if string(attrs) != `[{"key":"gen_ai.usage.input_tokens","value":{"intValue":"42"}}]` {
+		t.Errorf("unexpected attributes: %s", attrs)
+	}
+}
+
+func TestSetup(t *testing.T) {
+	if err := Setup(context.TODO(), nil, "", "s1"); err != nil {
+		t.Errorf("expected disabled tracing: %v", err)
+	}
+	if err := Setup(context.TODO(), &api.TraceConfig{Exporter: "zipkin"}, "", "s1"); err == nil {
+		t.Errorf("expected invalid exporter")
+	}
+}
@@ -5,22 +5,27 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"math/rand"
 	"os"
 	"sort"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/qiangli/ai/swarm/api"
 	"github.com/qiangli/ai/swarm/atm"
 	"github.com/qiangli/ai/swarm/atm/conf"
 	"github.com/qiangli/ai/swarm/llm/adapter"
+	"github.com/qiangli/ai/swarm/llm/ollama"
+	"github.com/qiangli/ai/swarm/llm/selector"
 	"github.com/qiangli/ai/swarm/log"
+	"github.com/qiangli/ai/swarm/telemetry"
 	"github.com/qiangli/ai/swarm/util"
 
 	"path/filepath"
+	"slices"
 )
 
 type AIKit struct {
@@ -104,6 +109,13 @@ func (r *AIKit) getAdapter(agent *api.Agent, args map[string]any) (api.LLMAdapte
 }
 
 func (r *AIKit) CallLlm(ctx context.Context, vars *api.Vars, agent *api.Agent, tf *api.ToolFunc, args api.ArgMap) (any, error) {
+	ctx, span := telemetry.Start(ctx, "llm")
+	result, err := r.callLlm(ctx, vars, agent, tf, args)
+	telemetry.End(span, err)
+	return result, err
+}
+
+func (r *AIKit) callLlm(ctx context.Context, vars *api.Vars, agent *api.Agent, tf *api.ToolFunc, args api.ArgMap) (any, error) {
 	var owner = r.vars.User.Email
 	var sessionID = r.vars.SessionID
 
@@ -111,6 +123,9 @@ func (r *AIKit) CallLlm(ctx context.Context, vars *api.Vars, agent *api.Agent, t
 	if v, err := r.checkAndCreate(ctx, vars, agent, tf, args); err == nil {
 		agent = v
 	}
+	span := trace.SpanFromContext(ctx)
+	span.SetName("llm " + string(api.NewPackname(agent.Pack, agent.Name)))
+	span.SetAttributes(telemetry.AttrAgent.String(string(api.NewPackname(agent.Pack, agent.Name))))
 
 	// query is required
 	query, _ := api.GetStrProp("query", args)
@@ -283,7 +298,8 @@ func (r *AIKit) CallLlm(ctx context.Context, vars *api.Vars, agent *api.Agent, t
 
 	// 2. Context Messages
 	// context/history, skip system role and old context message
-	var messages = api.ToMessages(args["history"])
+	// the conversation is moved to the history by ai:build_context if run
+	var messages = slices.Concat(api.ToMessages(args["history"]), args.Conversation())
 	for _, msg := range messages {
 		if msg.Role == api.RoleSystem || msg.Context {
 			continue
@@ -314,16 +330,16 @@ func (r *AIKit) CallLlm(ctx context.Context, vars *api.Vars, agent *api.Agent, t
 	agent.Models = models
 
 	var result *api.Result
+	var transcript []*api.Message
 	var respErr error
 	var sender string
 
-	//
-	// Seed the random number generator
-	rd := rand.New(rand.NewSource(time.Now().UnixNano()))
-	// Shuffle the models slice
-	rd.Shuffle(len(models), func(i, j int) {
-		models[i], models[j] = models[j], models[i]
-	})
+	// try the models in the order of the selection strategy
+	// skipping providers known to be failing
+	models, err := selector.Order(args.GetString("model_strategy"), models, r.vars.Health)
+	if err != nil {
+		return nil, err
+	}
 
 	// collect all errors
 	var errors []string
@@ -333,11 +349,39 @@ func (r *AIKit) CallLlm(ctx context.Context, vars *api.Vars, agent *api.Agent, t
 
 		sender = model.Provider
 		//
-		result, respErr = r.LlmAdapter(ctx, vars, agent, tf, args)
-		if respErr == nil && result != nil {
+		var resp *api.Response
+		resp, respErr = r.llmAdapter(ctx, vars, agent, tf, args)
+		if respErr == nil && resp.Result != nil {
+			result = resp.Result
+			transcript = resp.Messages
+			// recorded with the call
+			if entry := api.GetCallLogEntry(ctx); entry != nil && resp.Cache != "" {
+				entry.Cache = resp.Cache
+			}
+			if r.vars.Health != nil {
+				r.vars.Health.Success(model)
+			}
+			result.Provider = model.Provider
+			result.Model = model.Model
+			span.SetAttributes(
+				telemetry.AttrLlmSystem.String(model.Provider),
+				telemetry.AttrLlmModel.String(model.Model),
+				telemetry.AttrLlmInputTokens.Int64(result.InputTokens),
+				telemetry.AttrLlmOutputTokens.Int64(result.OutputTokens),
+			)
 			break
 		}
-		errors = append(errors, respErr.Error())
+		if respErr != nil {
+			if r.vars.Health != nil {
+				r.vars.Health.Failure(model, respErr)
+			}
+			errors = append(errors, respErr.Error())
+			span.AddEvent("model failed", trace.WithAttributes(
+				telemetry.AttrLlmSystem.String(model.Provider),
+				telemetry.AttrLlmModel.String(model.Model),
+				attribute.String("error", respErr.Error()),
+			))
+		}
 	}
 
 	// report all errors if the last error is not nil/none of the attempts was successful
@@ -352,6 +396,10 @@ func (r *AIKit) CallLlm(ctx context.Context, vars *api.Vars, agent *api.Agent, t
 		}
 	}
 	args["result"] = result.Value
+	// validated structured output
+	if result.Data != nil {
+		args["data"] = result.Data
+	}
 
 	// NOTE
 	// keep query of the current agent but clear the prompt (and history???)
@@ -366,10 +414,19 @@ func (r *AIKit) CallLlm(ctx context.Context, vars *api.Vars, agent *api.Agent, t
 			return nil, fmt.Errorf("agent is required for transfer")
 		}
 		args["agent"] = result.NextAgent
+		span.AddEvent("transfer", trace.WithAttributes(telemetry.AttrAgent.String(result.NextAgent)))
 		return r.SpawnAgent(ctx, vars, agent, tf, args)
 	}
 
-	// save assistant response message
+	// save the tool calls and results followed by the assistant response message
+	for _, v := range transcript {
+		v.Session = sessionID
+		v.Agent = packname
+		if v.Role == api.RoleAssistant {
+			v.Sender = sender
+		}
+		history = append(history, v)
+	}
 	message := api.Message{
 		ID:      uuid.NewString(),
 		Session: sessionID,
@@ -393,20 +450,15 @@ func (r *AIKit) CallLlm(ctx context.Context, vars *api.Vars, agent *api.Agent, t
 }
 
 func (r *AIKit) LlmAdapter(ctx context.Context, vars *api.Vars, agent *api.Agent, tf *api.ToolFunc, args api.ArgMap) (*api.Result, error) {
-	const prompt = `
-	The original request exceeds the maximum input size (%v) and has been rewritten as follows:
-	
-	## Instruction
-	Review the content of the file: %q
-
-	## Context History
-	Refer to the background information in the file: %q
-
-	## Query
-	Follow the specific request or task outlined in the file: %q
+	resp, err := r.llmAdapter(ctx, vars, agent, tf, args)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Result, nil
+}
 
-	Please carefully review the contents of these files and provide a detailed response or solution based on the instructions and query provided.
-	`
+// llmAdapter calls the LLM and returns the result along with the tool call and tool result messages.
+func (r *AIKit) llmAdapter(ctx context.Context, vars *api.Vars, agent *api.Agent, tf *api.ToolFunc, args api.ArgMap) (*api.Response, error) {
 	var owner = r.vars.User.Email
 
 	getToken := func(model *api.Model) (func() string, error) {
@@ -417,7 +469,7 @@ func (r *AIKit) LlmAdapter(ctx context.Context, vars *api.Vars, agent *api.Agent
 		}
 
 		ak, err := r.vars.Secrets.Get(owner, apiKey)
-		if err != nil {
+		if err != nil && !adapter.IsKeyOptional(model.Provider) {
 			return nil, err
 		}
 		token := func() string {
@@ -441,70 +493,255 @@ func (r *AIKit) LlmAdapter(ctx context.Context, vars *api.Vars, agent *api.Agent
 		req.SetMaxTurns(api.DefaultMaxTurns)
 	}
 
-	// https://platform.openai.com/docs/models/gpt-5-mini
-	maxInputSize, _ := api.GetIntProp("max_input_size", args)
-	if maxInputSize <= 0 {
-		maxInputSize = 272000*4 - 1000
+	req.Query = agent.Query
+	req.Prompt = agent.Prompt
+	req.Messages = agent.History
+
+	//
+	req.Arguments = args
+
+	req.Tools = agent.Tools
+	req.Runner = agent.Runner
+	if req.Runner != nil {
+		req.Runner = &unstreamedRunner{req.Runner}
 	}
-	total := len(agent.Prompt) + len(agent.Query)
-	for _, v := range agent.History {
-		total += len(v.Content)
+
+	req.Model = agent.Model
+
+	token, err := getToken(agent.Model)
+	if err != nil {
+		return nil, err
 	}
-	if total < maxInputSize {
-		req.Query = agent.Query
-		req.Prompt = agent.Prompt
-		req.Messages = agent.History
-	} else {
-		aid := api.NewPackname(agent.Pack, agent.Name).ID()
-		rid := uuid.NewString()
-		dir := filepath.Join(vars.Roots.Workspace.Path, "oversize", aid, rid)
-		if err := os.MkdirAll(dir, 0700); err != nil {
+	req.Token = token
+
+	// fit the messages to the context window of the model before each call
+	win, err := r.contextWindow(ctx, vars, agent, args)
+	if err != nil {
+		return nil, err
+	}
+	req.Window = win
+
+	// structured output
+	// instruct the model for adapters/providers without native support
+	schema, err := api.OutputSchema(args)
+	if err != nil {
+		return nil, err
+	}
+	if schema != nil {
+		_, isChat := llmAdapter.(*adapter.ChatAdapter)
+		if !isChat || !adapter.HasNativeOutputSchema(agent.Model.Provider, len(req.Tools) > 0) {
+			req.Messages = append([]*api.Message{{
+				Role:    api.RoleSystem,
+				Content: api.SchemaInstruction(schema),
+			}}, req.Messages...)
+		}
+	}
+
+	// response cache, opt-in with cache: true
+	var cacheKey string
+	if useCache, _ := api.GetBoolProp("cache", args); useCache && r.vars.Cache != nil {
+		key, err := req.CacheKey()
+		if err != nil {
 			return nil, err
 		}
-		insfile := filepath.Join(dir, "instruction.txt")
-		ctxfile := filepath.Join(dir, "context.json")
-		qfile := filepath.Join(dir, "query.txt")
+		ttl := cacheTTL(args)
+		if v, err := r.vars.Cache.Get(key, ttl); err == nil {
+			log.GetLogger(ctx).Infof("✔ cache hit %s\n", key)
+			return &api.Response{Result: v, Cache: "hit"}, nil
+		}
+		log.GetLogger(ctx).Debugf("cache miss %s\n", key)
+		cacheKey = key
+	}
 
-		os.WriteFile(insfile, []byte(agent.Prompt), 0600)
-		var history []byte
-		if len(agent.History) == 0 {
-			history = []byte("no context")
+	// stream if requested by the caller (console/serve) and supported by the adapter
+	// opt out per agent/call with stream: false
+	var stream = true
+	if _, found := args["stream"]; found {
+		stream, _ = api.GetBoolProp("stream", args)
+	}
+	var h api.StreamHandler
+	if stream {
+		h = api.GetStreamHandler(ctx)
+	}
+	send := func(h api.StreamHandler) (*api.Response, error) {
+		// cache hits are free, budgets apply to actual calls only including retries
+		if err := r.checkBudget(args); err != nil {
+			return nil, err
+		}
+		var resp *api.Response
+		if sa, ok := llmAdapter.(api.LLMStreamAdapter); ok && h != nil {
+			resp, err = sa.Stream(ctx, req, h)
 		} else {
-			v, err := json.Marshal(history)
-			if err != nil {
+			resp, err = llmAdapter.Call(ctx, req)
+		}
+		if err != nil {
+			return nil, err
+		}
+		r.recordUsage(ctx, agent, resp.Result)
+		return resp, nil
+	}
+
+	resp, err := send(h)
+	if err != nil {
+		return nil, err
+	}
+
+	// validate the structured output, ask again with the errors if invalid
+	if schema != nil && resp.Result != nil && resp.Result.State != api.StateTransfer {
+		retries := api.DefaultOutputRetries
+		if _, found := args["output_retries"]; found {
+			retries, _ = api.GetIntProp("output_retries", args)
+		}
+		for i := 0; ; i++ {
+			if resp.Result == nil {
+				return nil, fmt.Errorf("No response")
+			}
+			data, verr := api.ParseOutput(schema, resp.Result.Value)
+			if verr == nil {
+				resp.Result.Data = data
+				break
+			}
+			if i >= retries {
+				return nil, fmt.Errorf("Invalid structured output after %v attempts: %v", i+1, verr)
+			}
+			log.GetLogger(ctx).Infof("✘ %v, retrying %v/%v\n", verr, i+1, retries)
+
+			req.Messages = append(slices.Clone(req.Messages),
+				&api.Message{
+					Role:    api.RoleAssistant,
+					Content: resp.Result.Value,
+				},
+				&api.Message{
+					Role:    api.RoleUser,
+					Content: fmt.Sprintf("Your response is invalid: %v\n\n%s", verr, api.SchemaInstruction(schema)),
+				},
+			)
+			// retries are not streamed
+			if resp, err = send(nil); err != nil {
 				return nil, err
 			}
-			history = v
 		}
-		os.WriteFile(insfile, history, 0600)
-		os.WriteFile(insfile, []byte(agent.Query), 0600)
+	}
 
-		// remove instruction/context and rewrite the query
-		req.Prompt = ""
-		req.Messages = nil
-		req.Query = fmt.Sprintf(prompt, maxInputSize, insfile, ctxfile, qfile)
+	// transfers depend on the agent state, only final answers are cached
+	if cacheKey != "" {
+		resp.Cache = "miss"
 	}
+	if cacheKey != "" && resp.Result != nil && resp.Result.State != api.StateTransfer {
+		if err := r.vars.Cache.Put(cacheKey, resp.Result); err != nil {
+			log.GetLogger(ctx).Errorf("failed to cache response: %v\n", err)
+		}
+	}
+	return resp, nil
+}
 
-	//
-	req.Arguments = args
+// unstreamedRunner runs the tool calls of the LLM without the stream handler
+// so that the outputs of the agents and tools called are not streamed as the reply.
+type unstreamedRunner struct {
+	runner api.ActionRunner
+}
 
-	req.Tools = agent.Tools
-	req.Runner = agent.Runner
+func (r *unstreamedRunner) Run(ctx context.Context, tid string, args map[string]any) (any, error) {
+	return r.runner.Run(api.WithoutStreamHandler(ctx), tid, args)
+}
 
-	req.Model = agent.Model
+// recordUsage adds the tokens and estimated cost of the call to the ledger.
+func (r *AIKit) recordUsage(ctx context.Context, agent *api.Agent, result *api.Result) {
+	if r.vars.Usage == nil || result == nil || agent.Model == nil {
+		return
+	}
+	model := agent.Model
+	entry := &api.UsageEntry{
+		Time:    time.Now(),
+		Session: string(r.vars.SessionID),
+		Agent:   string(api.NewPackname(agent.Pack, agent.Name)),
+		//
+		Provider: model.Provider,
+		Model:    model.Model,
+		//
+		InputTokens:  result.InputTokens,
+		OutputTokens: result.OutputTokens,
+		TotalTokens:  result.TotalTokens,
+		Cost:         model.Cost(result.InputTokens, result.OutputTokens),
+	}
+	if err := r.vars.Usage.Record(entry); err != nil {
+		log.GetLogger(ctx).Errorf("failed to record usage: %v\n", err)
+	}
+}
 
-	token, err := getToken(agent.Model)
-	if err != nil {
-		return nil, err
+// checkBudget refuses the call once the estimated cost of the session or today
+// has reached the budget (USD) set with session_budget/daily_budget.
+func (r *AIKit) checkBudget(args api.ArgMap) error {
+	if r.vars.Usage == nil {
+		return nil
 	}
-	req.Token = token
+	check := func(name string, filter *api.UsageFilter) error {
+		budget, err := api.GetFloatProp(name, args)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %v", name, err)
+		}
+		if budget <= 0 {
+			return nil
+		}
+		entries, err := r.vars.Usage.Query(filter)
+		if err != nil {
+			return err
+		}
+		var spent float64
+		for _, e := range entries {
+			spent += e.Cost
+		}
+		if spent >= budget {
+			return fmt.Errorf("Budget exceeded: %s $%.4f spent of $%.4f", name, spent, budget)
+		}
+		return nil
+	}
+	if err := check("session_budget", &api.UsageFilter{Session: string(r.vars.SessionID)}); err != nil {
+		return err
+	}
+	return check("daily_budget", &api.UsageFilter{Since: today()})
+}
 
-	var resp *api.Response
-	resp, err = llmAdapter.Call(ctx, req)
+func today() time.Time {
+	y, m, d := time.Now().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+}
+
+// Usage summarizes the token usage and estimated cost recorded in the ledger.
+// scope: today (default), session or agent
+func (r *AIKit) Usage(ctx context.Context, vars *api.Vars, parent *api.Agent, tf *api.ToolFunc, args map[string]any) (string, error) {
+	if r.vars.Usage == nil {
+		return "", fmt.Errorf("usage ledger not available")
+	}
+	scope, _ := api.GetStrProp("scope", args)
+	var filter = &api.UsageFilter{}
+	switch scope {
+	case "", "today":
+		scope = "today"
+		filter.Since = today()
+	case "session":
+		session, _ := api.GetStrProp("session", args)
+		filter.Session = nvl(session, string(r.vars.SessionID))
+	case "agent":
+		name, _ := api.GetStrProp("agent", args)
+		if name == "" {
+			return "", fmt.Errorf("agent is required for scope agent")
+		}
+		filter.Agent = string(api.Packname(name).Clean())
+	case "all":
+	default:
+		return "", fmt.Errorf("invalid scope: %q. supported: today, session, agent, all", scope)
+	}
+
+	entries, err := r.vars.Usage.Query(filter)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	return resp.Result, nil
+	if len(entries) == 0 {
+		return fmt.Sprintf("No usage recorded (%s)\n", scope), nil
+	}
+	totals := api.SummarizeUsage(entries)
+	return fmt.Sprintf("Usage (%s):\n\n%s", scope, api.FormatUsage(totals)), nil
 }
 
 func (r *AIKit) ListAgents(ctx context.Context, vars *api.Vars, parent *api.Agent, tf *api.ToolFunc, args map[string]any) (string, error) {
@@ -649,7 +886,23 @@ func (r *AIKit) TransferAgent(_ context.Context, _ *api.Vars, _ *api.Agent, _ *a
 // agent is required.
 // actions defatult to the following if not set:
 // entrypoint: "ai:new_agent", "ai:build_query", "ai:build_prompt", "ai:build_context", "ai:call_llm"
+// the run is traced as a child span of the parent agent.
 func (r *AIKit) SpawnAgent(ctx context.Context, vars *api.Vars, parent *api.Agent, tf *api.ToolFunc, args api.ArgMap) (*api.Result, error) {
+	var attrs []attribute.KeyValue
+	name := args.GetString("agent")
+	if parent != nil {
+		attrs = append(attrs, telemetry.AttrParentAgent.String(string(api.NewPackname(parent.Pack, parent.Name))))
+		if name == "self" {
+			name = string(api.NewPackname(parent.Pack, parent.Name))
+		}
+	}
+	ctx, span := telemetry.Start(ctx, "agent "+name, append(attrs, telemetry.AttrAgent.String(name))...)
+	result, err := r.spawnAgent(ctx, vars, parent, tf, args)
+	telemetry.End(span, err)
+	return result, err
+}
+
+func (r *AIKit) spawnAgent(ctx context.Context, vars *api.Vars, parent *api.Agent, tf *api.ToolFunc, args api.ArgMap) (*api.Result, error) {
 	packsub, err := api.GetStrProp("agent", args)
 	if err != nil {
 		return nil, err
@@ -880,9 +1133,53 @@ func (r *AIKit) ListModels(ctx context.Context, vars *api.Vars, _ *api.Agent, tf
 	}
 	var v = fmt.Sprintf("Available models: %v\n\n%s\n", count, list)
 
+	// models served by self-hosted providers
+	if remote, _ := api.GetBoolProp("remote", args); remote {
+		v += r.listServedModels(ctx, user)
+	}
+
 	return v, nil
 }
 
+// listServedModels queries the model list from the base url of each self-hosted provider.
+func (r *AIKit) listServedModels(ctx context.Context, user string) string {
+	models, _ := r.vars.Assets.ListModels(user)
+
+	seen := make(map[string]bool)
+	var list []string
+	for _, tc := range models {
+		for _, mc := range tc.Models {
+			if !ollama.IsProvider(mc.Provider) {
+				continue
+			}
+			m := &api.Model{
+				Provider: mc.Provider,
+				BaseUrl:  mc.BaseUrl,
+				ApiKey:   mc.ApiKey,
+			}
+			baseUrl := ollama.BaseUrl(m)
+			if seen[baseUrl] {
+				continue
+			}
+			seen[baseUrl] = true
+
+			var apiKey = nvl(m.ApiKey, m.Provider)
+			token, _ := r.vars.Secrets.Get(user, apiKey)
+			ids, err := ollama.ListModels(ctx, m, token)
+			if err != nil {
+				list = append(list, fmt.Sprintf("%s - %s\n    error: %v\n", m.Provider, baseUrl, err))
+				continue
+			}
+			list = append(list, fmt.Sprintf("%s - %s\n    %s\n", m.Provider, baseUrl, strings.Join(ids, "\n    ")))
+		}
+	}
+	if len(list) == 0 {
+		return ""
+	}
+	sort.Strings(list)
+	return fmt.Sprintf("Served models:\n\n%s\n", strings.Join(list, "\n"))
+}
+
 func (r *AIKit) ListMessages(ctx context.Context, vars *api.Vars, _ *api.Agent, _ *api.ToolFunc, args map[string]any) (string, error) {
 	maxHistory, err := api.GetIntProp("max_history", args)
 	if err != nil || maxHistory <= 0 {
@@ -907,6 +1204,12 @@ func (r *AIKit) ListMessages(ctx context.Context, vars *api.Vars, _ *api.Agent,
 		Offset:     offset,
 		Roles:      roles,
 	}
+	// scoped to the session chosen with --session unless another is requested
+	if v := args["session"]; v != nil && api.ToString(v) != "" {
+		option.Session = api.SessionID(api.ToString(v))
+	} else if r.vars.NamedSession {
+		option.Session = r.vars.SessionID
+	}
 	format, err := api.GetStrProp("format", args)
 
 	history, count, err := loadHistory(r.vars.History, option)
@@ -941,6 +1244,70 @@ func (r *AIKit) ListMessages(ctx context.Context, vars *api.Vars, _ *api.Agent,
 	return v, nil
 }
 
+// SearchMessages recalls earlier conversations of all sessions by keywords unless a session is requested.
+func (r *AIKit) SearchMessages(ctx context.Context, vars *api.Vars, _ *api.Agent, _ *api.ToolFunc, args map[string]any) (string, error) {
+	store, ok := r.vars.History.(api.SearchStore)
+	if !ok {
+		return "", fmt.Errorf("search is not supported by the memory store")
+	}
+	query, err := api.GetStrProp("query", args)
+	if err != nil || strings.TrimSpace(query) == "" {
+		return "", fmt.Errorf("query is required")
+	}
+	limit, err := api.GetIntProp("limit", args)
+	if err != nil || limit <= 0 {
+		limit = 10
+	}
+	roles, err := api.GetArrayProp("roles", args)
+	if err != nil || len(roles) == 0 {
+		roles = []string{"assistant", "user"}
+	}
+	var option = &api.SearchOption{
+		Query: query,
+		Limit: limit,
+		Roles: roles,
+	}
+	if v := args["session"]; v != nil {
+		option.Session = api.SessionID(api.ToString(v))
+	}
+	if v := args["agent"]; v != nil {
+		option.Agent = api.ToString(v)
+	}
+	format, err := api.GetStrProp("format", args)
+
+	found, err := store.Search(option)
+	if err != nil {
+		return "", fmt.Errorf("Failed to search messages (%s): %v", option, err)
+	}
+	if len(found) == 0 {
+		return fmt.Sprintf("No messages (%s)", option), nil
+	}
+
+	if format == "json" || format == "application/json" {
+		b, err := json.MarshalIndent(found, "", "    ")
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+	var b bytes.Buffer
+	for _, v := range found {
+		b.WriteString("\n* ROLE: ")
+		b.WriteString(v.Role)
+		b.WriteString("\n  CONTENT:\n")
+		b.WriteString(v.Content)
+		b.WriteString("\n  CREATED: ")
+		b.WriteString(fmt.Sprintf("%v", v.Created))
+		b.WriteString("\n  SESSION: ")
+		b.WriteString(string(v.Session))
+		b.WriteString("\n  AGENT: ")
+		b.WriteString(fmt.Sprintf("%v", v.Agent))
+		b.WriteString("\n\n")
+	}
+	var v = fmt.Sprintf("Messages (%s): %v\n\n%s\n", option, len(found), b.String())
+	return v, nil
+}
+
 func (r *AIKit) SaveMessages(_ context.Context, _ *api.Vars, _ *api.Agent, _ *api.ToolFunc, args api.ArgMap) (*api.Result, error) {
 	data, err := api.GetStrProp("messages", args)
 	if err != nil {
@@ -1013,7 +1380,8 @@ func (r *AIKit) getTools(ids []string) ([]*api.ToolFunc, error) {
 				Parameters:  v.Parameters,
 				Body:        v.Body,
 				//
-				Output: v.Output,
+				Output:   v.Output,
+				Approval: v.Approval,
 				//
 				Arguments: v.Arguments,
 				// Provider: nvl(v.Provider, tc.Provider),
@@ -1264,11 +1632,25 @@ func formatList(items []string) string {
 }
 
 func listSkills(workspace string) (string, int, error) {
+	entries, err := findSkills(workspace)
+	if err != nil {
+		return "", 0, err
+	}
+
+	var buf strings.Builder
+	for _, e := range entries {
+		buf.WriteString(fmt.Sprintf("%s - %s\n[%s]\n\n", e.Name, e.Description, e.Path))
+	}
+	return buf.String(), len(entries), nil
+}
+
+// findSkills returns the skills under the roots listed in <workspace>/skills/config.md sorted by name.
+func findSkills(workspace string) ([]SkillEntry, error) {
 	// Read skill roots from: <workspace>/skills/config.md
 	cfgPath := filepath.Join(workspace, "skills", "config.md")
 	b, err := os.ReadFile(cfgPath)
 	if err != nil {
-		return "", 0, err
+		return nil, err
 	}
 	lines := strings.Split(string(b), "\n")
 	var roots []string
@@ -1385,12 +1767,7 @@ func listSkills(workspace string) (string, int, error) {
 		}
 		return a < b
 	})
-
-	var buf strings.Builder
-	for _, e := range entries {
-		buf.WriteString(fmt.Sprintf("%s - %s\n[%s]\n\n", e.Name, e.Description, e.Path))
-	}
-	return buf.String(), len(entries), nil
+	return entries, nil
 }
 
 func listAgents(assets api.AssetManager, user string) (string, int, error) {
@@ -1469,6 +1846,24 @@ func loadHistory(store api.MemStore, opt *api.MemOption) ([]*api.Message, int, e
 	return history, count, nil
 }
 
+// cacheTTL returns the max age of cached responses from cache_ttl:
+// a duration string or the number of seconds.
+func cacheTTL(args api.ArgMap) time.Duration {
+	v, found := args["cache_ttl"]
+	if !found {
+		return api.DefaultCacheTTL
+	}
+	if s, ok := v.(string); ok {
+		if d, err := time.ParseDuration(s); err == nil {
+			return d
+		}
+	}
+	if n, err := api.GetIntProp("cache_ttl", args); err == nil && n > 0 {
+		return time.Duration(n) * time.Second
+	}
+	return api.DefaultCacheTTL
+}
+
 // lookup model from embedded agents first and then from parents
 func findModel(a *api.Agent, set, level string) *api.Model {
 	if a.Model != nil {
@@ -1491,6 +1886,12 @@ func findModel(a *api.Agent, set, level string) *api.Model {
 						Provider: nvl(v.Provider, ac.Provider),
 						BaseUrl:  nvl(v.BaseUrl, ac.BaseUrl),
 						ApiKey:   nvl(v.ApiKey, ac.ApiKey),
+						//
+						Weight:     v.Weight,
+						InputCost:  v.InputCost,
+						OutputCost: v.OutputCost,
+						//
+						ContextWindow: v.ContextWindow,
 					}
 					return m
 				}
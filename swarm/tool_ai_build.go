@@ -289,6 +289,15 @@ func (r *AIKit) BuildPrompt(ctx context.Context, vars *api.Vars, parent *api.Age
 	var instructions []string
 	addInst := func(a *api.Agent) error {
 		in := a.Instruction
+		// resolved from the MCP server as is
+		if strings.HasPrefix(in, atm.McpResourcePrefix) {
+			content, err := atm.ResolveMcpResource(ctx, vars, in)
+			if err != nil {
+				return err
+			}
+			instructions = append(instructions, content)
+			return nil
+		}
 		if in != "" {
 			data := atm.BuildEffectiveArgs(vars, a, args)
 			content, err := atm.CheckApplyTemplate(a.Template, in, data)
@@ -323,6 +332,15 @@ func (r *AIKit) BuildContext(ctx context.Context, vars *api.Vars, parent *api.Ag
 	var contexts []string
 	addCtx := func(a *api.Agent) error {
 		in := a.Context
+		// resolved from the MCP server as is
+		if strings.HasPrefix(in, atm.McpResourcePrefix) {
+			content, err := atm.ResolveMcpResource(ctx, vars, in)
+			if err != nil {
+				return err
+			}
+			contexts = append(contexts, content)
+			return nil
+		}
 		if in != "" {
 			data := atm.BuildEffectiveArgs(vars, a, args)
 			content, err := atm.CheckApplyTemplate(a.Template, in, data)
@@ -351,6 +369,12 @@ func (r *AIKit) BuildContext(ctx context.Context, vars *api.Vars, parent *api.Ag
 		}
 	}
 
+	// prior turns supplied by the client follow the agent context
+	if conv := args.Conversation(); len(conv) > 0 {
+		history = append(history, conv...)
+		delete(args, "conversation")
+	}
+
 	if history == nil {
 		history = []*api.Message{}
 	}
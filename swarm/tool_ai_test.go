@@ -0,0 +1,130 @@
+package swarm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/qiangli/ai/swarm/api"
+	"github.com/qiangli/ai/swarm/llm/adapter"
+)
+
+type stubSecrets struct{}
+
+func (stubSecrets) Get(owner, key string) (string, error) {
+	return "", nil
+}
+
+// streamAdapter replies with the next output and runs a tool on the first call.
+type streamAdapter struct {
+	outputs  []string
+	streamed []string
+	calls    int
+	// stream handler seen by the tool
+	nested api.StreamHandler
+}
+
+func (r *streamAdapter) Call(ctx context.Context, req *api.Request) (*api.Response, error) {
+	return r.Stream(ctx, req, nil)
+}
+
+func (r *streamAdapter) Stream(ctx context.Context, req *api.Request, h api.StreamHandler) (*api.Response, error) {
+	if r.calls == 0 {
+		if _, err := req.Runner.Run(ctx, "agent:swe/coder", nil); err != nil {
+			return nil, err
+		}
+	}
+	out := r.outputs[r.calls]
+	r.calls++
+	if h != nil {
+		r.streamed = append(r.streamed, out)
+		h(&api.StreamEvent{Type: api.StreamEventText, Text: out})
+	}
+	return &api.Response{Result: &api.Result{Value: out}}, nil
+}
+
+type nestedRunner struct {
+	adapter *streamAdapter
+}
+
+func (r *nestedRunner) Run(ctx context.Context, tid string, args map[string]any) (any, error) {
+	r.adapter.nested = api.GetStreamHandler(ctx)
+	return "ok", nil
+}
+
+func TestLlmAdapterStream(t *testing.T) {
+	llm := &streamAdapter{outputs: []string{"not json", `{"answer": "42"}`}}
+	vars := &api.Vars{
+		User:    &api.User{Settings: map[string]any{}},
+		Secrets: stubSecrets{},
+	}
+	agent := &api.Agent{
+		Pack:   "swe",
+		Name:   "coder",
+		Model:  &api.Model{Provider: "test", Model: "test"},
+		Runner: &nestedRunner{adapter: llm},
+	}
+	args := api.ArgMap{
+		"adapter": llm,
+		"output_schema": map[string]any{
+			"type":       "object",
+			"properties": map[string]any{"answer": map[string]any{"type": "string"}},
+			"required":   []any{"answer"},
+		},
+	}
+
+	var events int
+	ctx := api.WithStreamHandler(context.TODO(), func(*api.StreamEvent) { events++ })
+	result, err := NewAIKit(vars).LlmAdapter(ctx, vars, agent, nil, args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Value != `{"answer": "42"}` {
+		t.Errorf("result: %q", result.Value)
+	}
+	// the first attempt only, not the retry or the agents called
+	if len(llm.streamed) != 1 || events != 1 {
+		t.Errorf("streamed: %q (%v events)", llm.streamed, events)
+	}
+	if llm.nested != nil {
+		t.Errorf("stream handler passed to the tool call")
+	}
+}
+
+type mapCache map[string]*api.Result
+
+func (r mapCache) Get(key string, ttl time.Duration) (*api.Result, error) {
+	if v, ok := r[key]; ok {
+		return v, nil
+	}
+	return nil, api.NewNotFoundError(key)
+}
+
+func (r mapCache) Put(key string, result *api.Result) error {
+	r[key] = result
+	return nil
+}
+
+func TestLlmAdapterCache(t *testing.T) {
+	vars := &api.Vars{
+		User:    &api.User{Settings: map[string]any{}},
+		Secrets: stubSecrets{},
+		Cache:   mapCache{},
+	}
+	agent := &api.Agent{
+		Pack:  "swe",
+		Name:  "coder",
+		Model: &api.Model{Provider: "test", Model: "test"},
+		Query: "hi",
+	}
+	args := api.ArgMap{"adapter": &adapter.EchoAdapter{}, "cache": true}
+	for _, want := range []string{"miss", "hit"} {
+		resp, err := NewAIKit(vars).llmAdapter(context.TODO(), vars, agent, nil, args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Cache != want {
+			t.Errorf("cache: %q want %q", resp.Cache, want)
+		}
+	}
+}
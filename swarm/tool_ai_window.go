@@ -0,0 +1,124 @@
+package swarm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/qiangli/ai/swarm/api"
+	"github.com/qiangli/ai/swarm/atm/conf"
+	"github.com/qiangli/ai/swarm/llm/window"
+)
+
+// upper bound of the tokens reserved for the response
+const maxOutputReserve = 16384
+
+const summaryPrompt = `You compress the earlier part of a conversation between a user, an AI assistant and its tools so the conversation can continue within a limited context window.
+
+Write a concise summary preserving:
+- the goals, requests and constraints stated by the user
+- decisions made, facts learned and results of tool calls that are still relevant
+- names of files, commands, identifiers and values needed to continue
+- open questions and unfinished work
+
+Do not add commentary. Reply with the summary only.`
+
+// contextWindow returns the context window management for the agent:
+// context_strategy: drop | summarize | pin (default) | none
+// context_window: max tokens overriding the context_window of the model
+// context_model: model set/level for the summarize strategy, level L1 of the agent model set by default
+func (r *AIKit) contextWindow(ctx context.Context, vars *api.Vars, agent *api.Agent, args api.ArgMap) (api.ContextWindow, error) {
+	limit := args.GetInt("context_window")
+	if limit <= 0 && agent.Model != nil {
+		limit = agent.Model.ContextWindow
+	}
+	if limit <= 0 {
+		limit = window.DefaultContextWindow
+	}
+	// reserve room for the tool definitions and the response
+	limit -= window.EstimateTools(agent.Tools) + min(limit/8, maxOutputReserve)
+
+	strategy := args.GetString("context_strategy")
+	var summarize window.Summarizer
+	if strategy == window.StrategySummarize {
+		summarize = func(ctx context.Context, messages []*api.Message) (string, error) {
+			return r.summarizeHistory(ctx, vars, agent, args, messages)
+		}
+	}
+	win, err := window.New(strategy, limit, summarize)
+	if err != nil {
+		return nil, err
+	}
+	return win, nil
+}
+
+// summarizeHistory summarizes the messages with the context model.
+func (r *AIKit) summarizeHistory(ctx context.Context, vars *api.Vars, agent *api.Agent, args api.ArgMap, messages []*api.Message) (string, error) {
+	model, err := r.contextModel(agent, args.GetString("context_model"))
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	for _, v := range messages {
+		fmt.Fprintf(&sb, "[%s]", v.Role)
+		if v.Role == api.RoleTool && v.Sender != "" {
+			fmt.Fprintf(&sb, " %s", v.Sender)
+		}
+		fmt.Fprintf(&sb, "\n%s\n", v.Content)
+		for _, tc := range v.ToolCalls {
+			fmt.Fprintf(&sb, "→ %s %v\n", tc.Command, tc.Arguments)
+		}
+		sb.WriteString("\n")
+	}
+
+	// summarize with a copy of the agent without tools
+	a := *agent
+	a.Model = model
+	a.Tools = nil
+	a.Prompt = summaryPrompt
+	a.Query = sb.String()
+	a.History = []*api.Message{
+		{Role: api.RoleSystem, Content: a.Prompt},
+		{Role: api.RoleUser, Content: a.Query},
+	}
+	argm := api.ArgMap{
+		// the older history is summarized again by later runs of the session
+		"cache": true,
+		// the summary is not part of the reply on the console or to serve clients
+		"stream": false,
+	}
+	result, err := r.LlmAdapter(ctx, vars, &a, nil, argm)
+	if err != nil {
+		return "", err
+	}
+	if result == nil || strings.TrimSpace(result.Value) == "" {
+		return "", fmt.Errorf("empty summary")
+	}
+	return result.Value, nil
+}
+
+// contextModel resolves the model alias, level L1 of the agent model set if empty.
+// The agent model is used if no L1 model is available.
+func (r *AIKit) contextModel(agent *api.Agent, alias string) (*api.Model, error) {
+	var set, level string
+	if alias != "" {
+		set, level = api.Setlevel(alias).Decode()
+	} else {
+		if agent.Model == nil {
+			return nil, fmt.Errorf("no model for summarizing the history")
+		}
+		set, level = agent.Model.Set, api.L1
+	}
+	if v := findModel(agent, set, level); v != nil {
+		return v, nil
+	}
+	v, err := conf.LoadModel(r.vars.User.Email, set, level, r.vars.Assets)
+	if err != nil {
+		if alias == "" {
+			return agent.Model, nil
+		}
+		return nil, err
+	}
+	return v, nil
+}
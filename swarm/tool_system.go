@@ -37,6 +37,7 @@ func NewToolSystem(base string) (api.ToolSystem, error) {
 	ts.AddKit(api.ToolTypeWeb, atm.NewWebKit())
 	ts.AddKit(api.ToolTypeSystem, atm.NewSystemKit())
 	ts.AddKit(api.ToolTypeMcp, atm.NewMcpKit())
+	ts.AddKit(api.ToolTypeOpenAPI, atm.NewOpenAPIKit())
 	// ts.AddKit(api.ToolTypeFaas, atm.NewFaasKit())
 
 	return ts, nil
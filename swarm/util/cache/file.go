@@ -0,0 +1,142 @@
+package cache
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/qiangli/ai/swarm/api"
+)
+
+// default limits
+const (
+	DefaultMaxSize    = 64 * 1024 * 1024
+	DefaultMaxEntries = 1000
+)
+
+// FileCache stores LLM responses as one json file per request key
+// under the workspace.
+type FileCache struct {
+	base string
+
+	// size limits, the oldest entries are evicted first
+	maxSize    int64
+	maxEntries int
+
+	mu sync.Mutex
+}
+
+func NewFileCache(workspace string, maxSize int64, maxEntries int) (api.ResponseCache, error) {
+	base := filepath.Join(workspace, "cache", "llm")
+	if err := os.MkdirAll(base, 0755); err != nil {
+		return nil, err
+	}
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+	if maxEntries <= 0 {
+		maxEntries = DefaultMaxEntries
+	}
+	return &FileCache{
+		base:       base,
+		maxSize:    maxSize,
+		maxEntries: maxEntries,
+	}, nil
+}
+
+func (r *FileCache) path(key string) string {
+	return filepath.Join(r.base, key+".json")
+}
+
+func (r *FileCache) Get(key string, ttl time.Duration) (*api.Result, error) {
+	if ttl <= 0 {
+		ttl = api.DefaultCacheTTL
+	}
+	p := r.path(key)
+	fi, err := os.Stat(p)
+	if err != nil {
+		return nil, api.NewNotFoundError("cache key: " + key)
+	}
+	if time.Since(fi.ModTime()) > ttl {
+		os.Remove(p)
+		return nil, api.NewNotFoundError("cache key: " + key)
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	var result api.Result
+	if err := json.Unmarshal(data, &result); err != nil {
+		// corrupted entry
+		os.Remove(p)
+		return nil, api.NewNotFoundError("cache key: " + key)
+	}
+	return &result, nil
+}
+
+func (r *FileCache) Put(key string, result *api.Result) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(r.path(key), data, 0600); err != nil {
+		return err
+	}
+	return r.prune()
+}
+
+// prune evicts the oldest entries until the cache is within the size limits.
+func (r *FileCache) prune() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entries, err := os.ReadDir(r.base)
+	if err != nil {
+		return err
+	}
+	type fileInfo struct {
+		name string
+		size int64
+		mod  time.Time
+	}
+	var files []fileInfo
+	var total int64
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, fileInfo{
+			name: e.Name(),
+			size: info.Size(),
+			mod:  info.ModTime(),
+		})
+		total += info.Size()
+	}
+	if total <= r.maxSize && len(files) <= r.maxEntries {
+		return nil
+	}
+
+	// oldest first
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].mod.Before(files[j].mod)
+	})
+	count := len(files)
+	for _, f := range files {
+		if total <= r.maxSize && count <= r.maxEntries {
+			break
+		}
+		if err := os.Remove(filepath.Join(r.base, f.name)); err != nil {
+			continue
+		}
+		total -= f.size
+		count--
+	}
+	return nil
+}
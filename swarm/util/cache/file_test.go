@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/qiangli/ai/swarm/api"
+)
+
+func TestFileCache(t *testing.T) {
+	c, err := NewFileCache(t.TempDir(), 0, 0)
+	if err != nil {
+		t.Fatalf("new cache: %v", err)
+	}
+
+	if _, err := c.Get("k1", time.Hour); err == nil {
+		t.Fatalf("expected miss")
+	}
+	if err := c.Put("k1", &api.Result{Value: "hello", TotalTokens: 3}); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	v, err := c.Get("k1", time.Hour)
+	if err != nil {
+		t.Fatalf("expected hit: %v", err)
+	}
+	if v.Value != "hello" || v.TotalTokens != 3 {
+		t.Errorf("unexpected result: %+v", v)
+	}
+
+	// expired
+	p := c.(*FileCache).path("k1")
+	old := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(p, old, old)
+	if _, err := c.Get("k1", time.Hour); err == nil {
+		t.Errorf("expected expired entry to miss")
+	}
+}
+
+func TestFileCachePrune(t *testing.T) {
+	c, err := NewFileCache(t.TempDir(), 0, 3)
+	if err != nil {
+		t.Fatalf("new cache: %v", err)
+	}
+	fc := c.(*FileCache)
+	for i := range 5 {
+		key := fmt.Sprintf("k%d", i)
+		if err := c.Put(key, &api.Result{Value: key}); err != nil {
+			t.Fatalf("put: %v", err)
+		}
+		// distinct mtime, oldest first
+		mod := time.Now().Add(time.Duration(i-10) * time.Minute)
+		os.Chtimes(fc.path(key), mod, mod)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(fc.base, "*.json"))
+	if len(files) != 3 {
+		t.Fatalf("expected 3 entries, got %v", len(files))
+	}
+	if _, err := c.Get("k0", time.Hour); err == nil {
+		t.Errorf("expected oldest entry to be evicted")
+	}
+	if _, err := c.Get("k4", time.Hour); err != nil {
+		t.Errorf("expected newest entry to be kept")
+	}
+}
@@ -1,9 +1,13 @@
 package conf
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/exec"
 	"strings"
+	"time"
 
 	"github.com/qiangli/ai/swarm/api"
 )
@@ -57,3 +61,63 @@ func (r *secrets) Get(owner, key string) (string, error) {
 	}
 	return "", fmt.Errorf("api key not found: %s", key)
 }
+
+// chainSecrets returns the secret from the first store that has it.
+type chainSecrets []api.SecretStore
+
+// NewSecrets returns the secret store checking the stores in order.
+func NewSecrets(stores ...api.SecretStore) api.SecretStore {
+	return chainSecrets(stores)
+}
+
+func (r chainSecrets) Get(owner, key string) (string, error) {
+	var errs []error
+	for _, s := range r {
+		v, err := s.Get(owner, key)
+		if err == nil && v != "" {
+			return v, nil
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return "", fmt.Errorf("api key not found: %s: %w", key, errors.Join(errs...))
+}
+
+// helperSecrets looks up secrets with an external command similar to git credential helpers:
+//
+//	<helper> get
+//
+// with owner=<owner> and key=<key> lines on stdin, replying value=<secret> on stdout.
+type helperSecrets struct {
+	command []string
+}
+
+func NewHelperSecrets(command string) (api.SecretStore, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("secret helper command is required")
+	}
+	return &helperSecrets{command: args}, nil
+}
+
+func (r *helperSecrets) Get(owner, key string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, r.command[0], append(r.command[1:], "get")...)
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("owner=%s\nkey=%s\n\n", owner, key))
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("secret helper %s: %w", r.command[0], err)
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		// password for git credential helpers
+		k, v, ok := strings.Cut(strings.TrimRight(line, "\r"), "=")
+		if ok && (k == "value" || k == "password") && v != "" {
+			return v, nil
+		}
+	}
+	return "", fmt.Errorf("secret helper %s: no value for %s", r.command[0], key)
+}
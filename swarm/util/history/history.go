@@ -0,0 +1,87 @@
+// Package history imports the conversation history saved as json files
+// by earlier versions into the memory store.
+package history
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/qiangli/ai/swarm/api"
+)
+
+// Import saves the messages of <workspace>/history into the store in the order saved
+// and moves each file to history.imported once saved so that it is imported only once.
+// It returns the number of messages imported.
+func Import(workspace string, store api.MemStore) (int, error) {
+	base := filepath.Join(workspace, "history")
+	entries, err := os.ReadDir(base)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	type historyFile struct {
+		name string
+		mod  time.Time
+	}
+	var files []historyFile
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, historyFile{name: filepath.Join(base, entry.Name()), mod: info.ModTime()})
+	}
+	// file names are in the order saved
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].mod.Equal(files[j].mod) {
+			return files[i].name < files[j].name
+		}
+		return files[i].mod.Before(files[j].mod)
+	})
+
+	done := base + ".imported"
+	if err := os.MkdirAll(done, 0755); err != nil {
+		return 0, err
+	}
+	var count int
+	for _, f := range files {
+		n, err := importFile(f.name, store)
+		if err != nil {
+			return count, err
+		}
+		count += n
+		// moved once saved so that it is not imported again
+		if err := os.Rename(f.name, filepath.Join(done, filepath.Base(f.name))); err != nil {
+			return count, err
+		}
+	}
+	// kept if anything else is left
+	os.Remove(base)
+	return count, nil
+}
+
+// importFile saves the messages of the file, files that can't be read are skipped.
+func importFile(name string, store api.MemStore) (int, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return 0, nil
+	}
+	var msgs []*api.Message
+	if err := json.Unmarshal(data, &msgs); err != nil {
+		return 0, nil
+	}
+	if err := store.Save(msgs); err != nil {
+		return 0, err
+	}
+	return len(msgs), nil
+}
@@ -0,0 +1,81 @@
+package history
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/qiangli/ai/swarm/api"
+)
+
+type memStore struct {
+	messages []*api.Message
+}
+
+func (r *memStore) Save(messages []*api.Message) error {
+	r.messages = append(r.messages, messages...)
+	return nil
+}
+
+func (r *memStore) Load(*api.MemOption) ([]*api.Message, error) {
+	return r.messages, nil
+}
+
+func (r *memStore) Get(string) (*api.Message, error) {
+	return nil, api.NewNotFoundError("")
+}
+
+func TestImport(t *testing.T) {
+	workspace := t.TempDir()
+	base := filepath.Join(workspace, "history")
+	if err := os.MkdirAll(base, 0755); err != nil {
+		t.Fatal(err)
+	}
+	save := func(name string, ids ...string) {
+		var msgs []*api.Message
+		for _, id := range ids {
+			msgs = append(msgs, &api.Message{ID: id, Role: api.RoleUser, Content: "hi"})
+		}
+		data, _ := json.Marshal(msgs)
+		if err := os.WriteFile(filepath.Join(base, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	save("2025-01-01-1.json", "1", "2")
+	save("2025-01-01-2.json", "3")
+	if err := os.WriteFile(filepath.Join(base, "notes.txt"), []byte("skip"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	store := &memStore{}
+	n, err := Import(workspace, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 || store.messages[0].ID != "1" || store.messages[2].ID != "3" {
+		t.Errorf("imported %v: %+v", n, store.messages)
+	}
+
+	// only once
+	if n, err := Import(workspace, store); err != nil || n != 0 {
+		t.Errorf("imported again: %v %v", n, err)
+	}
+	if _, err := os.Stat(base + ".imported"); err != nil {
+		t.Errorf("history not renamed: %v", err)
+	}
+
+	// recreated by an earlier version, history.imported exists already
+	if err := os.MkdirAll(base, 0755); err != nil {
+		t.Fatal(err)
+	}
+	save("2025-01-02-1.json", "4")
+	for range 2 {
+		if _, err := Import(workspace, store); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(store.messages) != 4 || store.messages[3].ID != "4" {
+		t.Errorf("imported: %+v", store.messages)
+	}
+}
@@ -0,0 +1,116 @@
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qiangli/ai/swarm/api"
+)
+
+const dayLayout = "2006-01-02"
+
+// FileLedger appends usage entries as json lines to one file per day
+// under the workspace.
+type FileLedger struct {
+	base string
+
+	mu sync.Mutex
+}
+
+func NewFileLedger(workspace string) (api.UsageLedger, error) {
+	base := filepath.Join(workspace, "usage")
+	if err := os.MkdirAll(base, 0755); err != nil {
+		return nil, err
+	}
+	return &FileLedger{
+		base: base,
+	}, nil
+}
+
+func (r *FileLedger) Record(e *api.UsageEntry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p := filepath.Join(r.base, e.Time.Format(dayLayout)+".jsonl")
+	f, err := os.OpenFile(p, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+func (r *FileLedger) Query(filter *api.UsageFilter) ([]*api.UsageEntry, error) {
+	if filter == nil {
+		filter = &api.UsageFilter{}
+	}
+	files, err := filepath.Glob(filepath.Join(r.base, "*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	// skip the files of days before since
+	var since string
+	if !filter.Since.IsZero() {
+		since = filter.Since.Format(dayLayout)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var list []*api.UsageEntry
+	for _, p := range files {
+		day := strings.TrimSuffix(filepath.Base(p), ".jsonl")
+		if day < since {
+			continue
+		}
+		entries, err := readEntries(p)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if filter.Match(e) {
+				list = append(list, e)
+			}
+		}
+	}
+	return list, nil
+}
+
+func readEntries(p string) ([]*api.UsageEntry, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var list []*api.UsageEntry
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		var e api.UsageEntry
+		// skip partially written lines
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			continue
+		}
+		list = append(list, &e)
+	}
+	return list, sc.Err()
+}
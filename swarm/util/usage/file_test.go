@@ -0,0 +1,64 @@
+package usage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/qiangli/ai/swarm/api"
+)
+
+func TestFileLedger(t *testing.T) {
+	ledger, err := NewFileLedger(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	model := &api.Model{Provider: "openai", Model: "gpt-5-mini", InputCost: 0.25, OutputCost: 2}
+	yesterday := time.Now().Add(-24 * time.Hour)
+	entries := []*api.UsageEntry{
+		{Time: yesterday, Session: "s1", Agent: "ask/ask", Provider: "openai", Model: "gpt-5-mini", InputTokens: 1000, OutputTokens: 100},
+		{Session: "s2", Agent: "ask/ask", Provider: "openai", Model: "gpt-5-mini", InputTokens: 2_000_000, OutputTokens: 500_000, Cost: model.Cost(2_000_000, 500_000)},
+		{Session: "s2", Agent: "git/short", Provider: "gemini", Model: "gemini-2.5-flash", InputTokens: 10},
+	}
+	for _, e := range entries {
+		if err := ledger.Record(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	all, err := ledger.Query(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 {
+		t.Fatalf("all: expected 3 entries, got %d", len(all))
+	}
+
+	y, m, d := time.Now().Date()
+	today, _ := ledger.Query(&api.UsageFilter{Since: time.Date(y, m, d, 0, 0, 0, 0, time.Local)})
+	if len(today) != 2 {
+		t.Errorf("today: expected 2 entries, got %d", len(today))
+	}
+
+	agent, _ := ledger.Query(&api.UsageFilter{Agent: "ask/ask"})
+	if len(agent) != 2 {
+		t.Errorf("agent: expected 2 entries, got %d", len(agent))
+	}
+
+	session, _ := ledger.Query(&api.UsageFilter{Session: "s2"})
+	totals := api.SummarizeUsage(session)
+	if len(totals) != 3 {
+		t.Fatalf("expected 2 models and the total, got %d", len(totals))
+	}
+	if totals[0].Model != "gpt-5-mini" {
+		t.Errorf("expected most expensive model first, got %s", totals[0].Model)
+	}
+	total := totals[len(totals)-1]
+	if total.Calls != 2 || total.InputTokens != 2_000_010 {
+		t.Errorf("unexpected total: %+v", total)
+	}
+	// 2M * 0.25 + 0.5M * 2
+	if total.Cost != 1.5 {
+		t.Errorf("expected cost 1.5, got %v", total.Cost)
+	}
+}
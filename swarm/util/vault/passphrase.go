@@ -0,0 +1,68 @@
+package vault
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+const (
+	EnvPassphrase     = "AI_VAULT_PASSPHRASE"
+	EnvPassphraseFile = "AI_VAULT_PASSPHRASE_FILE"
+)
+
+// Passphrase returns the passphrase from the AI_VAULT_PASSPHRASE environment variable,
+// the file named by AI_VAULT_PASSPHRASE_FILE or the terminal, in that order.
+// The environment is read when called, before it may be cleared;
+// headless systems should set one of the variables.
+func Passphrase() PassphraseFunc {
+	env := os.Getenv(EnvPassphrase)
+	file := os.Getenv(EnvPassphraseFile)
+
+	var cached string
+	return func(create bool) (string, error) {
+		if env != "" {
+			return env, nil
+		}
+		if file != "" {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return "", err
+			}
+			return strings.TrimRight(string(data), "\r\n"), nil
+		}
+		if cached != "" {
+			return cached, nil
+		}
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return "", fmt.Errorf("vault passphrase required: set %s or %s", EnvPassphrase, EnvPassphraseFile)
+		}
+		pass, err := readPassword("Vault passphrase: ")
+		if err != nil {
+			return "", err
+		}
+		if create {
+			confirm, err := readPassword("Confirm passphrase: ")
+			if err != nil {
+				return "", err
+			}
+			if confirm != pass {
+				return "", fmt.Errorf("passphrases do not match")
+			}
+		}
+		cached = pass
+		return pass, nil
+	}
+}
+
+func readPassword(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	data, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,278 @@
+// Package vault is an encrypted secret store in a single file,
+// portable across operating systems and usable without a desktop keyring.
+//
+// The secrets are kept per owner, encrypted with XChaCha20-Poly1305
+// using a key derived from the passphrase with scrypt.
+package vault
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	version = 1
+
+	// scrypt parameters recommended for interactive use
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+
+	saltSize = 16
+)
+
+// namespace of secrets without owner
+const defaultOwner = "_"
+
+// ErrNotFound is returned for secrets not in the vault, including when there is no vault file.
+var ErrNotFound = errors.New("secret not found")
+
+// PassphraseFunc returns the passphrase of the vault.
+// create is true if the vault file is about to be created.
+type PassphraseFunc func(create bool) (string, error)
+
+// file is the vault file format.
+type file struct {
+	Version int    `json:"version"`
+	KDF     string `json:"kdf"`
+	N       int    `json:"n"`
+	R       int    `json:"r"`
+	P       int    `json:"p"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+type Vault struct {
+	path       string
+	passphrase PassphraseFunc
+
+	mu   sync.Mutex
+	salt []byte
+	key  []byte
+	// owner/key/value, nil until loaded
+	secrets map[string]map[string]string
+}
+
+// Open returns the vault of the file.
+// The file is read and decrypted on first use and created by the first Set.
+func Open(path string, passphrase PassphraseFunc) *Vault {
+	return &Vault{
+		path:       path,
+		passphrase: passphrase,
+	}
+}
+
+func (r *Vault) Path() string {
+	return r.path
+}
+
+// Get implements api.SecretStore.
+func (r *Vault) Get(owner, key string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.load(); err != nil {
+		return "", err
+	}
+	v, ok := r.secrets[ns(owner)][key]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	return v, nil
+}
+
+func (r *Vault) Set(owner, key, value string) error {
+	if key == "" {
+		return fmt.Errorf("key is required")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.load(); err != nil {
+		return err
+	}
+	m, ok := r.secrets[ns(owner)]
+	if !ok {
+		m = make(map[string]string)
+		r.secrets[ns(owner)] = m
+	}
+	m[key] = value
+	return r.save()
+}
+
+func (r *Vault) Delete(owner, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.load(); err != nil {
+		return err
+	}
+	m := r.secrets[ns(owner)]
+	if _, ok := m[key]; !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	delete(m, key)
+	if len(m) == 0 {
+		delete(r.secrets, ns(owner))
+	}
+	return r.save()
+}
+
+// List returns the sorted keys of the owner.
+func (r *Vault) List(owner string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	var keys []string
+	for k := range r.secrets[ns(owner)] {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func ns(owner string) string {
+	if owner == "" {
+		return defaultOwner
+	}
+	return owner
+}
+
+// load reads and decrypts the vault file once, an empty vault if the file does not exist.
+func (r *Vault) load() error {
+	if r.secrets != nil {
+		return nil
+	}
+	data, err := os.ReadFile(r.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		r.secrets = make(map[string]map[string]string)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return fmt.Errorf("invalid vault file %s: %w", r.path, err)
+	}
+	if f.Version != version || f.KDF != "scrypt" {
+		return fmt.Errorf("unsupported vault file %s: version %v kdf %q", r.path, f.Version, f.KDF)
+	}
+	pass, err := r.passphrase(false)
+	if err != nil {
+		return err
+	}
+	key, err := scrypt.Key([]byte(pass), f.Salt, f.N, f.R, f.P, chacha20poly1305.KeySize)
+	if err != nil {
+		return err
+	}
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return err
+	}
+	if len(f.Nonce) != aead.NonceSize() {
+		return fmt.Errorf("invalid vault file %s: nonce size", r.path)
+	}
+	plain, err := aead.Open(nil, f.Nonce, f.Data, additionalData(&f))
+	if err != nil {
+		return fmt.Errorf("failed to decrypt vault %s: wrong passphrase or corrupted file", r.path)
+	}
+	var secrets map[string]map[string]string
+	if err := json.Unmarshal(plain, &secrets); err != nil {
+		return fmt.Errorf("invalid vault content %s: %w", r.path, err)
+	}
+	if secrets == nil {
+		secrets = make(map[string]map[string]string)
+	}
+	r.salt = f.Salt
+	r.key = key
+	r.secrets = secrets
+	return nil
+}
+
+// save encrypts and writes the vault file atomically.
+func (r *Vault) save() error {
+	if r.key == nil {
+		pass, err := r.passphrase(true)
+		if err != nil {
+			return err
+		}
+		if pass == "" {
+			return fmt.Errorf("passphrase is required")
+		}
+		salt := make([]byte, saltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return err
+		}
+		key, err := scrypt.Key([]byte(pass), salt, scryptN, scryptR, scryptP, chacha20poly1305.KeySize)
+		if err != nil {
+			return err
+		}
+		r.salt = salt
+		r.key = key
+	}
+	aead, err := chacha20poly1305.NewX(r.key)
+	if err != nil {
+		return err
+	}
+	plain, err := json.Marshal(r.secrets)
+	if err != nil {
+		return err
+	}
+	f := file{
+		Version: version,
+		KDF:     "scrypt",
+		N:       scryptN,
+		R:       scryptR,
+		P:       scryptP,
+		Salt:    r.salt,
+		Nonce:   make([]byte, aead.NonceSize()),
+	}
+	if _, err := rand.Read(f.Nonce); err != nil {
+		return err
+	}
+	f.Data = aead.Seal(nil, f.Nonce, plain, additionalData(&f))
+
+	data, err := json.MarshalIndent(&f, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), ".vault-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), r.path)
+}
+
+// additionalData binds the header to the ciphertext.
+func additionalData(f *file) []byte {
+	return fmt.Appendf(nil, "ai-vault/v%d/%s/%d/%d/%d", f.Version, f.KDF, f.N, f.R, f.P)
+}
@@ -0,0 +1,65 @@
+package vault
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func pass(s string) PassphraseFunc {
+	return func(bool) (string, error) {
+		return s, nil
+	}
+}
+
+func TestVault(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vault.json")
+
+	// no vault file yet
+	v := Open(path, pass("secret"))
+	if _, err := v.Get("alice@example.com", "openai"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected not found: %v", err)
+	}
+
+	if err := v.Set("alice@example.com", "openai", "sk-alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Set("bob@example.com", "openai", "sk-bob"); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Set("alice@example.com", "github", "gh-alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "sk-alice") {
+		t.Errorf("vault file not encrypted")
+	}
+	if fi, _ := os.Stat(path); fi.Mode().Perm() != 0600 {
+		t.Errorf("vault file mode: %v", fi.Mode())
+	}
+
+	// reopen
+	v = Open(path, pass("secret"))
+	if s, err := v.Get("bob@example.com", "openai"); err != nil || s != "sk-bob" {
+		t.Errorf("get: %q %v", s, err)
+	}
+	if keys, _ := v.List("alice@example.com"); strings.Join(keys, ",") != "github,openai" {
+		t.Errorf("list: %v", keys)
+	}
+	if err := v.Delete("alice@example.com", "openai"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path, pass("secret")).Get("alice@example.com", "openai"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("not deleted: %v", err)
+	}
+
+	if _, err := Open(path, pass("wrong")).Get("bob@example.com", "openai"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("expected decryption error: %v", err)
+	}
+}
@@ -19,6 +19,8 @@ import (
 	"mvdan.cc/sh/v3/interp"
 	"mvdan.cc/sh/v3/syntax"
 
+	"github.com/qiangli/ai/swarm/api"
+	"github.com/qiangli/ai/swarm/atm/conf"
 	"github.com/qiangli/shell/vfs"
 )
 
@@ -89,6 +91,18 @@ func VirtualExecHandler(vs *VirtualSystem, runner *interp.Runner) func(next inte
 	handle := func(ctx context.Context, args []string) error {
 		hc := interp.HandlerCtx(ctx)
 
+		// actions are authorized when the tool is called
+		if !conf.IsAction(args[0]) {
+			var agent string
+			if vs.agent != nil {
+				agent = string(api.NewPackname(vs.agent.Pack, vs.agent.Name))
+			}
+			if _, err := authorize(ctx, vs.vars, commandPolicyRequest(agent, args)); err != nil {
+				fmt.Fprintln(hc.Stderr, err.Error())
+				return interp.ExitStatus(126)
+			}
+		}
+
 		importEnv(ctx, vs, hc)
 		err := HandleAction(ctx, vs, args)
 		// sync env